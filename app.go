@@ -2,12 +2,17 @@ package main
 
 import (
 	"SweetDesk/internal/services"
+	"bytes"
 	"context"
 	"fmt"
+	"image"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,13 +27,43 @@ type BatchItem struct {
 	DownloadURL string `json:"downloadURL"` // URL to download image from
 	Name        string `json:"name"`
 	Dimension   string `json:"dimension"` // "WIDTHxHEIGHT"
+
+	// PreviewURL, if set, is hashed before downloading so near-duplicates
+	// of saved wallpapers are skipped without fetching the full image
+	PreviewURL string `json:"previewURL,omitempty"`
+	// AllowDuplicate processes near-duplicates anyway; they are only flagged
+	AllowDuplicate bool `json:"allowDuplicate,omitempty"`
+
+	// Options selects how the image is adapted to the target, e.g. seam
+	// carving to change its aspect ratio
+	Options *services.ProcessingOptions `json:"options,omitempty"`
+	// Output overrides the job's output format for this item
+	Output *services.OutputFormat `json:"output,omitempty"`
+
+	// Image is the search result the item came from; its credit fields are
+	// saved next to the output file so the source can be credited
+	Image *services.ImageResult `json:"image,omitempty"`
+	// Attribution overrides the credit derived from Image
+	Attribution *services.Attribution `json:"attribution,omitempty"`
 }
 
 // BatchItemStatus represents the processing status of a single item
 type BatchItemStatus struct {
 	ID     string `json:"id"`
-	Status string `json:"status"` // "pending", "processing", "done", "error"
+	Status string `json:"status"` // "pending", "processing", "done", "error", "skipped"
 	Error  string `json:"error,omitempty"`
+
+	// DuplicateOf is the saved file this item is a near-duplicate of
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+
+	// Quality is the JPEG quality the item was saved at, reported when
+	// its output format sets a size limit
+	Quality int `json:"quality,omitempty"`
+
+	// Download progress for items fetched from DownloadURL.
+	// BytesTotal is -1 when the server does not report the size.
+	BytesReceived int64 `json:"bytesReceived,omitempty"`
+	BytesTotal    int64 `json:"bytesTotal,omitempty"`
 }
 
 // ProcessingStatus represents the overall batch processing state
@@ -46,7 +81,38 @@ type App struct {
 	ctx            context.Context
 	imageProcessor *services.ImageProcessor
 	coreBridge     *services.CoreBridge
-	pixabayKey     string
+	keys           *services.KeyStore
+	localDirs      []string
+	subreddits     []string
+
+	// Extra providers loaded from JSON definitions in the config directory
+	genericProviders []*services.GenericProvider
+
+	// registry holds every configured provider for search and downloads.
+	// It is replaced when keys change, so read it through providers().
+	registryMu sync.RWMutex
+	registry   *services.ProviderRegistry
+
+	// cache stores search responses and previews on disk; nil if unavailable
+	cache *services.SearchCache
+
+	// downloader streams full-size images to staging files; previews
+	// stages previews with a smaller size limit
+	downloader *services.Downloader
+	previews   *services.Downloader
+
+	// hashes caches perceptual hashes of saved wallpapers and previews;
+	// similar searches them for look-alikes
+	hashes  *services.HashCache
+	similar *services.SimilarityIndex
+
+	// policy decides which URLs may be downloaded
+	policy *services.DownloadPolicy
+
+	// Providers kept outside the registry: wikimedia serves the Picture of
+	// the Day, direct downloads from allowlisted hosts
+	wikimedia *services.WikimediaProvider
+	direct    *services.DirectProvider
 
 	// Batch processing state
 	procMu     sync.Mutex
@@ -84,8 +150,158 @@ func (a *App) startup(ctx context.Context) {
 		fmt.Println("✅ SweetDesk-core bridge initialized")
 	}
 
-	// Get Pixabay API key from environment
-	a.pixabayKey = os.Getenv("PIXABAY_API_KEY")
+	// Configure the HTTP transport shared by every provider before any
+	// provider is created
+	if path, err := networkSettingsPath(); err == nil {
+		settings, err := services.LoadNetworkSettings(path)
+		if err != nil {
+			log.Printf("⚠️  Using default network settings: %v", err)
+		}
+		if err := services.ConfigureNetwork(settings); err != nil {
+			log.Printf("⚠️  Using default network settings: %v", err)
+		}
+	}
+
+	// Load provider API keys from <config>/keys.json. PIXABAY_API_KEY,
+	// UNSPLASH_ACCESS_KEY, PEXELS_API_KEY and WALLHAVEN_API_KEY override them.
+	keysPath := ""
+	if configDir, err := services.ConfigDir(); err == nil {
+		keysPath = filepath.Join(configDir, "keys.json")
+	}
+	a.keys, err = services.NewKeyStore(keysPath)
+	if err != nil {
+		log.Printf("⚠️  Ignoring stored API keys: %v", err)
+	}
+
+	// Get local image folders to index from environment
+	a.localDirs = filepath.SplitList(os.Getenv("SWEETDESK_LOCAL_DIRS"))
+
+	// Get wallpaper subreddits from environment (comma-separated)
+	if subs := os.Getenv("SWEETDESK_SUBREDDITS"); subs != "" {
+		a.subreddits = strings.Split(subs, ",")
+	}
+
+	// Load declarative provider definitions from <config>/providers/*.json
+	if configDir, err := services.ConfigDir(); err == nil {
+		providers, errs := services.LoadGenericProviders(ctx, filepath.Join(configDir, "providers"))
+		for _, err := range errs {
+			log.Printf("⚠️  Skipping provider definition: %v", err)
+		}
+		for _, p := range providers {
+			log.Printf("✅ Loaded provider definition: %s", p.GetName())
+		}
+		a.genericProviders = providers
+	}
+
+	// Stage downloads on disk, capped at SWEETDESK_MAX_DOWNLOAD_MB
+	maxDownload := int64(services.DefaultMaxDownloadBytes)
+	if mb, err := strconv.Atoi(os.Getenv("SWEETDESK_MAX_DOWNLOAD_MB")); err == nil && mb > 0 {
+		maxDownload = int64(mb) << 20
+	}
+	downloader, err := services.NewDownloader(filepath.Join(os.TempDir(), "sweetdesk-downloads"), maxDownload)
+	if err != nil {
+		log.Printf("⚠️  Download staging unavailable: %v", err)
+	}
+	a.downloader = downloader
+	previews, err := services.NewDownloader(filepath.Join(os.TempDir(), "sweetdesk-downloads"), services.MaxPreviewBytes)
+	if err != nil {
+		log.Printf("⚠️  Preview staging unavailable: %v", err)
+	}
+	a.previews = previews
+
+	// Only download from registered providers and user-approved domains
+	allowlistPath := ""
+	if configDir, err := services.ConfigDir(); err == nil {
+		allowlistPath = filepath.Join(configDir, "download_allowlist.json")
+	}
+	a.policy, err = services.NewDownloadPolicy(func(u *url.URL) bool {
+		_, ok := a.providers().ProviderForURL(u.String())
+		return ok
+	}, allowlistPath)
+	if err != nil {
+		log.Printf("⚠️  Ignoring download allowlist: %v", err)
+	}
+	for _, d := range []*services.Downloader{a.downloader, a.previews} {
+		if d != nil {
+			d.SetPolicy(a.policy)
+		}
+	}
+
+	// Cache search responses on disk, as required by Pixabay's terms
+	if cacheDir, err := services.CacheDir(); err == nil {
+		cache, err := services.NewSearchCache(cacheDir, services.DefaultCacheTTL, services.DefaultCacheMaxBytes)
+		if err != nil {
+			log.Printf("⚠️  Search cache disabled: %v", err)
+		} else {
+			a.cache = cache
+		}
+	}
+
+	// Remember image hashes so output folders are only decoded once
+	hashesPath := ""
+	if cacheDir, err := services.CacheDir(); err == nil {
+		hashesPath = filepath.Join(cacheDir, "image_hashes.json")
+	}
+	a.hashes, err = services.NewHashCache(hashesPath)
+	if err != nil {
+		log.Printf("⚠️  Rebuilding image hashes: %v", err)
+	}
+	a.similar = services.NewSimilarityIndex(a.hashes, a.cache)
+
+	a.wikimedia = services.NewWikimediaProvider(ctx)
+	a.direct = services.NewDirectProvider(ctx)
+	a.registry = a.buildRegistry()
+}
+
+// providers returns the current provider registry
+func (a *App) providers() *services.ProviderRegistry {
+	a.registryMu.RLock()
+	defer a.registryMu.RUnlock()
+	return a.registry
+}
+
+// reloadProviders rebuilds the registry, e.g. after an API key changed.
+// Searches already running finish on the previous providers.
+func (a *App) reloadProviders() {
+	registry := a.buildRegistry()
+	a.registryMu.Lock()
+	a.registry = registry
+	a.registryMu.Unlock()
+}
+
+// buildRegistry registers every provider that is usable with the current
+// configuration. Providers without a required key are left out.
+func (a *App) buildRegistry() *services.ProviderRegistry {
+	registry := services.NewProviderRegistry()
+	cached := func(p services.APIProvider) services.APIProvider {
+		if a.cache == nil {
+			return p
+		}
+		return services.NewCachedProvider(p, a.cache)
+	}
+
+	if key, _ := a.keys.Key("Pixabay"); key != "" {
+		registry.Register(cached(services.NewPixabayProvider(a.ctx, key)))
+	}
+	if key, _ := a.keys.Key("Unsplash"); key != "" {
+		registry.Register(cached(services.NewUnsplashProvider(a.ctx, key)))
+	}
+	if key, _ := a.keys.Key("Pexels"); key != "" {
+		registry.Register(cached(services.NewPexelsProvider(a.ctx, key)))
+	}
+	// The Wallhaven key is optional, only needed for NSFW results
+	wallhavenKey, _ := a.keys.Key("Wallhaven")
+	registry.Register(cached(services.NewWallhavenProvider(a.ctx, wallhavenKey)))
+	registry.Register(cached(a.wikimedia))
+	registry.Register(cached(services.NewRedditProvider(a.ctx, a.subreddits)))
+	if len(a.localDirs) > 0 {
+		// Not cached: the folder index is already local and must see new files
+		registry.Register(services.NewLocalFolderProvider(a.ctx, a.localDirs))
+	}
+	for _, p := range a.genericProviders {
+		registry.Register(cached(p))
+	}
+	return registry
 }
 
 // domReady is called after front-end resources have been loaded
@@ -158,13 +374,31 @@ func (a *App) Greet(name string) string {
 	return fmt.Sprintf("Hello %s, It's show time!", name)
 }
 
-// SearchImages searches for images using Pixabay API
-func (a *App) SearchImages(query string, page int, perPage int) ([]services.ImageResult, error) {
-	if a.pixabayKey == "" {
-		return nil, fmt.Errorf("Pixabay API key not configured")
+// SearchImages searches the given providers in parallel and merges their
+// results. An empty list or "all" searches every configured provider.
+// Providers that fail or time out are reported in the result's errors.
+func (a *App) SearchImages(query string, page int, perPage int, providers []string) (*services.FanOutResult, error) {
+	options := services.SearchOptions{
+		Page:        page,
+		PerPage:     perPage,
+		MinWidth:    1920,
+		MinHeight:   1080,
+		Orientation: "horizontal",
 	}
 
-	provider := services.NewPixabayProvider(a.ctx, a.pixabayKey)
+	return a.providers().Search(providers, query, options, services.DefaultProviderTimeout)
+}
+
+// SearchImagesWithOptions searches like SearchImages but with every filter
+// set by the caller, e.g. Pixabay's image type, colors and safe search.
+// Zero values mean no filter.
+func (a *App) SearchImagesWithOptions(query string, options services.SearchOptions, providers []string) (*services.FanOutResult, error) {
+	return a.providers().Search(providers, query, options, services.DefaultProviderTimeout)
+}
+
+// SearchImagesPaged searches a single provider and returns the page with its
+// totals, whether more results exist, and the provider's rate-limit state
+func (a *App) SearchImagesPaged(query string, page int, perPage int, provider string) (*services.SearchPage, error) {
 	options := services.SearchOptions{
 		Page:        page,
 		PerPage:     perPage,
@@ -173,17 +407,67 @@ func (a *App) SearchImages(query string, page int, perPage int) ([]services.Imag
 		Orientation: "horizontal",
 	}
 
-	return provider.Search(query, options)
+	return a.providers().SearchOne(provider, query, options)
 }
 
-// DownloadImage downloads an image from a URL
+// GetImageTags returns the tags of a search result when its provider only
+// gives them on request, such as Wallhaven. It returns nil for providers
+// whose results already carry their tags.
+func (a *App) GetImageTags(provider string, id string) ([]string, error) {
+	p, ok := a.providers().Get(provider)
+	if !ok {
+		return nil, fmt.Errorf("provider not configured: %s", provider)
+	}
+	if t, ok := p.(services.TagProvider); ok {
+		return t.Tags(id)
+	}
+	return nil, nil
+}
+
+// ListProviders reports every known provider and whether it is configured
+// and responding
+func (a *App) ListProviders() []services.ProviderStatus {
+	registry := a.providers()
+	statuses := registry.Status()
+
+	// Providers that need configuration are listed even when missing so the
+	// frontend can explain how to enable them
+	for _, name := range []string{"Pixabay", "Unsplash", "Pexels", "Local"} {
+		if _, ok := registry.Get(name); !ok {
+			statuses = append(statuses, services.ProviderStatus{Name: name, Configured: false})
+		}
+	}
+	return statuses
+}
+
+// DownloadImage downloads an image from a URL using the provider that
+// serves it. URLs from hosts that are neither a provider's nor on the
+// download allowlist are refused.
 func (a *App) DownloadImage(imageURL string) (string, error) {
-	if a.pixabayKey == "" {
-		return "", fmt.Errorf("Pixabay API key not configured")
+	path, err := a.downloadToFile(imageURL, nil)
+	if err != nil {
+		return "", err
 	}
+	defer os.Remove(path)
 
-	provider := services.NewPixabayProvider(a.ctx, a.pixabayKey)
-	data, err := provider.Download(imageURL)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read downloaded image: %w", err)
+	}
+
+	return a.imageProcessor.ConvertToBase64(data), nil
+}
+
+// GetPreviewImage returns a search result's preview image as base64,
+// served from the disk cache when available so previews work offline
+func (a *App) GetPreviewImage(previewURL string) (string, error) {
+	var data []byte
+	var err error
+	if a.cache != nil {
+		data, err = a.cache.Preview(previewURL, a.fetchImage)
+	} else {
+		data, err = a.fetchImage(previewURL)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -191,6 +475,148 @@ func (a *App) DownloadImage(imageURL string) (string, error) {
 	return a.imageProcessor.ConvertToBase64(data), nil
 }
 
+// ClearCache deletes all cached search responses and previews
+func (a *App) ClearCache() error {
+	if a.cache == nil {
+		return nil
+	}
+	return a.cache.Clear()
+}
+
+// ListProviderKeys reports which providers have an API key and whether it
+// comes from the environment or the config file. Keys are not returned.
+func (a *App) ListProviderKeys() []services.ProviderKeyInfo {
+	return a.keys.List()
+}
+
+// SetProviderKey stores an API key (an empty key removes it) and rebuilds
+// the providers so it takes effect immediately. An environment variable
+// for the same provider still takes precedence.
+func (a *App) SetProviderKey(provider string, key string) error {
+	if err := a.keys.Set(provider, key); err != nil {
+		return err
+	}
+	a.reloadProviders()
+	return nil
+}
+
+// TestProviderKey checks an API key against the provider and reports its
+// quota. An empty key tests the key currently in use.
+func (a *App) TestProviderKey(provider string, key string) (*services.KeyCheckResult, error) {
+	if key == "" {
+		key, _ = a.keys.Key(provider)
+	}
+	return services.CheckProviderKey(a.ctx, provider, key)
+}
+
+// GetDownloadAllowlist returns the domains the user approved for downloads
+// in addition to the providers' own hosts
+func (a *App) GetDownloadAllowlist() []string {
+	return a.policy.Allowlist()
+}
+
+// SetDownloadAllowlist replaces the user-approved download domains.
+// A domain also allows its subdomains.
+func (a *App) SetDownloadAllowlist(domains []string) error {
+	return a.policy.SetAllowlist(domains)
+}
+
+// fetchImage downloads a small image such as a preview into memory. It
+// is staged like full downloads, so the policy also checks redirects and
+// resolved addresses, and the size is capped at MaxPreviewBytes.
+func (a *App) fetchImage(imageURL string) ([]byte, error) {
+	path, err := a.stageDownload(a.previews, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	return os.ReadFile(path)
+}
+
+// downloadToFile streams imageURL to a staging file and returns its path.
+// The caller removes the file.
+func (a *App) downloadToFile(imageURL string, progress services.DownloadProgress) (string, error) {
+	return a.stageDownload(a.downloader, imageURL, progress)
+}
+
+// stageDownload streams imageURL to a staging file of d
+func (a *App) stageDownload(d *services.Downloader, imageURL string, progress services.DownloadProgress) (string, error) {
+	if d == nil {
+		return "", fmt.Errorf("download staging directory unavailable")
+	}
+	if err := a.policy.Check(imageURL); err != nil {
+		return "", err
+	}
+
+	provider := a.providerForURL(imageURL)
+	if s, ok := provider.(services.StreamingProvider); ok {
+		return s.DownloadFile(d, imageURL, progress)
+	}
+	data, err := provider.Download(imageURL)
+	if err != nil {
+		return "", err
+	}
+	return d.Stage(bytes.NewReader(data), progress, int64(len(data)))
+}
+
+// providerForURL returns the provider that serves imageURL
+func (a *App) providerForURL(imageURL string) services.APIProvider {
+	if p, ok := a.providers().ProviderForURL(imageURL); ok {
+		return p
+	}
+	return a.direct
+}
+
+// GetPictureOfTheDay returns the Wikimedia Commons Picture of the Day.
+// date is "YYYY-MM-DD"; an empty date means today.
+func (a *App) GetPictureOfTheDay(date string) (*services.ImageResult, error) {
+	day := time.Now()
+	if date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q (expected YYYY-MM-DD): %w", date, err)
+		}
+		day = parsed
+	}
+
+	return a.wikimedia.PictureOfTheDay(day)
+}
+
+// GetNetworkSettings returns the proxy, CA bundle and connection settings
+// used for every provider request
+func (a *App) GetNetworkSettings() services.NetworkSettings {
+	path, err := networkSettingsPath()
+	if err != nil {
+		return services.DefaultNetworkSettings()
+	}
+	settings, err := services.LoadNetworkSettings(path)
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	return settings
+}
+
+// SetNetworkSettings applies and saves network settings. The shared
+// transport is rebuilt, so the change takes effect for the next request.
+func (a *App) SetNetworkSettings(settings services.NetworkSettings) error {
+	if err := services.ConfigureNetwork(settings); err != nil {
+		return err
+	}
+	path, err := networkSettingsPath()
+	if err != nil {
+		return err
+	}
+	return services.SaveNetworkSettings(path, settings)
+}
+
+func networkSettingsPath() (string, error) {
+	configDir, err := services.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "network.json"), nil
+}
+
 // UpscaleImage upscales an image using AI
 func (a *App) UpscaleImage(base64Data string, imageType string, scale int) (string, error) {
 	if a.coreBridge == nil {
@@ -220,9 +646,19 @@ func (a *App) UpscaleImage(base64Data string, imageType string, scale int) (stri
 
 // ProcessImage is the main processing pipeline
 func (a *App) ProcessImage(base64Data string, targetWidth int, targetHeight int, savePath string, fileName string) (string, error) {
+	return a.ProcessImageWithOptions(base64Data, targetWidth, targetHeight, services.ProcessingOptions{}, savePath, fileName)
+}
+
+// ProcessImageWithOptions is ProcessImage with control over how the image
+// is adapted to the target: a crop, rotation or flip of the source, seam
+// carving or a fit mode to change its aspect ratio
+func (a *App) ProcessImageWithOptions(base64Data string, targetWidth int, targetHeight int, options services.ProcessingOptions, savePath string, fileName string) (string, error) {
 	if a.coreBridge == nil {
 		return "", fmt.Errorf("coreBridge not initialized")
 	}
+	if err := options.Validate(); err != nil {
+		return "", err
+	}
 
 	if targetWidth <= 0 || targetHeight <= 0 {
 		return "", fmt.Errorf("invalid target resolution: %dx%d (dimensions must be positive)", targetWidth, targetHeight)
@@ -251,9 +687,21 @@ func (a *App) ProcessImage(base64Data string, targetWidth int, targetHeight int,
 		return "", fmt.Errorf("failed to decode image: %w", err)
 	}
 
+	data, _, err = a.imageProcessor.PrepareSource(data, targetWidth, targetHeight, options)
+	if err != nil {
+		return "", err
+	}
+
+	// Fit modes other than stretching upscale with the source's aspect
+	// ratio and crop or pad to the target afterwards
+	upscaleWidth, upscaleHeight := targetWidth, targetHeight
+	if width, height, _, err := a.imageProcessor.DecodeDimensions(data); err == nil {
+		upscaleWidth, upscaleHeight = a.imageProcessor.UpscaleSize(width, height, targetWidth, targetHeight, options.FitMode)
+	}
+
 	opts := &types.ProcessingOptions{
-		TargetWidth:     targetWidth,
-		TargetHeight:    targetHeight,
+		TargetWidth:     min(upscaleWidth, maxResolution),
+		TargetHeight:    min(upscaleHeight, maxResolution),
 		ScaleFactor:     0,
 		MaxResolution:   maxResolution,
 		KeepAspectRatio: false,
@@ -264,6 +712,11 @@ func (a *App) ProcessImage(base64Data string, targetWidth int, targetHeight int,
 		return "", fmt.Errorf("failed to upscale: %w", err)
 	}
 
+	upscaled, _, err = a.imageProcessor.FitBytes(upscaled, targetWidth, targetHeight, options)
+	if err != nil {
+		return "", fmt.Errorf("failed to fit image: %w", err)
+	}
+
 	if savePath != "" && fileName != "" {
 		_, err := a.imageProcessor.SaveToFile(upscaled, savePath, fileName)
 		if err != nil {
@@ -277,6 +730,20 @@ func (a *App) ProcessImage(base64Data string, targetWidth int, targetHeight int,
 // ProcessBatch processes a batch of images in the backend.
 // Progress is emitted via Wails events so the frontend can re-render freely.
 func (a *App) ProcessBatch(items []BatchItem, savePath string) {
+	a.ProcessBatchWithOutput(items, savePath, services.OutputFormat{})
+}
+
+// batchOutput is how a processed batch item is finished and saved
+type batchOutput struct {
+	fileName string
+	target   image.Point
+	options  services.ProcessingOptions
+	format   services.OutputFormat
+}
+
+// ProcessBatchWithOutput is ProcessBatch saving in the given output format,
+// PNG by default. Items can override it with their own Output.
+func (a *App) ProcessBatchWithOutput(items []BatchItem, savePath string, output services.OutputFormat) {
 	a.procMu.Lock()
 	if a.procStatus.IsProcessing {
 		a.procMu.Unlock()
@@ -313,44 +780,45 @@ func (a *App) ProcessBatch(items []BatchItem, savePath string) {
 		time.Sleep(100 * time.Millisecond)
 		a.emitProcessingStatus()
 
-		// Prepare batch items for SweetDesk-core
-		batchItems := make([]types.BatchItem, len(items))
+		// Prepare batch items for SweetDesk-core.
+		// batchIndex maps each core batch entry back to its position in items,
+		// since failed or skipped items are not sent to the core.
+		batchItems := make([]types.BatchItem, 0, len(items))
+		batchIndex := make([]int, 0, len(items))
+		batchOutputs := make([]batchOutput, 0, len(items))
+		saved := a.savedHashes(savePath)
 		for i, item := range items {
-			// Get base64 data: either provided directly or download from URL
-			base64Data := item.Base64Data
-			if base64Data == "" && item.DownloadURL != "" {
-				downloaded, err := a.DownloadImage(item.DownloadURL)
-				if err != nil {
-					a.procMu.Lock()
-					a.procStatus.Items[i].Status = "error"
-					a.procStatus.Items[i].Error = err.Error()
-					a.recalcProgress()
-					a.procMu.Unlock()
-					a.emitProcessingStatus()
+			if item.Options != nil {
+				if err := item.Options.Validate(); err != nil {
+					a.setItemStatus(i, "error", err.Error())
 					continue
 				}
-				base64Data = downloaded
 			}
-			if base64Data == "" {
-				a.procMu.Lock()
-				a.procStatus.Items[i].Status = "error"
-				a.procStatus.Items[i].Error = "no image data available"
-				a.recalcProgress()
-				a.procMu.Unlock()
-				a.emitProcessingStatus()
+			format := output
+			if item.Output != nil {
+				format = *item.Output
+			}
+			if err := format.Validate(); err != nil {
+				a.setItemStatus(i, "error", err.Error())
 				continue
 			}
 
-			data, err := a.imageProcessor.ConvertFromBase64(base64Data)
+			// Near-duplicates of saved wallpapers are caught from the
+			// preview when possible, before the full image is downloaded
+			if dup := a.previewDuplicate(item, saved); dup != "" && !item.AllowDuplicate {
+				a.setItemDuplicate(i, dup, true)
+				continue
+			}
+
+			// Stage the source image on disk: downloads are streamed straight
+			// to a file, base64 data is decoded and written out
+			tmpInput, err := a.stageBatchInput(i, item)
 			if err != nil {
-				a.procMu.Lock()
-				a.procStatus.Items[i].Status = "error"
-				a.procStatus.Items[i].Error = err.Error()
-				a.recalcProgress()
-				a.procMu.Unlock()
-				a.emitProcessingStatus()
+				a.setItemStatus(i, "error", err.Error())
 				continue
 			}
+			// Ensure temporary input file is cleaned up when processing is done
+			defer os.Remove(tmpInput)
 
 			// Parse dimensions from "WIDTHxHEIGHT"
 			targetWidth, targetHeight := 3840, 2160
@@ -358,71 +826,95 @@ func (a *App) ProcessBatch(items []BatchItem, savePath string) {
 				fmt.Sscanf(item.Dimension, "%dx%d", &targetWidth, &targetHeight)
 			}
 
-			// Sanitize filename
+			// Sanitize filename; the extension follows the output format
 			fileName := item.Name
 			if fileName == "" {
-				fileName = fmt.Sprintf("wallpaper-%s.png", item.ID)
+				fileName = fmt.Sprintf("wallpaper-%s", item.ID)
 			}
-			if filepath.Ext(fileName) == "" {
-				fileName += ".png"
+			fileName = format.FileName(fileName)
+
+			// Compare the full image with the output folder and with the
+			// items queued before it in this batch
+			if fh, err := services.HashImageFile(tmpInput); err == nil {
+				if dup := duplicateOf(fh.Hash, saved); dup != "" {
+					a.setItemDuplicate(i, dup, !item.AllowDuplicate)
+					if !item.AllowDuplicate {
+						continue
+					}
+				}
+				saved = append(saved, services.FileHash{Path: filepath.Join(savePath, fileName), Hash: fh.Hash})
 			}
 
-			// Save input to temp file
-			tmpDir := ""
-			if a.coreBridge != nil {
-				tmpDir = a.coreBridge.TmpDir
+			var options services.ProcessingOptions
+			if item.Options != nil {
+				options = *item.Options
+				if err := a.prepareSourceFile(tmpInput, targetWidth, targetHeight, options); err != nil {
+					a.setItemStatus(i, "error", err.Error())
+					continue
+				}
 			}
-			if tmpDir == "" {
-				tmpDir = os.TempDir()
+
+			// With a fit mode the core upscales to a size of the source's
+			// aspect ratio, which is then cropped or padded to the target
+			upscaleWidth, upscaleHeight := targetWidth, targetHeight
+			width, height, _, err := a.imageProcessor.DecodeFileDimensions(tmpInput)
+			if err == nil {
+				upscaleWidth, upscaleHeight = a.imageProcessor.UpscaleSize(width, height, targetWidth, targetHeight, options.FitMode)
 			}
 
-			tmpInput := filepath.Join(tmpDir, fmt.Sprintf("batch-%s-input.png", item.ID))
-			if err := os.WriteFile(tmpInput, data, 0644); err != nil {
-				a.procMu.Lock()
-				a.procStatus.Items[i].Status = "error"
-				a.procStatus.Items[i].Error = err.Error()
-				a.recalcProgress()
-				a.procMu.Unlock()
-				a.emitProcessingStatus()
+			// Sources that already have the upscaled resolution (common for
+			// Wallhaven wallpapers) do not need upscaling, only saving.
+			if err == nil && width == upscaleWidth && height == upscaleHeight {
+				quality, err := a.saveOutput(tmpInput, savePath, fileName, image.Pt(targetWidth, targetHeight), options, format)
+				if err != nil {
+					a.setItemStatus(i, "error", err.Error())
+				} else {
+					log.Printf("⏭️  %s is already %dx%d, skipped upscaling", item.ID, width, height)
+					a.saveAttribution(item, filepath.Join(savePath, fileName))
+					a.setItemQuality(i, quality, format)
+					a.setItemStatus(i, "done", "")
+				}
 				continue
 			}
-			// Ensure temporary input file is cleaned up when processing is done
-			defer os.Remove(tmpInput)
 
 			// Check core bridge availability after temp file is created and deferred for cleanup
 			if a.coreBridge == nil {
-				a.procMu.Lock()
-				a.procStatus.Items[i].Status = "error"
-				a.procStatus.Items[i].Error = "core bridge not initialized"
-				a.recalcProgress()
-				a.procMu.Unlock()
-				a.emitProcessingStatus()
+				a.setItemStatus(i, "error", "core bridge not initialized")
 				continue
 			}
 
+			// The core writes PNG; other formats are converted from a
+			// temporary file once it is done
 			tmpOutput := filepath.Join(savePath, fileName)
+			if format.Reencodes("png") {
+				tmpOutput = strings.TrimSuffix(tmpInput, filepath.Ext(tmpInput)) + "-upscaled.png"
+				defer os.Remove(tmpOutput)
+			}
 
 			opts := &types.ProcessingOptions{
-				TargetWidth:     targetWidth,
-				TargetHeight:    targetHeight,
+				TargetWidth:     min(upscaleWidth, 16384),
+				TargetHeight:    min(upscaleHeight, 16384),
 				ScaleFactor:     0,
 				MaxResolution:   16384,
 				KeepAspectRatio: false,
 			}
 
-			batchItems[i] = types.BatchItem{
+			batchItems = append(batchItems, types.BatchItem{
 				InputPath:  tmpInput,
 				OutputPath: tmpOutput,
 				Options:    opts,
-			}
+			})
+			batchIndex = append(batchIndex, i)
+			batchOutputs = append(batchOutputs, batchOutput{fileName, image.Pt(targetWidth, targetHeight), options, format})
 		}
 
 		// Progress callback for UI — current is 1-indexed from SweetDesk-core
 		progressCallback := func(current, total int, item types.BatchItem) {
 			a.procMu.Lock()
-			idx := current - 1 // convert to 0-indexed
-			a.procStatus.Current = idx
-			if idx >= 0 && idx < len(a.procStatus.Items) {
+			pos := current - 1 // convert to 0-indexed
+			if pos >= 0 && pos < len(batchIndex) {
+				idx := batchIndex[pos]
+				a.procStatus.Current = idx
 				a.procStatus.Items[idx].Status = "processing"
 			}
 			// Calculate progress based on completed items (done + error)
@@ -432,11 +924,25 @@ func (a *App) ProcessBatch(items []BatchItem, savePath string) {
 		}
 
 		// Call SweetDesk-core batch API
-		if a.coreBridge != nil {
+		if a.coreBridge != nil && len(batchItems) > 0 {
 			_, err := a.coreBridge.ProcessBatch(batchItems, progressCallback)
 			if err != nil {
 				log.Printf("❌ Batch processing failed: %v", err)
 			}
+			for pos, batchItem := range batchItems {
+				if _, err := os.Stat(batchItem.OutputPath); err != nil {
+					continue
+				}
+				item, out := items[batchIndex[pos]], batchOutputs[pos]
+				quality, err := a.saveOutput(batchItem.OutputPath, savePath, out.fileName, out.target, out.options, out.format)
+				if err != nil {
+					os.Remove(batchItem.OutputPath)
+					a.setItemStatus(batchIndex[pos], "error", err.Error())
+					continue
+				}
+				a.setItemQuality(batchIndex[pos], quality, out.format)
+				a.saveAttribution(item, filepath.Join(savePath, out.fileName))
+			}
 		}
 
 		// Mark remaining non-error items as done
@@ -452,6 +958,250 @@ func (a *App) ProcessBatch(items []BatchItem, savePath string) {
 	}()
 }
 
+// savedHashes hashes the wallpapers already in the output folder
+func (a *App) savedHashes(savePath string) []services.FileHash {
+	if a.hashes == nil {
+		return nil
+	}
+	files, err := a.hashes.HashDir(savePath, false)
+	if err != nil {
+		// The folder may not exist yet
+		return nil
+	}
+	if err := a.hashes.Save(); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	return files
+}
+
+// previewDuplicate hashes the item's preview and returns the saved file it
+// matches, if any. Previews that fail to load are not an error; the full
+// image is checked after downloading.
+func (a *App) previewDuplicate(item BatchItem, saved []services.FileHash) string {
+	if item.PreviewURL == "" || item.Base64Data != "" || len(saved) == 0 {
+		return ""
+	}
+	var data []byte
+	var err error
+	if a.cache != nil {
+		data, err = a.cache.Preview(item.PreviewURL, a.fetchImage)
+	} else {
+		data, err = a.fetchImage(item.PreviewURL)
+	}
+	if err != nil {
+		return ""
+	}
+	hash, err := services.HashImageBytes(data)
+	if err != nil {
+		return ""
+	}
+	return duplicateOf(hash, saved)
+}
+
+// duplicateOf returns the path of the first saved image hash matches
+func duplicateOf(hash services.ImageHash, saved []services.FileHash) string {
+	for _, f := range saved {
+		if hash.IsDuplicate(f.Hash) {
+			return f.Path
+		}
+	}
+	return ""
+}
+
+// setItemDuplicate flags item i as a near-duplicate of path, marking it
+// skipped when skip is set
+func (a *App) setItemDuplicate(i int, path string, skip bool) {
+	a.procMu.Lock()
+	a.procStatus.Items[i].DuplicateOf = path
+	if skip {
+		a.procStatus.Items[i].Status = "skipped"
+		log.Printf("⏭️  %s is a duplicate of %s, skipped", a.procStatus.Items[i].ID, filepath.Base(path))
+	}
+	a.recalcProgress()
+	a.procMu.Unlock()
+	a.emitProcessingStatus()
+}
+
+// FindDuplicates groups near-duplicate images in dir and its subfolders,
+// best copy first, so redundant files can be cleaned up
+func (a *App) FindDuplicates(dir string) ([]services.DuplicateGroup, error) {
+	if a.hashes == nil {
+		return nil, fmt.Errorf("image hashing not initialized")
+	}
+	files, err := a.hashes.HashDir(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.hashes.Save(); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	return services.FindDuplicates(files), nil
+}
+
+// SearchBySimilarImage finds wallpapers that look like source, a file path
+// or base64 image data. It searches the default output folder, the local
+// folders, every folder hashed before and the cached search previews, so
+// the higher-resolution original of a shared image can be tracked down.
+func (a *App) SearchBySimilarImage(source string) ([]services.SimilarityMatch, error) {
+	if a.similar == nil {
+		return nil, fmt.Errorf("image index not initialized")
+	}
+
+	var query services.FileHash
+	if info, err := os.Stat(source); err == nil && !info.IsDir() {
+		// Not cached: the query's folder should not join the searched ones
+		if query, err = services.HashImageFile(source); err != nil {
+			return nil, err
+		}
+	} else {
+		if i := strings.Index(source, ";base64,"); strings.HasPrefix(source, "data:") && i >= 0 {
+			source = source[i+len(";base64,"):]
+		}
+		data, err := a.imageProcessor.ConvertFromBase64(source)
+		if err != nil {
+			return nil, fmt.Errorf("not an image file or base64 image data")
+		}
+		if query, err = services.HashImageData(data); err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+	}
+
+	dirs := append([]string{a.GetDefaultSavePath()}, a.localDirs...)
+	dirs = append(dirs, a.hashes.Dirs()...)
+	matches := a.similar.Search(query, dirs, services.DefaultSimilarityLimit)
+	if err := a.hashes.Save(); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	return matches, nil
+}
+
+// prepareSourceFile applies the source-resolution steps of opts, such as
+// seam carving, to the staged image at path
+func (a *App) prepareSourceFile(path string, targetWidth int, targetHeight int, opts services.ProcessingOptions) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read input image: %w", err)
+	}
+	data, changed, err := a.imageProcessor.PrepareSource(data, targetWidth, targetHeight, opts)
+	if err != nil || !changed {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// saveOutput fits the image at inputPath, already upscaled or at the
+// upscaled size, to the target and saves it in the output folder in the
+// output format. An image that needs neither is copied as-is. It returns
+// the JPEG quality used, if any.
+func (a *App) saveOutput(inputPath string, savePath string, fileName string, target image.Point, opts services.ProcessingOptions, format services.OutputFormat) (int, error) {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read upscaled image: %w", err)
+	}
+	data, quality, changed, err := a.imageProcessor.EncodeOutput(data, target.X, target.Y, opts, format)
+	if err != nil {
+		return 0, err
+	}
+	if !changed && inputPath == filepath.Join(savePath, fileName) {
+		return 0, nil
+	}
+	_, err = a.imageProcessor.SaveToFile(data, savePath, fileName)
+	return quality, err
+}
+
+// saveAttribution writes the item's attribution sidecar next to outputPath.
+// A failure is only logged; the wallpaper itself was saved.
+func (a *App) saveAttribution(item BatchItem, outputPath string) {
+	attribution := item.Attribution
+	if attribution == nil && item.Image != nil && item.DownloadURL != "" {
+		credit := services.AttributionFromResult(*item.Image)
+		credit.SourceURL = item.DownloadURL
+		attribution = &credit
+	}
+	if attribution == nil {
+		return
+	}
+	if err := services.WriteAttribution(outputPath, *attribution); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+}
+
+// GetAttribution returns the attribution saved with a processed wallpaper,
+// or nil if it has none
+func (a *App) GetAttribution(path string) (*services.Attribution, error) {
+	return services.ReadAttribution(path)
+}
+
+// stageBatchInput writes a batch item's source image to a temp file and
+// returns its path. Items with a DownloadURL are streamed to disk with
+// byte-level progress; base64 data is decoded and written out.
+func (a *App) stageBatchInput(i int, item BatchItem) (string, error) {
+	if item.Base64Data == "" && item.DownloadURL != "" {
+		a.setItemStatus(i, "processing", "")
+		return a.downloadToFile(item.DownloadURL, a.downloadProgress(i))
+	}
+	if item.Base64Data == "" {
+		return "", fmt.Errorf("no image data available")
+	}
+
+	data, err := a.imageProcessor.ConvertFromBase64(item.Base64Data)
+	if err != nil {
+		return "", err
+	}
+
+	tmpDir := ""
+	if a.coreBridge != nil {
+		tmpDir = a.coreBridge.TmpDir
+	}
+	if tmpDir == "" {
+		tmpDir = os.TempDir()
+	}
+
+	tmpInput := filepath.Join(tmpDir, fmt.Sprintf("batch-%s-input.png", item.ID))
+	if err := os.WriteFile(tmpInput, data, 0644); err != nil {
+		return "", err
+	}
+	return tmpInput, nil
+}
+
+// downloadProgress records the bytes received for item i, notifying the
+// frontend at most every 200ms.
+func (a *App) downloadProgress(i int) services.DownloadProgress {
+	var lastEmit time.Time
+	return func(received, total int64) {
+		a.procMu.Lock()
+		a.procStatus.Items[i].BytesReceived = received
+		a.procStatus.Items[i].BytesTotal = total
+		a.procMu.Unlock()
+
+		if received == total || time.Since(lastEmit) >= 200*time.Millisecond {
+			lastEmit = time.Now()
+			a.emitProcessingStatus()
+		}
+	}
+}
+
+// setItemStatus updates a single batch item and notifies the frontend.
+func (a *App) setItemStatus(i int, status string, errMsg string) {
+	a.procMu.Lock()
+	a.procStatus.Items[i].Status = status
+	a.procStatus.Items[i].Error = errMsg
+	a.recalcProgress()
+	a.procMu.Unlock()
+	a.emitProcessingStatus()
+}
+
+// setItemQuality records the quality a size-limited item was saved at.
+// The next status update carries it to the frontend.
+func (a *App) setItemQuality(i int, quality int, format services.OutputFormat) {
+	if format.MaxBytes == 0 {
+		return
+	}
+	a.procMu.Lock()
+	a.procStatus.Items[i].Quality = quality
+	a.procMu.Unlock()
+}
+
 // GetProcessingStatus returns the current batch processing state.
 // Called by the frontend on mount/re-mount to recover state.
 func (a *App) GetProcessingStatus() ProcessingStatus {
@@ -474,7 +1224,7 @@ func (a *App) recalcProgress() {
 	}
 	completed := 0
 	for _, item := range a.procStatus.Items {
-		if item.Status == "done" || item.Status == "error" {
+		if item.Status == "done" || item.Status == "error" || item.Status == "skipped" {
 			completed++
 		}
 	}
@@ -1,6 +1,6 @@
 'use client';
 
-import { useState } from 'react';
+import { useEffect, useState } from 'react';
 import type { ImageResult, DownloadItem } from '../lib/types';
 
 interface ImageDetailProps {
@@ -20,6 +20,20 @@ export default function ImageDetail({ image, onBack, onAddToList }: ImageDetailP
     const [customAspect, setCustomAspect] = useState('');
     const [showCustomDimension, setShowCustomDimension] = useState(false);
     const [showCustomAspect, setShowCustomAspect] = useState(false);
+    const [tags, setTags] = useState<string[]>(image.tags || []);
+
+    // Some providers (Wallhaven) only return tags with the image's details
+    useEffect(() => {
+        setTags(image.tags || []);
+        if (!image.source || !window.go?.main?.App?.GetImageTags) return;
+        let cancelled = false;
+        window.go.main.App.GetImageTags(image.source, image.id)
+            .then(fetched => {
+                if (!cancelled && fetched && fetched.length > 0) setTags(fetched);
+            })
+            .catch(() => { /* keep the tags from the search result */ });
+        return () => { cancelled = true; };
+    }, [image.source, image.id, image.tags]);
 
     const effectiveDimension = showCustomDimension ? customDimension : dimension;
     const effectiveAspect = showCustomAspect ? customAspect : aspect;
@@ -118,7 +132,7 @@ export default function ImageDetail({ image, onBack, onAddToList }: ImageDetailP
                 {/* Tags */}
                 <div>
                     <div className="flex flex-wrap gap-2">
-                        {image.tags?.map(tag => (
+                        {tags.map(tag => (
                             <span key={tag} className="text-xs px-2 py-1 bg-secondary text-secondary-foreground rounded-md border border-border">
                                 {tag}
                             </span>
@@ -2,7 +2,7 @@
 
 import { useState, useEffect, useRef } from 'react';
 import { EventsOn } from '../../wailsjs/runtime/runtime';
-import type { DownloadItem } from '../lib/types';
+import type { DownloadItem, ImageResult } from '../lib/types';
 
 // --- Backend types (mirrors Go structs) ---
 interface BatchItem {
@@ -11,12 +11,54 @@ interface BatchItem {
     downloadURL: string;
     name: string;
     dimension: string;
+    previewURL?: string;
+    allowDuplicate?: boolean;
+    options?: ProcessingOptions;
+    output?: OutputFormat;  // overrides the job's format
+    image?: ImageResult;    // search result credited in an attribution sidecar
+    attribution?: Attribution;
+}
+
+interface ProcessingOptions {
+    useSeamCarving?: boolean;
+    protectMask?: string; // base64 image, white = keep
+    removeMask?: string;  // base64 image, white = carve away
+    fitMode?: "stretch" | "crop" | "smart" | "letterbox" | "blur" | "mirror";
+    fillColor?: string;   // letterbox colour, "#rrggbb"
+    // Normalised source coordinates, 0 to 1; applied before upscaling
+    crop?: { x: number; y: number; width: number; height: number };
+    focalPoint?: { x: number; y: number };
+    rotate?: 0 | 90 | 180 | 270; // clockwise
+    flipH?: boolean;
+    flipV?: boolean;
+}
+
+interface OutputFormat {
+    format?: "png" | "jpeg" | "tiff" | "bmp" | "webp";
+    pngCompression?: "default" | "none" | "fast" | "best";
+    quality?: number;       // JPEG quality (1-100)
+    progressive?: boolean;
+    subsampling?: "4:2:0" | "4:2:2" | "4:4:4";
+    tiffCompression?: "deflate" | "lzw" | "none";
+    maxBytes?: number;      // JPEG size limit; the quality is searched for
+}
+
+interface Attribution {
+    provider: string;
+    pageURL: string;
+    author: string;
+    authorURL?: string;
+    license: string;
+    licenseURL?: string;
+    sourceURL?: string;
 }
 
 interface BatchItemStatus {
     id: string;
-    status: 'pending' | 'processing' | 'done' | 'error';
+    status: 'pending' | 'processing' | 'done' | 'error' | 'skipped';
     error?: string;
+    duplicateOf?: string;
+    quality?: number; // JPEG quality used under a maxBytes limit
 }
 
 interface ProcessingStatus {
@@ -38,7 +80,7 @@ export default function ProcessingView({ items, savePath, onComplete }: Processi
     const [progress, setProgress] = useState(0);
     const [currentItem, setCurrentItem] = useState(0);
     const [status, setStatus] = useState<'processing' | 'complete' | 'error'>('processing');
-    const [itemStatuses, setItemStatuses] = useState<Record<string, 'pending' | 'processing' | 'done' | 'error'>>(
+    const [itemStatuses, setItemStatuses] = useState<Record<string, 'pending' | 'processing' | 'done' | 'error' | 'skipped'>>(
         () => Object.fromEntries(items.filter(i => i.selected).map(i => [i.id, 'pending' as const]))
     );
 
@@ -50,9 +92,9 @@ export default function ProcessingView({ items, savePath, onComplete }: Processi
         if (!ps || !ps.items) return;
         setProgress(ps.progress);
         setCurrentItem(ps.current);
-        const newStatuses: Record<string, 'pending' | 'processing' | 'done' | 'error'> = {};
+        const newStatuses: Record<string, 'pending' | 'processing' | 'done' | 'error' | 'skipped'> = {};
         for (const item of ps.items) {
-            newStatuses[item.id] = item.status as 'pending' | 'processing' | 'done' | 'error';
+            newStatuses[item.id] = item.status as 'pending' | 'processing' | 'done' | 'error' | 'skipped';
         }
         setItemStatuses(newStatuses);
         if (ps.done) {
@@ -130,8 +172,11 @@ export default function ProcessingView({ items, savePath, onComplete }: Processi
                     id: item.id,
                     base64Data,
                     downloadURL,
+                    previewURL: item.image.previewURL || undefined,
                     name,
                     dimension: item.dimension || '3840x2160',
+                    // Credit the source in a sidecar next to the saved wallpaper
+                    image: item.image,
                 };
             });
 
@@ -196,6 +241,11 @@ export default function ProcessingView({ items, savePath, onComplete }: Processi
                                         <path strokeLinecap="round" strokeLinejoin="round" d="M6 18L18 6M6 6l12 12" />
                                     </svg>
                                 )}
+                                {itemStatuses[item.id] === 'skipped' && (
+                                    <svg className="w-5 h-5 text-muted-foreground" fill="none" viewBox="0 0 24 24" stroke="currentColor" strokeWidth={2}>
+                                        <path strokeLinecap="round" strokeLinejoin="round" d="M8 16H6a2 2 0 01-2-2V6a2 2 0 012-2h8a2 2 0 012 2v2m-6 12h8a2 2 0 002-2v-8a2 2 0 00-2-2h-8a2 2 0 00-2 2v8a2 2 0 002 2z" />
+                                    </svg>
+                                )}
                             </div>
 
                             {/* Thumbnail */}
@@ -3,8 +3,9 @@
 import React from "react"
 
 import { useState, useCallback } from 'react';
-import type { ImageResult, FilterState } from '../lib/types';
-import { SearchImages } from '../../wailsjs/go/main/App';
+import type { ImageResult, FilterState, SearchFilterState } from '../lib/types';
+import { SearchImagesWithOptions } from '../../wailsjs/go/main/App';
+import { services } from '../../wailsjs/go/models';
 
 interface SearchPanelProps {
     onImageSelect: (image: ImageResult) => void;
@@ -25,6 +26,20 @@ const MOCK_IMAGES: ImageResult[] = Array.from({ length: 18 }, (_, i) => ({
     description: 'A beautiful high-resolution wallpaper perfect for your desktop.',
 }));
 
+// Values accepted by the Pixabay API for the provider-side filters
+const IMAGE_TYPES = [['all', 'Todos'], ['photo', 'Foto'], ['illustration', 'Ilustracao'], ['vector', 'Vetor']];
+const CATEGORIES = [
+    'backgrounds', 'nature', 'places', 'travel', 'buildings', 'animals', 'science', 'computer',
+    'food', 'sports', 'transportation', 'industry', 'music', 'fashion', 'people', 'feelings',
+    'business', 'education', 'health', 'religion',
+];
+const COLORS = [
+    'grayscale', 'transparent', 'red', 'orange', 'yellow', 'green', 'turquoise', 'blue',
+    'lilac', 'pink', 'white', 'gray', 'black', 'brown',
+];
+const SORTS = [['', 'Relevancia'], ['top', 'Populares'], ['new', 'Recentes']];
+const LANGS = ['pt', 'en', 'es', 'fr', 'de', 'it', 'ja', 'zh'];
+
 export default function SearchPanel({ onImageSelect, onAddToList }: SearchPanelProps) {
     const [query, setQuery] = useState('');
     const [results, setResults] = useState<ImageResult[]>(MOCK_IMAGES);
@@ -34,16 +49,34 @@ export default function SearchPanel({ onImageSelect, onAddToList }: SearchPanelP
         dimensions: '',
         type: '',
     });
+    const [searchFilters, setSearchFilters] = useState<SearchFilterState>({
+        imageType: 'photo',
+        category: '',
+        colors: '',
+        editorsChoice: false,
+        safeSearch: false,
+        sort: '',
+        lang: '',
+    });
     const [showFilters, setShowFilters] = useState(true);
 
-    const handleSearch = useCallback(async () => {
+    const runSearch = useCallback(async (providerFilters: SearchFilterState) => {
         if (!query.trim()) return;
 
         setIsSearching(true);
         try {
             // Use Wails backend for search
-            if (typeof SearchImages !== 'undefined') {
-                const images = await SearchImages(query, 1, 18);
+            if (typeof SearchImagesWithOptions !== 'undefined') {
+                const options = services.SearchOptions.createFrom({
+                    page: 1,
+                    perPage: 18,
+                    ...providerFilters,
+                });
+                const response = await SearchImagesWithOptions(query, options, ['all']);
+                for (const [provider, message] of Object.entries(response?.errors || {})) {
+                    console.warn(`Search failed for ${provider}:`, message);
+                }
+                const images = response?.results;
                 if (images && images.length > 0) {
                     setResults(images.map((img) => ({
                         id: img.id,
@@ -55,6 +88,9 @@ export default function SearchPanel({ onImageSelect, onAddToList }: SearchPanelP
                         author: img.author,
                         source: img.source,
                         tags: img.tags || [],
+                        authorURL: img.authorURL,
+                        license: img.license,
+                        licenseURL: img.licenseURL,
                         // Description field is not provided by backend ImageResult struct
                         description: '',
                     })));
@@ -75,6 +111,17 @@ export default function SearchPanel({ onImageSelect, onAddToList }: SearchPanelP
         }
     }, [query]);
 
+    const handleSearch = useCallback(() => {
+        runSearch(searchFilters);
+    }, [runSearch, searchFilters]);
+
+    // Provider filters change what the backend returns, so they re-run the search
+    const updateSearchFilters = useCallback((patch: Partial<SearchFilterState>) => {
+        const next = { ...searchFilters, ...patch };
+        setSearchFilters(next);
+        runSearch(next);
+    }, [runSearch, searchFilters]);
+
     const handleKeyDown = useCallback((e: React.KeyboardEvent) => {
         if (e.key === 'Enter') handleSearch();
     }, [handleSearch]);
@@ -216,6 +263,102 @@ export default function SearchPanel({ onImageSelect, onAddToList }: SearchPanelP
                                 ))}
                             </div>
                         </div>
+
+                        {/* Provider filters, applied by the backend */}
+                        <p className="text-sm font-semibold text-muted-foreground uppercase tracking-wider mb-4">Pixabay</p>
+
+                        <div className="mb-6">
+                            <p className="text-sm font-medium text-foreground mb-3">Imagem</p>
+                            <div className="flex flex-col gap-2">
+                                {IMAGE_TYPES.map(([value, label]) => (
+                                    <button
+                                        key={value}
+                                        onClick={() => updateSearchFilters({ imageType: value })}
+                                        className={`text-left text-sm px-3 py-2 rounded-lg transition-colors ${
+                                            searchFilters.imageType === value
+                                                ? 'bg-primary text-primary-foreground'
+                                                : 'text-muted-foreground hover:bg-muted hover:text-foreground'
+                                        }`}
+                                    >
+                                        {label}
+                                    </button>
+                                ))}
+                            </div>
+                        </div>
+
+                        <div className="mb-6">
+                            <p className="text-sm font-medium text-foreground mb-3">Ordem</p>
+                            <div className="flex flex-col gap-2">
+                                {SORTS.map(([value, label]) => (
+                                    <button
+                                        key={value}
+                                        onClick={() => updateSearchFilters({ sort: value })}
+                                        className={`text-left text-sm px-3 py-2 rounded-lg transition-colors ${
+                                            searchFilters.sort === value
+                                                ? 'bg-primary text-primary-foreground'
+                                                : 'text-muted-foreground hover:bg-muted hover:text-foreground'
+                                        }`}
+                                    >
+                                        {label}
+                                    </button>
+                                ))}
+                            </div>
+                        </div>
+
+                        <div className="mb-6 flex flex-col gap-3">
+                            <label className="text-sm font-medium text-foreground">
+                                Categoria
+                                <select
+                                    value={searchFilters.category}
+                                    onChange={(e) => updateSearchFilters({ category: e.target.value })}
+                                    className="mt-2 w-full bg-input text-sm text-foreground rounded-lg px-3 py-2 capitalize"
+                                >
+                                    <option value="">Todas</option>
+                                    {CATEGORIES.map(c => <option key={c} value={c}>{c}</option>)}
+                                </select>
+                            </label>
+                            <label className="text-sm font-medium text-foreground">
+                                Cor
+                                <select
+                                    value={searchFilters.colors}
+                                    onChange={(e) => updateSearchFilters({ colors: e.target.value })}
+                                    className="mt-2 w-full bg-input text-sm text-foreground rounded-lg px-3 py-2 capitalize"
+                                >
+                                    <option value="">Todas</option>
+                                    {COLORS.map(c => <option key={c} value={c}>{c}</option>)}
+                                </select>
+                            </label>
+                            <label className="text-sm font-medium text-foreground">
+                                Idioma da busca
+                                <select
+                                    value={searchFilters.lang}
+                                    onChange={(e) => updateSearchFilters({ lang: e.target.value })}
+                                    className="mt-2 w-full bg-input text-sm text-foreground rounded-lg px-3 py-2 uppercase"
+                                >
+                                    <option value="">Padrao</option>
+                                    {LANGS.map(l => <option key={l} value={l}>{l}</option>)}
+                                </select>
+                            </label>
+                        </div>
+
+                        <div className="mb-6 flex flex-col gap-2">
+                            <label className="flex items-center gap-2 text-sm text-muted-foreground">
+                                <input
+                                    type="checkbox"
+                                    checked={searchFilters.editorsChoice}
+                                    onChange={(e) => updateSearchFilters({ editorsChoice: e.target.checked })}
+                                />
+                                Escolha dos editores
+                            </label>
+                            <label className="flex items-center gap-2 text-sm text-muted-foreground">
+                                <input
+                                    type="checkbox"
+                                    checked={searchFilters.safeSearch}
+                                    onChange={(e) => updateSearchFilters({ safeSearch: e.target.checked })}
+                                />
+                                Busca segura
+                            </label>
+                        </div>
                     </div>
                 )}
 
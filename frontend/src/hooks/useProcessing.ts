@@ -2,7 +2,7 @@
 
 import { useState, useEffect, useRef, useCallback } from 'react';
 import { EventsOn } from '../../wailsjs/runtime/runtime';
-import type { DownloadItem } from '../lib/types';
+import type { DownloadItem, ImageResult } from '../lib/types';
 
 // ─── Backend types (mirrors Go structs) ─────────────────────────────
 
@@ -12,12 +12,56 @@ interface BatchItem {
     downloadURL: string;
     name: string;
     dimension: string;
+    previewURL?: string;
+    allowDuplicate?: boolean;
+    options?: ProcessingOptions;
+    output?: OutputFormat;  // overrides the job's format
+    image?: ImageResult;    // search result credited in an attribution sidecar
+    attribution?: Attribution;
+}
+
+interface ProcessingOptions {
+    useSeamCarving?: boolean;
+    protectMask?: string; // base64 image, white = keep
+    removeMask?: string;  // base64 image, white = carve away
+    fitMode?: "stretch" | "crop" | "smart" | "letterbox" | "blur" | "mirror";
+    fillColor?: string;   // letterbox colour, "#rrggbb"
+    // Normalised source coordinates, 0 to 1; applied before upscaling
+    crop?: { x: number; y: number; width: number; height: number };
+    focalPoint?: { x: number; y: number };
+    rotate?: 0 | 90 | 180 | 270; // clockwise
+    flipH?: boolean;
+    flipV?: boolean;
+}
+
+interface OutputFormat {
+    format?: "png" | "jpeg" | "tiff" | "bmp" | "webp";
+    pngCompression?: "default" | "none" | "fast" | "best";
+    quality?: number;       // JPEG quality (1-100)
+    progressive?: boolean;
+    subsampling?: "4:2:0" | "4:2:2" | "4:4:4";
+    tiffCompression?: "deflate" | "lzw" | "none";
+    maxBytes?: number;      // JPEG size limit; the quality is searched for
+}
+
+interface Attribution {
+    provider: string;
+    pageURL: string;
+    author: string;
+    authorURL?: string;
+    license: string;
+    licenseURL?: string;
+    sourceURL?: string;
 }
 
 interface BatchItemStatus {
     id: string;
-    status: 'pending' | 'processing' | 'done' | 'error';
+    status: 'pending' | 'processing' | 'done' | 'error' | 'skipped';
     error?: string;
+    duplicateOf?: string;
+    quality?: number; // JPEG quality used under a maxBytes limit
+    bytesReceived?: number;
+    bytesTotal?: number;
 }
 
 interface ProcessingStatus {
@@ -29,7 +73,7 @@ interface ProcessingStatus {
     done: boolean;
 }
 
-export type ItemStatusMap = Record<string, 'pending' | 'processing' | 'done' | 'error'>;
+export type ItemStatusMap = Record<string, 'pending' | 'processing' | 'done' | 'error' | 'skipped'>;
 
 export interface UseProcessingResult {
     progress: number;
@@ -44,8 +88,8 @@ function clamp(value: number, min: number, max: number): number {
     return Math.max(min, Math.min(max, value));
 }
 
-function isValidStatus(s: string): s is 'pending' | 'processing' | 'done' | 'error' {
-    return s === 'pending' || s === 'processing' || s === 'done' || s === 'error';
+function isValidStatus(s: string): s is 'pending' | 'processing' | 'done' | 'error' | 'skipped' {
+    return s === 'pending' || s === 'processing' || s === 'done' || s === 'error' || s === 'skipped';
 }
 
 export function useProcessing(): UseProcessingResult {
@@ -144,8 +188,11 @@ export function useProcessing(): UseProcessingResult {
                 id: item.id,
                 base64Data,
                 downloadURL,
+                previewURL: item.image.previewURL || undefined,
                 name,
                 dimension: item.dimension || '3840x2160',
+                // Credit the source in a sidecar next to the saved wallpaper
+                image: item.image,
             };
         });
 
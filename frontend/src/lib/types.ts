@@ -9,6 +9,9 @@ export interface ImageResult {
     source: string;
     tags: string[];
     description?: string;
+    authorURL?: string;
+    license?: string;
+    licenseURL?: string;
 }
 
 export interface DownloadItem {
@@ -26,3 +29,13 @@ export interface FilterState {
     dimensions: string;
     type: string;
 }
+
+export interface SearchFilterState {
+    imageType: string;
+    category: string;
+    colors: string;
+    editorsChoice: boolean;
+    safeSearch: boolean;
+    sort: string;
+    lang: string;
+}
@@ -1,6 +1,8 @@
 // Global type declarations for Wails Go bindings
 // This file provides types for window.go.main.App.* methods
 
+import type { ImageResult } from './types';
+
 export {};
 
 export interface BatchItem {
@@ -9,12 +11,56 @@ export interface BatchItem {
     downloadURL: string;
     name: string;
     dimension: string;
+    previewURL?: string;
+    allowDuplicate?: boolean;
+    options?: ProcessingOptions;
+    output?: OutputFormat;  // overrides the job's format
+    image?: ImageResult;    // search result credited in an attribution sidecar
+    attribution?: Attribution;
+}
+
+export interface ProcessingOptions {
+    useSeamCarving?: boolean;
+    protectMask?: string; // base64 image, white = keep
+    removeMask?: string;  // base64 image, white = carve away
+    fitMode?: "stretch" | "crop" | "smart" | "letterbox" | "blur" | "mirror";
+    fillColor?: string;   // letterbox colour, "#rrggbb"
+    // Normalised source coordinates, 0 to 1; applied before upscaling
+    crop?: { x: number; y: number; width: number; height: number };
+    focalPoint?: { x: number; y: number };
+    rotate?: 0 | 90 | 180 | 270; // clockwise
+    flipH?: boolean;
+    flipV?: boolean;
+}
+
+export interface OutputFormat {
+    format?: "png" | "jpeg" | "tiff" | "bmp" | "webp";
+    pngCompression?: "default" | "none" | "fast" | "best";
+    quality?: number;       // JPEG quality (1-100)
+    progressive?: boolean;
+    subsampling?: "4:2:0" | "4:2:2" | "4:4:4";
+    tiffCompression?: "deflate" | "lzw" | "none";
+    maxBytes?: number;      // JPEG size limit; the quality is searched for
+}
+
+export interface Attribution {
+    provider: string;
+    pageURL: string;
+    author: string;
+    authorURL?: string;
+    license: string;
+    licenseURL?: string;
+    sourceURL?: string;
 }
 
 export interface BatchItemStatus {
     id: string;
-    status: 'pending' | 'processing' | 'done' | 'error';
+    status: 'pending' | 'processing' | 'done' | 'error' | 'skipped';
     error?: string;
+    duplicateOf?: string;
+    quality?: number; // JPEG quality used under a maxBytes limit
+    bytesReceived?: number;
+    bytesTotal?: number;
 }
 
 export interface ProcessingStatus {
@@ -35,10 +81,14 @@ declare global {
                     DownloadImage?: (url: string) => Promise<string>;
                     SelectDirectory?: () => Promise<string>;
                     GetDefaultSavePath?: () => Promise<string>;
-                    SearchImages?: (query: string, page: number, perPage: number) => Promise<unknown[]>;
+                    SearchImages?: (query: string, page: number, perPage: number, providers: string[]) => Promise<{ results: unknown[]; errors: Record<string, string> }>;
+                    SearchImagesWithOptions?: (query: string, options: Record<string, unknown>, providers: string[]) => Promise<{ results: unknown[]; errors: Record<string, string> }>;
+                    ListProviders?: () => Promise<unknown[]>;
+                    GetImageTags?: (provider: string, id: string) => Promise<string[] | null>;
                     UpscaleImage?: (base64Data: string, imageType: string, scale: number) => Promise<string>;
                     Greet?: (name: string) => Promise<string>;
                     ProcessBatch?: (items: BatchItem[], savePath: string) => Promise<void>;
+                    ProcessBatchWithOutput?: (items: BatchItem[], savePath: string, output: OutputFormat) => Promise<void>;
                     GetProcessingStatus?: () => Promise<ProcessingStatus>;
                 };
             };
@@ -3,20 +3,58 @@
 import {main} from '../models';
 import {services} from '../models';
 
+export function ClearCache():Promise<void>;
+
 export function DownloadImage(arg1:string):Promise<string>;
 
+export function FindDuplicates(arg1:string):Promise<Array<services.DuplicateGroup>>;
+
+export function GetAttribution(arg1:string):Promise<services.Attribution>;
+
 export function GetDefaultSavePath():Promise<string>;
 
+export function GetDownloadAllowlist():Promise<Array<string>>;
+
+export function GetImageTags(arg1:string,arg2:string):Promise<Array<string>>;
+
+export function GetNetworkSettings():Promise<services.NetworkSettings>;
+
+export function GetPictureOfTheDay(arg1:string):Promise<services.ImageResult>;
+
+export function GetPreviewImage(arg1:string):Promise<string>;
+
 export function GetProcessingStatus():Promise<main.ProcessingStatus>;
 
 export function Greet(arg1:string):Promise<string>;
 
+export function ListProviderKeys():Promise<Array<services.ProviderKeyInfo>>;
+
+export function ListProviders():Promise<Array<services.ProviderStatus>>;
+
 export function ProcessBatch(arg1:Array<main.BatchItem>,arg2:string):Promise<void>;
 
+export function ProcessBatchWithOutput(arg1:Array<main.BatchItem>,arg2:string,arg3:services.OutputFormat):Promise<void>;
+
 export function ProcessImage(arg1:string,arg2:number,arg3:number,arg4:string,arg5:string):Promise<string>;
 
-export function SearchImages(arg1:string,arg2:number,arg3:number):Promise<Array<services.ImageResult>>;
+export function ProcessImageWithOptions(arg1:string,arg2:number,arg3:number,arg4:services.ProcessingOptions,arg5:string,arg6:string):Promise<string>;
+
+export function SearchBySimilarImage(arg1:string):Promise<Array<services.SimilarityMatch>>;
+
+export function SearchImages(arg1:string,arg2:number,arg3:number,arg4:Array<string>):Promise<services.FanOutResult>;
+
+export function SearchImagesPaged(arg1:string,arg2:number,arg3:number,arg4:string):Promise<services.SearchPage>;
+
+export function SearchImagesWithOptions(arg1:string,arg2:services.SearchOptions,arg3:Array<string>):Promise<services.FanOutResult>;
 
 export function SelectDirectory():Promise<string>;
 
+export function SetDownloadAllowlist(arg1:Array<string>):Promise<void>;
+
+export function SetNetworkSettings(arg1:services.NetworkSettings):Promise<void>;
+
+export function SetProviderKey(arg1:string,arg2:string):Promise<void>;
+
+export function TestProviderKey(arg1:string,arg2:string):Promise<services.KeyCheckResult>;
+
 export function UpscaleImage(arg1:string,arg2:string,arg3:number):Promise<string>;
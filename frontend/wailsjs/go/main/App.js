@@ -2,14 +2,46 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function ClearCache() {
+  return window['go']['main']['App']['ClearCache']();
+}
+
 export function DownloadImage(arg1) {
   return window['go']['main']['App']['DownloadImage'](arg1);
 }
 
+export function FindDuplicates(arg1) {
+  return window['go']['main']['App']['FindDuplicates'](arg1);
+}
+
+export function GetAttribution(arg1) {
+  return window['go']['main']['App']['GetAttribution'](arg1);
+}
+
 export function GetDefaultSavePath() {
   return window['go']['main']['App']['GetDefaultSavePath']();
 }
 
+export function GetDownloadAllowlist() {
+  return window['go']['main']['App']['GetDownloadAllowlist']();
+}
+
+export function GetImageTags(arg1, arg2) {
+  return window['go']['main']['App']['GetImageTags'](arg1, arg2);
+}
+
+export function GetNetworkSettings() {
+  return window['go']['main']['App']['GetNetworkSettings']();
+}
+
+export function GetPictureOfTheDay(arg1) {
+  return window['go']['main']['App']['GetPictureOfTheDay'](arg1);
+}
+
+export function GetPreviewImage(arg1) {
+  return window['go']['main']['App']['GetPreviewImage'](arg1);
+}
+
 export function GetProcessingStatus() {
   return window['go']['main']['App']['GetProcessingStatus']();
 }
@@ -18,22 +50,66 @@ export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
+export function ListProviderKeys() {
+  return window['go']['main']['App']['ListProviderKeys']();
+}
+
+export function ListProviders() {
+  return window['go']['main']['App']['ListProviders']();
+}
+
 export function ProcessBatch(arg1, arg2) {
   return window['go']['main']['App']['ProcessBatch'](arg1, arg2);
 }
 
+export function ProcessBatchWithOutput(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ProcessBatchWithOutput'](arg1, arg2, arg3);
+}
+
 export function ProcessImage(arg1, arg2, arg3, arg4, arg5) {
   return window['go']['main']['App']['ProcessImage'](arg1, arg2, arg3, arg4, arg5);
 }
 
-export function SearchImages(arg1, arg2, arg3) {
-  return window['go']['main']['App']['SearchImages'](arg1, arg2, arg3);
+export function ProcessImageWithOptions(arg1, arg2, arg3, arg4, arg5, arg6) {
+  return window['go']['main']['App']['ProcessImageWithOptions'](arg1, arg2, arg3, arg4, arg5, arg6);
+}
+
+export function SearchBySimilarImage(arg1) {
+  return window['go']['main']['App']['SearchBySimilarImage'](arg1);
+}
+
+export function SearchImages(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['SearchImages'](arg1, arg2, arg3, arg4);
+}
+
+export function SearchImagesPaged(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['SearchImagesPaged'](arg1, arg2, arg3, arg4);
+}
+
+export function SearchImagesWithOptions(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SearchImagesWithOptions'](arg1, arg2, arg3);
 }
 
 export function SelectDirectory() {
   return window['go']['main']['App']['SelectDirectory']();
 }
 
+export function SetDownloadAllowlist(arg1) {
+  return window['go']['main']['App']['SetDownloadAllowlist'](arg1);
+}
+
+export function SetNetworkSettings(arg1) {
+  return window['go']['main']['App']['SetNetworkSettings'](arg1);
+}
+
+export function SetProviderKey(arg1, arg2) {
+  return window['go']['main']['App']['SetProviderKey'](arg1, arg2);
+}
+
+export function TestProviderKey(arg1, arg2) {
+  return window['go']['main']['App']['TestProviderKey'](arg1, arg2);
+}
+
 export function UpscaleImage(arg1, arg2, arg3) {
   return window['go']['main']['App']['UpscaleImage'](arg1, arg2, arg3);
 }
@@ -6,6 +6,12 @@ export namespace main {
 	    downloadURL: string;
 	    name: string;
 	    dimension: string;
+	    previewURL?: string;
+	    allowDuplicate?: boolean;
+	    options?: services.ProcessingOptions;
+	    output?: services.OutputFormat;
+	    image?: services.ImageResult;
+	    attribution?: services.Attribution;
 	
 	    static createFrom(source: any = {}) {
 	        return new BatchItem(source);
@@ -18,12 +24,40 @@ export namespace main {
 	        this.downloadURL = source["downloadURL"];
 	        this.name = source["name"];
 	        this.dimension = source["dimension"];
+	        this.previewURL = source["previewURL"];
+	        this.allowDuplicate = source["allowDuplicate"];
+	        this.options = this.convertValues(source["options"], services.ProcessingOptions);
+	        this.output = this.convertValues(source["output"], services.OutputFormat);
+	        this.image = this.convertValues(source["image"], services.ImageResult);
+	        this.attribution = this.convertValues(source["attribution"], services.Attribution);
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class BatchItemStatus {
 	    id: string;
 	    status: string;
 	    error?: string;
+	    duplicateOf?: string;
+	    quality?: number;
+	    bytesReceived?: number;
+	    bytesTotal?: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new BatchItemStatus(source);
@@ -34,6 +68,10 @@ export namespace main {
 	        this.id = source["id"];
 	        this.status = source["status"];
 	        this.error = source["error"];
+	        this.duplicateOf = source["duplicateOf"];
+	        this.quality = source["quality"];
+	        this.bytesReceived = source["bytesReceived"];
+	        this.bytesTotal = source["bytesTotal"];
 	    }
 	}
 	export class ProcessingStatus {
@@ -81,6 +119,167 @@ export namespace main {
 
 export namespace services {
 	
+	export class Attribution {
+	    provider: string;
+	    pageURL: string;
+	    author: string;
+	    authorURL?: string;
+	    license: string;
+	    licenseURL?: string;
+	    sourceURL?: string;
+	    // Go type: time
+	    savedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Attribution(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.provider = source["provider"];
+	        this.pageURL = source["pageURL"];
+	        this.author = source["author"];
+	        this.authorURL = source["authorURL"];
+	        this.license = source["license"];
+	        this.licenseURL = source["licenseURL"];
+	        this.sourceURL = source["sourceURL"];
+	        this.savedAt = this.convertValues(source["savedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CropRect {
+	    x: number;
+	    y: number;
+	    width: number;
+	    height: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CropRect(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.x = source["x"];
+	        this.y = source["y"];
+	        this.width = source["width"];
+	        this.height = source["height"];
+	    }
+	}
+	export class DuplicateGroup {
+	    files: FileHash[];
+	
+	    static createFrom(source: any = {}) {
+	        return new DuplicateGroup(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = this.convertValues(source["files"], FileHash);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FanOutResult {
+	    results: ImageResult[];
+	    errors: Record<string, string>;
+	    total: number;
+	    hasMore: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new FanOutResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.results = this.convertValues(source["results"], ImageResult);
+	        this.errors = source["errors"];
+	        this.total = source["total"];
+	        this.hasMore = source["hasMore"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FileHash {
+	    path: string;
+	    width: number;
+	    height: number;
+	    size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileHash(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.width = source["width"];
+	        this.height = source["height"];
+	        this.size = source["size"];
+	    }
+	}
+	export class FocalPoint {
+	    x: number;
+	    y: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FocalPoint(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.x = source["x"];
+	        this.y = source["y"];
+	    }
+	}
 	export class ImageResult {
 	    id: string;
 	    url: string;
@@ -91,6 +290,9 @@ export namespace services {
 	    author: string;
 	    source: string;
 	    tags: string[];
+	    license: string;
+	    licenseURL: string;
+	    authorURL?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new ImageResult(source);
@@ -107,8 +309,366 @@ export namespace services {
 	        this.author = source["author"];
 	        this.source = source["source"];
 	        this.tags = source["tags"];
+	        this.license = source["license"];
+	        this.licenseURL = source["licenseURL"];
+	        this.authorURL = source["authorURL"];
 	    }
 	}
+	export class KeyCheckResult {
+	    provider: string;
+	    valid: boolean;
+	    error?: string;
+	    rateLimit?: RateLimitState;
+	
+	    static createFrom(source: any = {}) {
+	        return new KeyCheckResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.provider = source["provider"];
+	        this.valid = source["valid"];
+	        this.error = source["error"];
+	        this.rateLimit = this.convertValues(source["rateLimit"], RateLimitState);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class NetworkSettings {
+	    proxyURL: string;
+	    caBundle: string;
+	    userAgent: string;
+	    maxConnsPerHost: number;
+	    maxIdleConns: number;
+	    requestTimeoutSeconds: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new NetworkSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.proxyURL = source["proxyURL"];
+	        this.caBundle = source["caBundle"];
+	        this.userAgent = source["userAgent"];
+	        this.maxConnsPerHost = source["maxConnsPerHost"];
+	        this.maxIdleConns = source["maxIdleConns"];
+	        this.requestTimeoutSeconds = source["requestTimeoutSeconds"];
+	    }
+	}
+	export class OutputFormat {
+	    format?: string;
+	    pngCompression?: string;
+	    quality?: number;
+	    progressive?: boolean;
+	    subsampling?: string;
+	    tiffCompression?: string;
+	    maxBytes?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new OutputFormat(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.format = source["format"];
+	        this.pngCompression = source["pngCompression"];
+	        this.quality = source["quality"];
+	        this.progressive = source["progressive"];
+	        this.subsampling = source["subsampling"];
+	        this.tiffCompression = source["tiffCompression"];
+	        this.maxBytes = source["maxBytes"];
+	    }
+	}
+	export class ProcessingOptions {
+	    targetResolution?: string;
+	    aspectRatio?: string;
+	    useSeamCarving?: boolean;
+	    quality?: number;
+	    protectMask?: string;
+	    removeMask?: string;
+	    fitMode?: string;
+	    fillColor?: string;
+	    crop?: CropRect;
+	    focalPoint?: FocalPoint;
+	    rotate?: number;
+	    flipH?: boolean;
+	    flipV?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProcessingOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.targetResolution = source["targetResolution"];
+	        this.aspectRatio = source["aspectRatio"];
+	        this.useSeamCarving = source["useSeamCarving"];
+	        this.quality = source["quality"];
+	        this.protectMask = source["protectMask"];
+	        this.removeMask = source["removeMask"];
+	        this.fitMode = source["fitMode"];
+	        this.fillColor = source["fillColor"];
+	        this.crop = this.convertValues(source["crop"], CropRect);
+	        this.focalPoint = this.convertValues(source["focalPoint"], FocalPoint);
+	        this.rotate = source["rotate"];
+	        this.flipH = source["flipH"];
+	        this.flipV = source["flipV"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProviderKeyInfo {
+	    provider: string;
+	    envVar: string;
+	    configured: boolean;
+	    source?: string;
+	    hint?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProviderKeyInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.provider = source["provider"];
+	        this.envVar = source["envVar"];
+	        this.configured = source["configured"];
+	        this.source = source["source"];
+	        this.hint = source["hint"];
+	    }
+	}
+	export class ProviderStatus {
+	    name: string;
+	    configured: boolean;
+	    healthy: boolean;
+	    lastError?: string;
+	    // Go type: time
+	    lastChecked: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProviderStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.configured = source["configured"];
+	        this.healthy = source["healthy"];
+	        this.lastError = source["lastError"];
+	        this.lastChecked = this.convertValues(source["lastChecked"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RateLimitState {
+	    limit: number;
+	    remaining: number;
+	    // Go type: time
+	    reset: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new RateLimitState(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.limit = source["limit"];
+	        this.remaining = source["remaining"];
+	        this.reset = this.convertValues(source["reset"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SearchOptions {
+	    page: number;
+	    perPage: number;
+	    category: string;
+	    orientation: string;
+	    minWidth: number;
+	    minHeight: number;
+	    categories: string;
+	    purity: string;
+	    atLeast: string;
+	    ratios: string;
+	    sort: string;
+	    timeWindow: string;
+	    imageType: string;
+	    colors: string;
+	    editorsChoice: boolean;
+	    safeSearch: boolean;
+	    lang: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.page = source["page"];
+	        this.perPage = source["perPage"];
+	        this.category = source["category"];
+	        this.orientation = source["orientation"];
+	        this.minWidth = source["minWidth"];
+	        this.minHeight = source["minHeight"];
+	        this.categories = source["categories"];
+	        this.purity = source["purity"];
+	        this.atLeast = source["atLeast"];
+	        this.ratios = source["ratios"];
+	        this.sort = source["sort"];
+	        this.timeWindow = source["timeWindow"];
+	        this.imageType = source["imageType"];
+	        this.colors = source["colors"];
+	        this.editorsChoice = source["editorsChoice"];
+	        this.safeSearch = source["safeSearch"];
+	        this.lang = source["lang"];
+	    }
+	}
+	export class SearchPage {
+	    results: ImageResult[];
+	    page: number;
+	    perPage: number;
+	    total: number;
+	    totalHits: number;
+	    hasMore: boolean;
+	    rateLimit?: RateLimitState;
+	    cached: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.results = this.convertValues(source["results"], ImageResult);
+	        this.page = source["page"];
+	        this.perPage = source["perPage"];
+	        this.total = source["total"];
+	        this.totalHits = source["totalHits"];
+	        this.hasMore = source["hasMore"];
+	        this.rateLimit = this.convertValues(source["rateLimit"], RateLimitState);
+	        this.cached = source["cached"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SimilarityMatch {
+	    path?: string;
+	    image?: ImageResult;
+	    width: number;
+	    height: number;
+	    score: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SimilarityMatch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.image = this.convertValues(source["image"], ImageResult);
+	        this.width = source["width"];
+	        this.height = source["height"];
+	        this.score = source["score"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 
 }
-
@@ -5,6 +5,7 @@ go 1.25.7
 require (
 	github.com/Molasses-Co/SweetDesk-core v0.0.11
 	github.com/wailsapp/wails/v2 v2.11.0
+	golang.org/x/image v0.36.0
 )
 
 require (
@@ -42,7 +43,6 @@ require (
 	github.com/wailsapp/go-webview2 v1.0.22 // indirect
 	github.com/wailsapp/mimetype v1.4.1 // indirect
 	golang.org/x/crypto v0.33.0 // indirect
-	golang.org/x/image v0.36.0 // indirect
 	golang.org/x/net v0.35.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.34.0 // indirect
@@ -7,16 +7,44 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // APIProvider defines the interface for image API providers
 type APIProvider interface {
-	Search(query string, options SearchOptions) ([]ImageResult, error)
+	Search(query string, options SearchOptions) (*SearchPage, error)
 	Download(imageURL string) ([]byte, error)
 	GetName() string
 }
 
+// URLMatcher is implemented by providers that can recognise the URLs they
+// serve images from, so downloads are routed back to the right provider
+type URLMatcher interface {
+	MatchesURL(u *url.URL) bool
+}
+
+// ContextSearcher is implemented by providers whose searches can be
+// cancelled, so a fan-out search that times out also stops their requests
+type ContextSearcher interface {
+	SearchContext(ctx context.Context, query string, options SearchOptions) (*SearchPage, error)
+}
+
+// TagProvider is implemented by providers whose search results leave out
+// tags that a request for the image's details returns
+type TagProvider interface {
+	Tags(id string) ([]string, error)
+}
+
+// searchContext searches p, cancelling with ctx when p supports it
+func searchContext(ctx context.Context, p APIProvider, query string, options SearchOptions) (*SearchPage, error) {
+	if s, ok := p.(ContextSearcher); ok {
+		return s.SearchContext(ctx, query, options)
+	}
+	return p.Search(query, options)
+}
+
 // SearchOptions contains search parameters
 type SearchOptions struct {
 	Page        int    `json:"page"`
@@ -25,6 +53,19 @@ type SearchOptions struct {
 	Orientation string `json:"orientation"` // "horizontal", "vertical", "all"
 	MinWidth    int    `json:"minWidth"`
 	MinHeight   int    `json:"minHeight"`
+	Categories  string `json:"categories"` // Wallhaven: "general,anime,people" or flags like "110"
+	Purity      string `json:"purity"`     // Wallhaven: "sfw,sketchy,nsfw" or flags; defaults to SFW only
+	AtLeast     string `json:"atLeast"`    // minimum resolution, e.g. "2560x1440"
+	Ratios      string `json:"ratios"`     // comma-separated ratios, e.g. "16x9,21x9"
+	Sort        string `json:"sort"`       // listing order: "hot", "top", "new", "relevance"; Pixabay: "popular", "latest"
+	TimeWindow  string `json:"timeWindow"` // "hour", "day", "week", "month", "year", "all"
+
+	// Pixabay filters
+	ImageType     string `json:"imageType"`     // "photo" (default), "illustration", "vector", "all"
+	Colors        string `json:"colors"`        // comma-separated, e.g. "grayscale,blue"
+	EditorsChoice bool   `json:"editorsChoice"` // only Editor's Choice images
+	SafeSearch    bool   `json:"safeSearch"`    // only images suitable for all ages
+	Lang          string `json:"lang"`          // query language code, e.g. "pt"
 }
 
 // ImageResult represents a single image result
@@ -38,70 +79,88 @@ type ImageResult struct {
 	Author      string   `json:"author"`
 	Source      string   `json:"source"`
 	Tags        []string `json:"tags"`
+	License     string   `json:"license"`
+	LicenseURL  string   `json:"licenseURL"`
+	AuthorURL   string   `json:"authorURL,omitempty"` // the author's profile page
+}
+
+// SearchPage is one page of search results along with what the provider
+// reported about the rest of the result set
+type SearchPage struct {
+	Results   []ImageResult   `json:"results"`
+	Page      int             `json:"page"`
+	PerPage   int             `json:"perPage"`
+	Total     int             `json:"total"`     // total matches, 0 if the provider does not report it
+	TotalHits int             `json:"totalHits"` // matches reachable through the API, which may be capped below Total
+	HasMore   bool            `json:"hasMore"`
+	RateLimit *RateLimitState `json:"rateLimit,omitempty"`
+	Cached    bool            `json:"cached"` // served from the on-disk cache
+}
+
+// RateLimitState is the request quota reported in a provider's response headers
+type RateLimitState struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"` // zero if the provider does not report it
 }
 
+// pixabayBaseURL is the Pixabay API endpoint
+const pixabayBaseURL = "https://pixabay.com/api"
+
 // PixabayProvider implements APIProvider for Pixabay
 type PixabayProvider struct {
-	apiKey string
-	client *http.Client
-	ctx    context.Context
+	apiKey  string
+	baseURL string
+	client  *ProviderClient
+	ctx     context.Context
 }
 
 // NewPixabayProvider creates a new Pixabay provider
 func NewPixabayProvider(ctx context.Context, apiKey string) *PixabayProvider {
 	return &PixabayProvider{
-		apiKey: apiKey,
-		client: &http.Client{Timeout: 30 * time.Second},
-		ctx:    ctx,
+		apiKey:  apiKey,
+		baseURL: pixabayBaseURL,
+		client:  NewProviderClient("Pixabay", 30*time.Second),
+		ctx:     ctx,
 	}
 }
 
+// SetBaseURL points the provider at another API endpoint, such as a mirror
+// or a local test server
+func (p *PixabayProvider) SetBaseURL(baseURL string) {
+	p.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
 // GetName returns the provider name
 func (p *PixabayProvider) GetName() string {
 	return "Pixabay"
 }
 
 // Search searches for images on Pixabay
-func (p *PixabayProvider) Search(query string, options SearchOptions) ([]ImageResult, error) {
-	baseURL := "https://pixabay.com/api/"
-	
-	params := url.Values{}
-	params.Set("key", p.apiKey)
-	params.Set("q", query)
-	params.Set("image_type", "photo")
-	params.Set("page", fmt.Sprintf("%d", options.Page))
-	params.Set("per_page", fmt.Sprintf("%d", options.PerPage))
-	
-	if options.MinWidth > 0 {
-		params.Set("min_width", fmt.Sprintf("%d", options.MinWidth))
-	}
-	if options.MinHeight > 0 {
-		params.Set("min_height", fmt.Sprintf("%d", options.MinHeight))
-	}
-	if options.Orientation != "" && options.Orientation != "all" {
-		params.Set("orientation", options.Orientation)
-	}
-	
-	fullURL := baseURL + "?" + params.Encode()
+func (p *PixabayProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	return p.SearchContext(p.ctx, query, options)
+}
+
+// SearchContext is Search, cancelled with ctx
+func (p *PixabayProvider) SearchContext(ctx context.Context, query string, options SearchOptions) (*SearchPage, error) {
+	params := pixabayParams(p.apiKey, query, options)
+	fullURL := p.baseURL + "/?" + params.Encode()
 	
-	req, err := http.NewRequestWithContext(p.ctx, "GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
-	}
-	
 	var result struct {
-		Total int `json:"total"`
-		Hits  []struct {
+		Total     int `json:"total"`
+		TotalHits int `json:"totalHits"`
+		Hits      []struct {
 			ID            int    `json:"id"`
 			PageURL       string `json:"pageURL"`
 			PreviewURL    string `json:"previewURL"`
@@ -109,6 +168,7 @@ func (p *PixabayProvider) Search(query string, options SearchOptions) ([]ImageRe
 			ImageWidth    int    `json:"imageWidth"`
 			ImageHeight   int    `json:"imageHeight"`
 			User          string `json:"user"`
+			UserID        int    `json:"user_id"`
 			Tags          string `json:"tags"`
 		} `json:"hits"`
 	}
@@ -127,12 +187,83 @@ func (p *PixabayProvider) Search(query string, options SearchOptions) ([]ImageRe
 			Width:       hit.ImageWidth,
 			Height:      hit.ImageHeight,
 			Author:      hit.User,
+			AuthorURL:   fmt.Sprintf("https://pixabay.com/users/%s-%d/", url.PathEscape(hit.User), hit.UserID),
 			Source:      "Pixabay",
 			Tags:        parseTags(hit.Tags),
+			License:     "Pixabay Content License",
+			LicenseURL:  "https://pixabay.com/service/license-summary/",
 		})
 	}
-	
-	return images, nil
+
+	page, perPage := pageBounds(options, 20)
+	return &SearchPage{
+		Results:   images,
+		Page:      page,
+		PerPage:   perPage,
+		Total:     result.Total,
+		TotalHits: result.TotalHits,
+		HasMore:   page*perPage < result.TotalHits,
+		RateLimit: parseRateLimit(resp.Header),
+	}, nil
+}
+
+// pixabayParams builds the query string for a Pixabay search
+func pixabayParams(apiKey string, query string, options SearchOptions) url.Values {
+	params := url.Values{}
+	params.Set("key", apiKey)
+	params.Set("q", query)
+	params.Set("page", fmt.Sprintf("%d", options.Page))
+	params.Set("per_page", fmt.Sprintf("%d", options.PerPage))
+
+	imageType := options.ImageType
+	switch imageType {
+	case "photo", "illustration", "vector", "all":
+	default:
+		imageType = "photo"
+	}
+	params.Set("image_type", imageType)
+
+	if options.MinWidth > 0 {
+		params.Set("min_width", fmt.Sprintf("%d", options.MinWidth))
+	}
+	if options.MinHeight > 0 {
+		params.Set("min_height", fmt.Sprintf("%d", options.MinHeight))
+	}
+	if options.Orientation != "" && options.Orientation != "all" {
+		params.Set("orientation", options.Orientation)
+	}
+	if options.Category != "" {
+		params.Set("category", strings.ToLower(options.Category))
+	}
+	if colors := strings.ReplaceAll(strings.ToLower(options.Colors), " ", ""); colors != "" {
+		params.Set("colors", colors)
+	}
+	if options.EditorsChoice {
+		params.Set("editors_choice", "true")
+	}
+	if options.SafeSearch {
+		params.Set("safesearch", "true")
+	}
+	switch options.Sort {
+	case "latest", "new":
+		params.Set("order", "latest")
+	case "popular", "top":
+		params.Set("order", "popular")
+	}
+	if options.Lang != "" {
+		params.Set("lang", strings.ToLower(options.Lang))
+	}
+	return params
+}
+
+// MatchesURL reports whether u is served by Pixabay
+func (p *PixabayProvider) MatchesURL(u *url.URL) bool {
+	return hostMatches(u, "pixabay.com")
+}
+
+// DownloadFile streams an image to a staging file
+func (p *PixabayProvider) DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error) {
+	return d.Fetch(p.ctx, p.client, imageURL, nil, progress)
 }
 
 // Download downloads an image from URL
@@ -148,10 +279,6 @@ func (p *PixabayProvider) Download(imageURL string) ([]byte, error) {
 	}
 	defer resp.Body.Close()
 	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("download returned status %d", resp.StatusCode)
-	}
-	
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read image data: %w", err)
@@ -160,6 +287,63 @@ func (p *PixabayProvider) Download(imageURL string) ([]byte, error) {
 	return data, nil
 }
 
+// hostMatches reports whether u is an http(s) URL on one of the given
+// domains or their subdomains
+func hostMatches(u *url.URL, domains ...string) bool {
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRateLimit reads the X-RateLimit-* headers used by Pixabay, Unsplash,
+// Pexels and Reddit. It returns nil when the response carries no quota.
+func parseRateLimit(h http.Header) *RateLimitState {
+	number := func(name string) (int, bool) {
+		// Reddit reports fractional values such as "598.0"
+		f, err := strconv.ParseFloat(h.Get(name), 64)
+		return int(f), err == nil
+	}
+
+	remaining, ok := number("X-Ratelimit-Remaining")
+	if !ok {
+		return nil
+	}
+	state := &RateLimitState{Remaining: remaining}
+	if limit, ok := number("X-Ratelimit-Limit"); ok {
+		state.Limit = limit
+	} else if used, ok := number("X-Ratelimit-Used"); ok {
+		state.Limit = used + remaining
+	}
+	if reset, ok := number("X-Ratelimit-Reset"); ok {
+		// Pexels sends a UNIX timestamp, the others the seconds left in the window
+		if reset > 1_000_000_000 {
+			state.Reset = time.Unix(int64(reset), 0)
+		} else {
+			state.Reset = time.Now().Add(time.Duration(reset) * time.Second)
+		}
+	}
+	return state
+}
+
+// pageBounds normalises the requested page and page size
+func pageBounds(options SearchOptions, defaultPerPage int) (int, int) {
+	page, perPage := options.Page, options.PerPage
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultPerPage
+	}
+	return page, perPage
+}
+
 // parseTags splits comma-separated tags into a slice
 func parseTags(tags string) []string {
 	if tags == "" {
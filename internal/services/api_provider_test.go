@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	if state := parseRateLimit(http.Header{}); state != nil {
+		t.Errorf("expected nil without headers, got %+v", state)
+	}
+
+	// Pixabay reports the seconds left in the current window
+	h := http.Header{}
+	h.Set("X-RateLimit-Limit", "100")
+	h.Set("X-RateLimit-Remaining", "42")
+	h.Set("X-RateLimit-Reset", "60")
+	state := parseRateLimit(h)
+	if state == nil || state.Limit != 100 || state.Remaining != 42 {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if until := time.Until(state.Reset); until < 55*time.Second || until > 61*time.Second {
+		t.Errorf("reset in %s, want ~60s", until)
+	}
+
+	// Pexels reports a UNIX timestamp
+	h.Set("X-RateLimit-Reset", "1893456000")
+	if state := parseRateLimit(h); !state.Reset.Equal(time.Unix(1893456000, 0)) {
+		t.Errorf("reset = %s", state.Reset)
+	}
+}
+
+func TestPixabayParams(t *testing.T) {
+	params := pixabayParams("key", "anime", SearchOptions{
+		Page:          2,
+		PerPage:       50,
+		ImageType:     "illustration",
+		Category:      "Backgrounds",
+		Colors:        "blue, turquoise",
+		EditorsChoice: true,
+		SafeSearch:    true,
+		Sort:          "latest",
+		Lang:          "PT",
+	})
+	want := map[string]string{
+		"image_type":     "illustration",
+		"category":       "backgrounds",
+		"colors":         "blue,turquoise",
+		"editors_choice": "true",
+		"safesearch":     "true",
+		"order":          "latest",
+		"lang":           "pt",
+		"page":           "2",
+		"per_page":       "50",
+	}
+	for key, value := range want {
+		if got := params.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+
+	// Defaults keep the previous photo-only search without extra filters
+	params = pixabayParams("key", "sea", SearchOptions{ImageType: "gif"})
+	if params.Get("image_type") != "photo" || params.Has("safesearch") || params.Has("order") {
+		t.Errorf("unexpected defaults %v", params)
+	}
+}
+
+func TestPixabayAuthorURLEscapesUser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"total":1,"totalHits":1,"hits":[{"id":1,"user_id":42,"user":"Jane Doe/Art?"}]}`)
+	}))
+	defer server.Close()
+	p := NewPixabayProvider(context.Background(), "test-key")
+	p.SetBaseURL(server.URL)
+
+	page, err := p.Search("sea", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "https://pixabay.com/users/Jane%20Doe%2FArt%3F-42/"
+	if len(page.Results) != 1 || page.Results[0].AuthorURL != want {
+		t.Errorf("AuthorURL = %+v, want %s", page.Results, want)
+	}
+}
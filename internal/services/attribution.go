@@ -0,0 +1,72 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Attribution records where a saved wallpaper came from so its author can
+// be credited
+type Attribution struct {
+	Provider   string    `json:"provider"`
+	PageURL    string    `json:"pageURL"`
+	Author     string    `json:"author"`
+	AuthorURL  string    `json:"authorURL,omitempty"`
+	License    string    `json:"license"`
+	LicenseURL string    `json:"licenseURL,omitempty"`
+	SourceURL  string    `json:"sourceURL,omitempty"` // the image that was downloaded
+	SavedAt    time.Time `json:"savedAt"`
+}
+
+// AttributionFromResult copies the credit fields of a search result
+func AttributionFromResult(r ImageResult) Attribution {
+	return Attribution{
+		Provider:   r.Source,
+		PageURL:    r.URL,
+		Author:     r.Author,
+		AuthorURL:  r.AuthorURL,
+		License:    r.License,
+		LicenseURL: r.LicenseURL,
+		SourceURL:  r.DownloadURL,
+	}
+}
+
+// AttributionPath returns the JSON sidecar path for an image
+func AttributionPath(imagePath string) string {
+	return imagePath + ".attribution.json"
+}
+
+// WriteAttribution stores attribution in a sidecar next to imagePath
+func WriteAttribution(imagePath string, attribution Attribution) error {
+	if attribution.SavedAt.IsZero() {
+		attribution.SavedAt = time.Now().UTC()
+	}
+	data, err := json.MarshalIndent(attribution, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(AttributionPath(imagePath), data, 0644); err != nil {
+		return fmt.Errorf("failed to write attribution: %w", err)
+	}
+	return nil
+}
+
+// ReadAttribution loads the sidecar for imagePath. It returns nil without
+// an error when the image has none.
+func ReadAttribution(imagePath string) (*Attribution, error) {
+	data, err := os.ReadFile(AttributionPath(imagePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attribution: %w", err)
+	}
+	var attribution Attribution
+	if err := json.Unmarshal(data, &attribution); err != nil {
+		return nil, fmt.Errorf("failed to parse attribution: %w", err)
+	}
+	return &attribution, nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAttributionRoundTrip(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "beach.png")
+
+	if got, err := ReadAttribution(imagePath); err != nil || got != nil {
+		t.Fatalf("image without a sidecar: got %+v, %v", got, err)
+	}
+
+	want := AttributionFromResult(ImageResult{
+		URL:         "https://unsplash.com/photos/abc",
+		DownloadURL: "https://images.unsplash.com/photo-abc",
+		Author:      "Jane Doe",
+		AuthorURL:   "https://unsplash.com/@jane",
+		Source:      "Unsplash",
+		License:     "Unsplash License",
+		LicenseURL:  "https://unsplash.com/license",
+	})
+	if err := WriteAttribution(imagePath, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadAttribution(imagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SavedAt.IsZero() {
+		t.Error("expected SavedAt to be filled in")
+	}
+	got.SavedAt = want.SavedAt
+	if *got != want {
+		t.Errorf("read %+v, want %+v", *got, want)
+	}
+}
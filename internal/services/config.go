@@ -0,0 +1,27 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ConfigDir returns SweetDesk's per-user configuration directory,
+// e.g. ~/.config/SweetDesk on Linux
+func ConfigDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve user config directory: %w", err)
+	}
+	return filepath.Join(base, "SweetDesk"), nil
+}
+
+// CacheDir returns SweetDesk's per-user cache directory,
+// e.g. ~/.cache/SweetDesk on Linux
+func CacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve user cache directory: %w", err)
+	}
+	return filepath.Join(base, "SweetDesk"), nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DirectProvider downloads images from hosts that no provider claims, such
+// as the allowlisted hosts of pasted URLs. It sends a plain GET without
+// credentials and cannot search.
+type DirectProvider struct {
+	client *ProviderClient
+	ctx    context.Context
+}
+
+// NewDirectProvider creates a provider for direct downloads
+func NewDirectProvider(ctx context.Context) *DirectProvider {
+	return &DirectProvider{
+		client: NewProviderClient("Direct", 30*time.Second),
+		ctx:    ctx,
+	}
+}
+
+// GetName returns the provider name
+func (p *DirectProvider) GetName() string {
+	return "Direct"
+}
+
+// Search is not supported, direct downloads only fetch known URLs
+func (p *DirectProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	return nil, errors.New("direct downloads cannot be searched")
+}
+
+// DownloadFile streams an image to a staging file
+func (p *DirectProvider) DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error) {
+	return d.Fetch(p.ctx, p.client, imageURL, nil, progress)
+}
+
+// Download downloads an image from URL
+func (p *DirectProvider) Download(imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(p.ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDirectProviderDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || r.URL.RawQuery != "" {
+			t.Errorf("direct download sent credentials: %q, %q", r.Header.Get("Authorization"), r.URL.RawQuery)
+		}
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+	p := NewDirectProvider(context.Background())
+
+	data, err := p.Download(server.URL + "/wallpaper.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "image" {
+		t.Errorf("downloaded %q, want %q", data, "image")
+	}
+	if _, err := p.Search("sea", SearchOptions{}); err == nil {
+		t.Error("expected Search to fail")
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// URLRefusedError is returned when the download policy blocks a URL
+type URLRefusedError struct {
+	URL    string
+	Reason string
+}
+
+func (e *URLRefusedError) Error() string {
+	return fmt.Sprintf("download refused for %s: %s", e.URL, e.Reason)
+}
+
+// cgnatPrefix is the carrier-grade NAT range, which net.IP.IsPrivate omits
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// DownloadPolicy decides which URLs may be downloaded. Only http(s) URLs on
+// a registered provider's hosts or a user-approved domain are allowed, and
+// connections to loopback, link-local and private addresses are refused
+// after DNS resolution and on every redirect.
+type DownloadPolicy struct {
+	knownHost func(*url.URL) bool // e.g. ProviderRegistry.MatchesURL
+
+	mu        sync.RWMutex
+	allowlist []string
+	path      string // where the allowlist is persisted; empty to keep it in memory
+}
+
+// NewDownloadPolicy creates a policy that accepts the hosts knownHost
+// recognises. The allowlist is loaded from path when it exists.
+func NewDownloadPolicy(knownHost func(*url.URL) bool, path string) (*DownloadPolicy, error) {
+	p := &DownloadPolicy{knownHost: knownHost, path: path}
+	if path == "" {
+		return p, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return p, fmt.Errorf("failed to read download allowlist: %w", err)
+	}
+	var domains []string
+	if err := json.Unmarshal(data, &domains); err != nil {
+		return p, fmt.Errorf("failed to parse download allowlist: %w", err)
+	}
+	p.allowlist = normalizeDomains(domains)
+	return p, nil
+}
+
+// Allowlist returns the user-approved domains
+func (p *DownloadPolicy) Allowlist() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]string{}, p.allowlist...)
+}
+
+// SetAllowlist replaces the user-approved domains and persists them
+func (p *DownloadPolicy) SetAllowlist(domains []string) error {
+	domains = normalizeDomains(domains)
+	for _, domain := range domains {
+		if strings.ContainsAny(domain, "/:@ ") {
+			return fmt.Errorf("invalid domain %q", domain)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.path != "" {
+		data, err := json.MarshalIndent(domains, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		if err := os.WriteFile(p.path, data, 0644); err != nil {
+			return fmt.Errorf("failed to save download allowlist: %w", err)
+		}
+	}
+	p.allowlist = domains
+	return nil
+}
+
+// Check validates a URL before it is requested. Local file URLs are only
+// accepted when a provider (the local folder provider) recognises them.
+func (p *DownloadPolicy) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &URLRefusedError{URL: rawURL, Reason: "invalid URL"}
+	}
+
+	if u.Scheme == "file" {
+		if p.knownHost != nil && p.knownHost(u) {
+			return nil
+		}
+		return &URLRefusedError{URL: rawURL, Reason: "file is outside the configured folders"}
+	}
+	if err := p.checkTarget(u); err != nil {
+		return err
+	}
+
+	if p.knownHost != nil && p.knownHost(u) {
+		return nil
+	}
+	p.mu.RLock()
+	allowed := hostMatches(u, p.allowlist...)
+	p.mu.RUnlock()
+	if !allowed {
+		return &URLRefusedError{URL: u.Redacted(), Reason: fmt.Sprintf("%s is not a known image host; add it to the download allowlist", u.Hostname())}
+	}
+	return nil
+}
+
+// WithContext attaches the policy to ctx. Provider clients enforce the
+// address and redirect checks for requests made with the returned context.
+func (p *DownloadPolicy) WithContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, downloadPolicyKey{}, p)
+}
+
+// checkTarget allows only http(s) URLs whose host is not a blocked IP literal
+func (p *DownloadPolicy) checkTarget(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &URLRefusedError{URL: u.Redacted(), Reason: fmt.Sprintf("scheme %q is not allowed", u.Scheme)}
+	}
+	if u.Hostname() == "" {
+		return &URLRefusedError{URL: u.Redacted(), Reason: "missing host"}
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && isBlockedAddr(addr) {
+		return &URLRefusedError{URL: u.Redacted(), Reason: "address is not public"}
+	}
+	return nil
+}
+
+type downloadPolicyKey struct{}
+
+func policyFromContext(ctx context.Context) *DownloadPolicy {
+	p, _ := ctx.Value(downloadPolicyKey{}).(*DownloadPolicy)
+	return p
+}
+
+// guardDial is a net.Dialer ControlContext hook. It runs after DNS
+// resolution with the actual address, so names that resolve to private
+// addresses are caught too.
+func guardDial(ctx context.Context, network, address string, _ syscall.RawConn) error {
+	if policyFromContext(ctx) == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return &URLRefusedError{URL: address, Reason: "invalid address"}
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || isBlockedAddr(addr) {
+		return &URLRefusedError{URL: address, Reason: "address is not public"}
+	}
+	return nil
+}
+
+// guardRedirect is an http.Client CheckRedirect hook
+func guardRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if p := policyFromContext(req.Context()); p != nil {
+		return p.checkTarget(req.URL)
+	}
+	return nil
+}
+
+// isBlockedAddr reports whether addr is loopback, link-local, private or
+// otherwise not a public unicast address
+func isBlockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() ||
+		cgnatPrefix.Contains(addr)
+}
+
+func normalizeDomains(domains []string) []string {
+	seen := map[string]bool{}
+	cleaned := []string{}
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(strings.ToLower(trim(domain)), "*.")
+		if domain != "" && !seen[domain] {
+			seen[domain] = true
+			cleaned = append(cleaned, domain)
+		}
+	}
+	sort.Strings(cleaned)
+	return cleaned
+}
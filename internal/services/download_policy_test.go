@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadPolicyCheck(t *testing.T) {
+	policy, err := NewDownloadPolicy(func(u *url.URL) bool {
+		return hostMatches(u, "pixabay.com") || u.Scheme == "file" && strings.HasPrefix(u.Path, "/pictures/")
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.SetAllowlist([]string{" Example.org ", "*.images.net"}); err != nil {
+		t.Fatal(err)
+	}
+
+	allowed := []string{
+		"https://cdn.pixabay.com/photo/1.jpg",
+		"http://example.org/a.png",
+		"https://static.example.org/a.png",
+		"https://images.net/a.png",
+		"file:///pictures/beach.jpg",
+	}
+	for _, u := range allowed {
+		if err := policy.Check(u); err != nil {
+			t.Errorf("Check(%q) = %v, want allowed", u, err)
+		}
+	}
+
+	refused := []string{
+		"ftp://pixabay.com/1.jpg",
+		"file:///etc/passwd",
+		"https://evil.com/a.png",
+		"https://example.org.evil.com/a.png",
+		"http://127.0.0.1/a.png",
+		"http://[::1]/a.png",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.5/a.png",
+		"javascript:alert(1)",
+	}
+	for _, u := range refused {
+		var refusedErr *URLRefusedError
+		if err := policy.Check(u); !errors.As(err, &refusedErr) {
+			t.Errorf("Check(%q) = %v, want URLRefusedError", u, err)
+		}
+	}
+}
+
+func TestDownloadPolicyAllowlistPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist.json")
+	policy, err := NewDownloadPolicy(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.SetAllowlist([]string{"b.com", "a.com", "A.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.SetAllowlist([]string{"http://a.com/path"}); err == nil {
+		t.Error("expected a URL to be rejected as a domain")
+	}
+
+	reloaded, err := NewDownloadPolicy(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Allowlist(); len(got) != 2 || got[0] != "a.com" || got[1] != "b.com" {
+		t.Errorf("reloaded allowlist = %v, want [a.com b.com]", got)
+	}
+}
+
+func TestDownloadPolicyBlocksResolvedPrivateAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(encodeImageToPNG(createTestImage(8, 8)))
+	}))
+	defer server.Close()
+
+	// "localhost" passes the host check but resolves to a loopback address
+	policy, _ := NewDownloadPolicy(func(u *url.URL) bool { return u.Hostname() == "localhost" }, "")
+	d := newTestDownloader(t, DefaultMaxDownloadBytes)
+	d.SetPolicy(policy)
+
+	imageURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/image.png"
+	_, err := d.Fetch(context.Background(), newTestClient(), imageURL, nil, nil)
+	var refused *URLRefusedError
+	if !errors.As(err, &refused) {
+		t.Fatalf("Fetch = %v, want URLRefusedError", err)
+	}
+
+	// Without a policy the same request goes through
+	d.SetPolicy(nil)
+	if _, err := d.Fetch(context.Background(), newTestClient(), imageURL, nil, nil); err != nil {
+		t.Errorf("Fetch without policy failed: %v", err)
+	}
+}
+
+func TestGuardRedirect(t *testing.T) {
+	policy, _ := NewDownloadPolicy(nil, "")
+	ctx := policy.WithContext(context.Background())
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://192.168.1.1/router.png", nil)
+	var refused *URLRefusedError
+	if err := guardRedirect(req, []*http.Request{req}); !errors.As(err, &refused) {
+		t.Errorf("redirect to a private address = %v, want URLRefusedError", err)
+	}
+
+	req, _ = http.NewRequestWithContext(ctx, "GET", "https://cdn.example.com/a.png", nil)
+	if err := guardRedirect(req, []*http.Request{req}); err != nil {
+		t.Errorf("redirect to a public host = %v, want nil", err)
+	}
+
+	req, _ = http.NewRequest("GET", "http://192.168.1.1/router.png", nil)
+	if err := guardRedirect(req, []*http.Request{req}); err != nil {
+		t.Errorf("requests without a policy should not be checked, got %v", err)
+	}
+}
+
+func TestIsBlockedAddr(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":         false,
+		"2606:4700::1111": false,
+		"127.0.0.1":       true,
+		"10.1.2.3":        true,
+		"172.16.0.1":      true,
+		"192.168.0.1":     true,
+		"169.254.169.254": true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"::1":             true,
+		"fe80::1":         true,
+		"fd00::1":         true,
+		"::ffff:10.0.0.1": true,
+	}
+	for addr, want := range cases {
+		if got := isBlockedAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isBlockedAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
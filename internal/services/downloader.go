@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultMaxDownloadBytes caps a single image download
+	DefaultMaxDownloadBytes = 200 << 20
+
+	// MaxPreviewBytes caps a preview image download
+	MaxPreviewBytes = 20 << 20
+
+	// defaultMaxResumes is how many times an interrupted transfer is resumed
+	defaultMaxResumes = 3
+
+	// defaultStallTimeout is how long a transfer may go without receiving
+	// data before it is treated as interrupted
+	defaultStallTimeout = 30 * time.Second
+)
+
+// errDownloadStalled interrupts a transfer that stopped receiving data
+var errDownloadStalled = errors.New("download stalled")
+
+// DownloadProgress reports the bytes received so far for one download.
+// total is -1 when the server does not send the size.
+type DownloadProgress func(received, total int64)
+
+// StreamingProvider is implemented by providers whose downloads can be
+// streamed to a staging file instead of being held in memory
+type StreamingProvider interface {
+	DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error)
+}
+
+// Downloader streams images to staging files, enforcing a size limit,
+// checking that the payload is an image, and resuming interrupted
+// transfers with Range requests. Callers remove the returned file.
+type Downloader struct {
+	dir          string
+	maxBytes     int64
+	maxResumes   int
+	stallTimeout time.Duration
+	policy       *DownloadPolicy
+}
+
+// NewDownloader creates a downloader that stages files in dir
+func NewDownloader(dir string, maxBytes int64) (*Downloader, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxDownloadBytes
+	}
+	return &Downloader{dir: dir, maxBytes: maxBytes, maxResumes: defaultMaxResumes, stallTimeout: defaultStallTimeout}, nil
+}
+
+// SetPolicy makes Fetch refuse URLs and addresses the policy blocks
+func (d *Downloader) SetPolicy(policy *DownloadPolicy) {
+	d.policy = policy
+}
+
+// Fetch downloads imageURL through client into a new staging file and
+// returns its path. header is added to every request. There is no limit
+// on the duration of a transfer, only on how long it may stall.
+func (d *Downloader) Fetch(ctx context.Context, client *ProviderClient, imageURL string, header http.Header, progress DownloadProgress) (string, error) {
+	if d.policy != nil {
+		if err := d.policy.Check(imageURL); err != nil {
+			return "", err
+		}
+		ctx = d.policy.WithContext(ctx)
+	}
+
+	file, err := os.CreateTemp(d.dir, "download-*.part")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging file: %w", err)
+	}
+	path := file.Name()
+	ok := false
+	defer func() {
+		file.Close()
+		if !ok {
+			os.Remove(path)
+		}
+	}()
+
+	var received int64
+	total := int64(-1)
+	validator := "" // ETag or Last-Modified, so a resume never mixes two versions
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to create request: %w", err)
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		if received > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", received))
+			if validator != "" {
+				req.Header.Set("If-Range", validator)
+			}
+		}
+
+		resp, err := client.Download(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to download image: %w", err)
+		}
+
+		if received == 0 || resp.StatusCode != http.StatusPartialContent {
+			// First response, or the server ignored the range: start over
+			if err := checkImageContentType(resp.Header.Get("Content-Type")); err != nil {
+				resp.Body.Close()
+				return "", err
+			}
+			if resp.ContentLength > d.maxBytes {
+				resp.Body.Close()
+				return "", fmt.Errorf("image is %d MB, larger than the %d MB download limit",
+					resp.ContentLength>>20, d.maxBytes>>20)
+			}
+			if err := resetFile(file); err != nil {
+				resp.Body.Close()
+				return "", err
+			}
+			received = 0
+			total = resp.ContentLength
+			validator = resp.Header.Get("ETag")
+			if validator == "" {
+				validator = resp.Header.Get("Last-Modified")
+			}
+		} else if start, size, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || start != received {
+			resp.Body.Close()
+			return "", fmt.Errorf("server resumed the download at the wrong offset")
+		} else if size > 0 {
+			total = size
+		}
+
+		// Closing the body unblocks a read that is waiting for data
+		var stalled atomic.Bool
+		watchdog := time.AfterFunc(d.stallTimeout, func() {
+			stalled.Store(true)
+			resp.Body.Close()
+		})
+		n, copyErr := io.Copy(file, &progressReader{
+			r:            io.LimitReader(resp.Body, d.maxBytes-received+1),
+			received:     received,
+			total:        total,
+			report:       progress,
+			watchdog:     watchdog,
+			stallTimeout: d.stallTimeout,
+		})
+		watchdog.Stop()
+		resp.Body.Close()
+		received += n
+		if copyErr != nil && stalled.Load() {
+			copyErr = fmt.Errorf("%w: no data for %v", errDownloadStalled, d.stallTimeout)
+		}
+
+		if received > d.maxBytes {
+			return "", fmt.Errorf("image is larger than the %d MB download limit", d.maxBytes>>20)
+		}
+		if copyErr == nil && (total < 0 || received == total) {
+			break
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if copyErr == nil {
+			copyErr = io.ErrUnexpectedEOF // connection closed before Content-Length
+		}
+		if attempt >= d.maxResumes || !isTransientError(copyErr) {
+			return "", fmt.Errorf("download interrupted: %w", copyErr)
+		}
+		log.Printf("⚠️  Download interrupted at %d bytes, resuming: %v", received, copyErr)
+		if err := sleepContext(ctx, time.Duration(attempt+1)*500*time.Millisecond); err != nil {
+			return "", err
+		}
+	}
+
+	if err := checkImageFile(file); err != nil {
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write staging file: %w", err)
+	}
+	ok = true
+	return path, nil
+}
+
+// Stage copies an image from r into a new staging file with the same
+// limits as Fetch. It is used for sources that are already local.
+func (d *Downloader) Stage(r io.Reader, progress DownloadProgress, total int64) (string, error) {
+	file, err := os.CreateTemp(d.dir, "download-*.part")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging file: %w", err)
+	}
+	path := file.Name()
+	ok := false
+	defer func() {
+		file.Close()
+		if !ok {
+			os.Remove(path)
+		}
+	}()
+
+	n, err := io.Copy(file, &progressReader{r: io.LimitReader(r, d.maxBytes+1), total: total, report: progress})
+	if err != nil {
+		return "", fmt.Errorf("failed to copy image: %w", err)
+	}
+	if n > d.maxBytes {
+		return "", fmt.Errorf("image is larger than the %d MB download limit", d.maxBytes>>20)
+	}
+	if err := checkImageFile(file); err != nil {
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write staging file: %w", err)
+	}
+	ok = true
+	return path, nil
+}
+
+// progressReader reports cumulative progress as data is read, and pushes
+// back the watchdog, if any, whenever data arrives
+type progressReader struct {
+	r        io.Reader
+	received int64
+	total    int64
+	report   DownloadProgress
+
+	watchdog     *time.Timer
+	stallTimeout time.Duration
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.received += int64(n)
+	if pr.watchdog != nil && n > 0 {
+		pr.watchdog.Reset(pr.stallTimeout)
+	}
+	if pr.report != nil && n > 0 {
+		pr.report(pr.received, pr.total)
+	}
+	return n, err
+}
+
+func resetFile(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to reset staging file: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to reset staging file: %w", err)
+	}
+	return nil
+}
+
+// checkImageContentType rejects responses that declare a non-image type.
+// Generic binary types are accepted and left to the magic-byte check.
+func checkImageContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid Content-Type %q", contentType)
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "image/"),
+		mediaType == "application/octet-stream",
+		mediaType == "binary/octet-stream":
+		return nil
+	}
+	return fmt.Errorf("URL did not return an image (Content-Type %s)", mediaType)
+}
+
+// checkImageFile verifies the magic bytes of a staged file
+func checkImageFile(file *os.File) error {
+	head := make([]byte, 512)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read staging file: %w", err)
+	}
+	if !isImageData(head[:n]) {
+		return fmt.Errorf("downloaded file is not a supported image")
+	}
+	return nil
+}
+
+// isImageData sniffs the leading bytes of a file for a known image format
+func isImageData(head []byte) bool {
+	if strings.HasPrefix(http.DetectContentType(head), "image/") {
+		return true
+	}
+	// TIFF is not covered by http.DetectContentType
+	return bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*"))
+}
+
+// parseContentRange parses "bytes start-end/size"; size is -1 if unknown
+func parseContentRange(value string) (start int64, size int64, ok bool) {
+	value, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rangePart, sizePart, found := strings.Cut(value, "/")
+	if !found {
+		return 0, 0, false
+	}
+	startPart, _, found := strings.Cut(rangePart, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(startPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	size = -1
+	if sizePart != "*" {
+		if size, err = strconv.ParseInt(sizePart, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, size, true
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestDownloader(t *testing.T, maxBytes int64) *Downloader {
+	t.Helper()
+	d, err := NewDownloader(t.TempDir(), maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDownloaderFetch(t *testing.T) {
+	image := encodeImageToPNG(createTestImage(64, 64))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(image)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/fake.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("<html>not really a png</html>"))
+		}
+	}))
+	defer server.Close()
+	client := newTestClient()
+
+	d := newTestDownloader(t, DefaultMaxDownloadBytes)
+	var lastReceived, lastTotal int64
+	path, err := d.Fetch(context.Background(), client, server.URL+"/image.png", nil, func(received, total int64) {
+		lastReceived, lastTotal = received, total
+	})
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	defer os.Remove(path)
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, image) {
+		t.Error("staged file does not match the served image")
+	}
+	if lastReceived != int64(len(image)) || lastTotal != int64(len(image)) {
+		t.Errorf("progress ended at %d/%d, want %d", lastReceived, lastTotal, len(image))
+	}
+
+	if _, err := d.Fetch(context.Background(), client, server.URL+"/page.html", nil, nil); err == nil {
+		t.Error("expected an HTML page to be rejected by Content-Type")
+	}
+	if _, err := d.Fetch(context.Background(), client, server.URL+"/fake.png", nil, nil); err == nil {
+		t.Error("expected a non-image body to be rejected by its magic bytes")
+	}
+
+	small := newTestDownloader(t, int64(len(image)/2))
+	if _, err := small.Fetch(context.Background(), client, server.URL+"/image.png", nil, nil); err == nil {
+		t.Error("expected the size limit to be enforced")
+	}
+	if entries, _ := os.ReadDir(small.dir); len(entries) != 0 {
+		t.Errorf("failed downloads left %d staging files", len(entries))
+	}
+}
+
+func TestDownloaderResumesInterruptedTransfer(t *testing.T) {
+	image := encodeImageToPNG(createTestImage(256, 256))
+	modTime := time.Now().Add(-time.Hour)
+	var requests atomic.Int32
+	var sawRange atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// Announce the full size, send half, then drop the connection
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+			w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+			w.Write(image[:len(image)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		if r.Header.Get("Range") != "" {
+			sawRange.Store(true)
+		}
+		http.ServeContent(w, r, "image.png", modTime, bytes.NewReader(image))
+	}))
+	defer server.Close()
+
+	d := newTestDownloader(t, DefaultMaxDownloadBytes)
+	path, err := d.Fetch(context.Background(), newTestClient(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	if !sawRange.Load() {
+		t.Error("expected the second request to use a Range header")
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, image) {
+		t.Errorf("resumed file is %d bytes, want %d identical bytes", len(data), len(image))
+	}
+}
+
+func TestDownloaderSlowAndStalledTransfers(t *testing.T) {
+	image := encodeImageToPNG(createTestImage(64, 64))
+	modTime := time.Now().Add(-time.Hour)
+	var stalls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow.png":
+			// Steady data, taking longer than the client timeout overall
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+			chunk := len(image)/8 + 1
+			for start := 0; start < len(image); start += chunk {
+				w.Write(image[start:min(start+chunk, len(image))])
+				w.(http.Flusher).Flush()
+				time.Sleep(50 * time.Millisecond)
+			}
+		case "/stall.png":
+			if r.Header.Get("Range") == "" {
+				// Send half, then go quiet until the client gives up
+				stalls.Add(1)
+				w.Header().Set("Content-Type", "image/png")
+				w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+				w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+				w.Write(image[:len(image)/2])
+				w.(http.Flusher).Flush()
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+				return
+			}
+			http.ServeContent(w, r, "image.png", modTime, bytes.NewReader(image))
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient()
+	client.client.Timeout = 200 * time.Millisecond
+	d := newTestDownloader(t, DefaultMaxDownloadBytes)
+	d.stallTimeout = 150 * time.Millisecond
+
+	for _, name := range []string{"/slow.png", "/stall.png"} {
+		path, err := d.Fetch(context.Background(), client, server.URL+name, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: Fetch failed: %v", name, err)
+		}
+		defer os.Remove(path)
+		if data, _ := os.ReadFile(path); !bytes.Equal(data, image) {
+			t.Errorf("%s: staged %d bytes, want %d identical bytes", name, len(data), len(image))
+		}
+	}
+	if stalls.Load() != 1 {
+		t.Errorf("stalled transfer was requested %d times from the start, want 1", stalls.Load())
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	if start, size, ok := parseContentRange("bytes 100-199/1000"); !ok || start != 100 || size != 1000 {
+		t.Errorf("got %d, %d, %v", start, size, ok)
+	}
+	if _, size, ok := parseContentRange("bytes 0-9/*"); !ok || size != -1 {
+		t.Errorf("unknown size: got %d, %v", size, ok)
+	}
+	if _, _, ok := parseContentRange("items 0-9/10"); ok {
+		t.Error("expected a non-byte range to be rejected")
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// minSizedQuality is the lowest JPEG quality EncodeToSize accepts before it
+// falls back to 4:2:0 chroma and smaller images
+const minSizedQuality = 50
+
+// sizedScales are the downscales EncodeToSize tries, in order
+var sizedScales = []float64{0.9, 0.8, 0.7}
+
+// EncodeToSize encodes img as a JPEG of at most maxBytes, at the highest
+// quality that fits, and returns that quality. Below quality 50 it first
+// tries slightly smaller versions of the image.
+func (ip *ImageProcessor) EncodeToSize(img image.Image, maxBytes int) ([]byte, int, error) {
+	return ip.encodeToSize(img, maxBytes, OutputFormat{Format: FormatJPEG})
+}
+
+// encodeToSize is EncodeToSize with out's JPEG settings. out.Quality, if
+// set, caps the quality, and out.Subsampling is tried before 4:2:0.
+func (ip *ImageProcessor) encodeToSize(img image.Image, maxBytes int, out OutputFormat) ([]byte, int, error) {
+	if maxBytes <= 0 {
+		return nil, 0, fmt.Errorf("invalid size limit: %d bytes", maxBytes)
+	}
+	top := out.Quality
+	if top == 0 {
+		top = 100
+	}
+
+	type attempt struct {
+		subsampling string
+		scale       float64
+	}
+	attempts := []attempt{{out.Subsampling, 1}}
+	if out.Subsampling != "" && out.Subsampling != Subsampling420 {
+		attempts = append(attempts, attempt{Subsampling420, 1})
+	}
+	for _, scale := range sizedScales {
+		attempts = append(attempts, attempt{Subsampling420, scale})
+	}
+
+	b := img.Bounds()
+	for i, a := range attempts {
+		src := img
+		if a.scale != 1 {
+			width := max(int(math.Round(float64(b.Dx())*a.scale)), 1)
+			height := max(int(math.Round(float64(b.Dy())*a.scale)), 1)
+			src = scaleImage(img, width, height, xdraw.CatmullRom)
+		}
+		j, err := newJPEGImage(src, a.subsampling)
+		if err != nil {
+			return nil, 0, err
+		}
+		// The smallest image may go below the quality floor
+		low := minSizedQuality
+		if i == len(attempts)-1 {
+			low = 1
+		}
+		data, quality, err := j.encodeUnder(maxBytes, low, top, out.Progressive)
+		if err != nil {
+			return nil, 0, err
+		}
+		if data != nil {
+			return data, quality, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("cannot encode the image in %d bytes", maxBytes)
+}
+
+// encodeUnder finds the highest quality between low and high whose
+// encoding fits in maxBytes. The data is nil when none does.
+func (j *jpegImage) encodeUnder(maxBytes, low, high int, progressive bool) ([]byte, int, error) {
+	var best []byte
+	bestQuality := 0
+	for low <= high {
+		quality := (low + high) / 2
+		var buf bytes.Buffer
+		if err := j.encode(&buf, quality, progressive); err != nil {
+			return nil, 0, err
+		}
+		if buf.Len() <= maxBytes {
+			best, bestQuality = buf.Bytes(), quality
+			low = quality + 1
+		} else {
+			high = quality - 1
+		}
+	}
+	return best, bestQuality, nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEncodeToSize(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	src := createNoisyImage(400, 300, false)
+	jpegSize := func(quality int) int {
+		data, err := ip.Encode(src, OutputFormat{Format: FormatJPEG, Quality: quality})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(data)
+	}
+
+	// Just under the limit: the next quality up no longer fits
+	limit := jpegSize(80) + 100
+	data, quality, err := ip.EncodeToSize(src, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > limit || quality < minSizedQuality || quality == 100 || jpegSize(quality+1) <= limit {
+		t.Errorf("got %d bytes at quality %d for a %d byte limit", len(data), quality, limit)
+	}
+	if w, h, format, _ := ip.DecodeDimensions(data); w != 400 || h != 300 || format != "jpeg" {
+		t.Errorf("got a %dx%d %s, want a 400x300 jpeg", w, h, format)
+	}
+
+	// Below the quality floor the image is made slightly smaller
+	limit = jpegSize(minSizedQuality) * 9 / 10
+	data, _, err = ip.EncodeToSize(src, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, _, _, _ := ip.DecodeDimensions(data); len(data) > limit || w >= 400 || w < 280 {
+		t.Errorf("got %d bytes %d pixels wide for a %d byte limit", len(data), w, limit)
+	}
+
+	if _, _, err := ip.EncodeToSize(src, 200); err == nil {
+		t.Error("EncodeToSize in 200 bytes succeeded, want an error")
+	}
+}
+
+func TestEncodeOutputMaxBytes(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	data := encodeImageToPNG(createNoisyImage(160, 90, false))
+
+	out := OutputFormat{Format: FormatJPEG, Quality: 70, MaxBytes: 1 << 20}
+	encoded, quality, changed, err := ip.EncodeOutput(data, 160, 90, ProcessingOptions{}, out)
+	if err != nil || !changed {
+		t.Fatalf("EncodeOutput: changed = %v, err = %v", changed, err)
+	}
+	if quality != 70 || len(encoded) > out.MaxBytes {
+		t.Errorf("got %d bytes at quality %d, want quality capped at 70", len(encoded), quality)
+	}
+
+	if err := (OutputFormat{MaxBytes: 1000}).Validate(); err == nil {
+		t.Error("a size limit for PNG output validated")
+	}
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Fit modes decide how an upscaled image whose aspect ratio differs from
+// the target's fills it
+const (
+	FitStretch   = "stretch"   // scale each dimension to the target
+	FitCrop      = "crop"      // cover the target and keep the centre
+	FitSmartCrop = "smart"     // cover the target and keep the most salient part
+	FitLetterbox = "letterbox" // fit inside the target on FillColor
+	FitBlur      = "blur"      // fit inside the target on a blurred copy of itself
+	FitMirror    = "mirror"    // fit inside the target, mirroring the edges outwards
+)
+
+// smartCropSize bounds the longer side of the saliency map
+const smartCropSize = 256
+
+// Validate checks the fit mode, fill colour, crop and rotation
+func (o ProcessingOptions) Validate() error {
+	switch o.FitMode {
+	case "", FitStretch, FitCrop, FitSmartCrop, FitLetterbox, FitBlur, FitMirror:
+	default:
+		return fmt.Errorf("unknown fit mode: %s", o.FitMode)
+	}
+	if _, err := parseHexColor(o.FillColor); err != nil {
+		return err
+	}
+	return o.validateTransform()
+}
+
+// UpscaleSize returns the size a width×height source is upscaled to before
+// Fit: the target itself for stretching, the smallest size covering it for
+// crops, or the largest fitting inside it for the letterbox modes. The
+// source's aspect ratio is kept in the last two cases.
+func (ip *ImageProcessor) UpscaleSize(width, height, targetWidth, targetHeight int, mode string) (int, int) {
+	if width <= 0 || height <= 0 {
+		return targetWidth, targetHeight
+	}
+	// Cross-multiplied to compare aspect ratios exactly
+	wider := int64(width)*int64(targetHeight) > int64(height)*int64(targetWidth)
+	switch mode {
+	case FitCrop, FitSmartCrop:
+		if wider {
+			return scaledSide(width, height, targetHeight), targetHeight
+		}
+		return targetWidth, scaledSide(height, width, targetWidth)
+	case FitLetterbox, FitBlur, FitMirror:
+		if wider {
+			return targetWidth, scaledSide(height, width, targetWidth)
+		}
+		return scaledSide(width, height, targetHeight), targetHeight
+	}
+	return targetWidth, targetHeight
+}
+
+// scaledSide is side scaled by to/from, at least one pixel
+func scaledSide(side, from, to int) int {
+	return max(int(math.Round(float64(side)*float64(to)/float64(from))), 1)
+}
+
+// FitBytes decodes an upscaled image, fits it to targetWidth×targetHeight
+// with Fit and encodes it again in its own format. data is returned as-is
+// when it already has the target size.
+func (ip *ImageProcessor) FitBytes(data []byte, targetWidth, targetHeight int, opts ProcessingOptions) ([]byte, bool, error) {
+	width, height, _, err := ip.DecodeDimensions(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if width == targetWidth && height == targetHeight {
+		return data, false, nil
+	}
+	img, format, err := ip.LoadImageFromBytes(data)
+	if err != nil {
+		return nil, false, err
+	}
+	fitted, err := ip.Fit(img, targetWidth, targetHeight, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	quality := opts.Quality
+	if quality <= 0 {
+		quality = DefaultJPEGQuality
+	}
+	out, err := ip.EncodeImage(fitted, format, quality)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// Fit fits img to targetWidth×targetHeight with opts.FitMode, stretching
+// when no mode is set. img is normally already at the UpscaleSize for the
+// mode; any other size is scaled to it first.
+func (ip *ImageProcessor) Fit(img image.Image, targetWidth, targetHeight int, opts ProcessingOptions) (image.Image, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("cannot fit an empty image")
+	}
+	if targetWidth <= 0 || targetHeight <= 0 {
+		return nil, fmt.Errorf("invalid fit size: %dx%d", targetWidth, targetHeight)
+	}
+	if b.Dx() == targetWidth && b.Dy() == targetHeight {
+		return img, nil
+	}
+
+	width, height := ip.UpscaleSize(b.Dx(), b.Dy(), targetWidth, targetHeight, opts.FitMode)
+	scaled := scaleImage(img, width, height, xdraw.CatmullRom)
+	target := image.Rect(0, 0, targetWidth, targetHeight)
+
+	switch opts.FitMode {
+	case FitCrop, FitSmartCrop:
+		offset := image.Pt((width-targetWidth)/2, (height-targetHeight)/2)
+		if opts.FitMode == FitSmartCrop {
+			offset = smartCropOffset(scaled, targetWidth, targetHeight)
+		}
+		out := image.NewNRGBA(target)
+		xdraw.Draw(out, target, scaled, offset, xdraw.Src)
+		return out, nil
+	case FitLetterbox:
+		fill, _ := parseHexColor(opts.FillColor)
+		out := image.NewNRGBA(target)
+		xdraw.Draw(out, target, image.NewUniform(fill), image.Point{}, xdraw.Src)
+		xdraw.Draw(out, centered(scaled.Bounds(), target), scaled, image.Point{}, xdraw.Over)
+		return out, nil
+	case FitBlur:
+		out := blurredBackground(scaled, targetWidth, targetHeight)
+		xdraw.Draw(out, centered(scaled.Bounds(), target), scaled, image.Point{}, xdraw.Over)
+		return out, nil
+	case FitMirror:
+		return mirrorExtend(scaled, targetWidth, targetHeight), nil
+	}
+	return scaled, nil
+}
+
+// scaleImage returns img resized to width×height, or img itself when it
+// already has that size
+func scaleImage(img image.Image, width, height int, scaler xdraw.Scaler) image.Image {
+	b := img.Bounds()
+	if b.Dx() == width && b.Dy() == height {
+		return img
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	scaler.Scale(out, out.Bounds(), img, b, xdraw.Src, nil)
+	return out
+}
+
+// centered returns a rectangle of r's size centred in target
+func centered(r, target image.Rectangle) image.Rectangle {
+	x := (target.Dx() - r.Dx()) / 2
+	y := (target.Dy() - r.Dy()) / 2
+	return image.Rect(x, y, x+r.Dx(), y+r.Dy())
+}
+
+// smartCropOffset returns where to cut a width×height window out of img,
+// which covers it along one axis. Each position is scored by the saliency
+// inside it: luminance edges, where the detail is, plus colour that stands
+// out from the image's average. Near-ties go to the most central position.
+func smartCropOffset(img image.Image, width, height int) image.Point {
+	b := img.Bounds()
+	horizontal := b.Dx() > width
+	if !horizontal && b.Dy() <= height {
+		return image.Point{}
+	}
+
+	// Work on a small copy; only the profile along the free axis is needed
+	scale := min(float64(smartCropSize)/float64(max(b.Dx(), b.Dy())), 1)
+	sw, sh := max(int(float64(b.Dx())*scale), 1), max(int(float64(b.Dy())*scale), 1)
+	small := image.NewNRGBA(image.Rect(0, 0, sw, sh))
+	xdraw.ApproxBiLinear.Scale(small, small.Bounds(), img, b, xdraw.Src, nil)
+
+	saliency := saliencyMap(small)
+	profile := make([]float64, sw)
+	window := max(int(math.Round(float64(width)*float64(sw)/float64(b.Dx()))), 1)
+	if !horizontal {
+		profile = make([]float64, sh)
+		window = max(int(math.Round(float64(height)*float64(sh)/float64(b.Dy()))), 1)
+	}
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			if horizontal {
+				profile[x] += saliency[y*sw+x]
+			} else {
+				profile[y] += saliency[y*sw+x]
+			}
+		}
+	}
+	window = min(window, len(profile))
+
+	// Sliding window sums, keeping the best and, within 2% of it, the
+	// position closest to the centre
+	sums := make([]float64, len(profile)-window+1)
+	for i, v := range profile {
+		if i < window {
+			sums[0] += v
+		}
+	}
+	best := sums[0]
+	for i := 1; i < len(sums); i++ {
+		sums[i] = sums[i-1] + profile[i+window-1] - profile[i-1]
+		best = max(best, sums[i])
+	}
+	center := float64(len(sums)-1) / 2
+	pos := -1
+	for i, s := range sums {
+		if s >= best*0.98 && (pos < 0 || math.Abs(float64(i)-center) < math.Abs(float64(pos)-center)) {
+			pos = i
+		}
+	}
+
+	// Back to full-size pixels
+	if horizontal {
+		x := int(math.Round(float64(pos) * float64(b.Dx()) / float64(sw)))
+		return image.Pt(min(x, b.Dx()-width), 0)
+	}
+	y := int(math.Round(float64(pos) * float64(b.Dy()) / float64(sh)))
+	return image.Pt(0, min(y, b.Dy()-height))
+}
+
+// saliencyMap scores each pixel of img by its luminance gradient plus its
+// colour distance from the image's mean colour
+func saliencyMap(img *image.NRGBA) []float64 {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	lum := make([]float64, w*h)
+	var mr, mg, mb float64
+	for i := range lum {
+		p := img.Pix[i*4 : i*4+3]
+		lum[i] = 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+		mr, mg, mb = mr+float64(p[0]), mg+float64(p[1]), mb+float64(p[2])
+	}
+	n := float64(w * h)
+	mr, mg, mb = mr/n, mg/n, mb/n
+
+	saliency := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			dx := lum[y*w+min(x+1, w-1)] - lum[y*w+max(x-1, 0)]
+			dy := lum[min(y+1, h-1)*w+x] - lum[max(y-1, 0)*w+x]
+			p := img.Pix[i*4 : i*4+3]
+			dr, dg, db := float64(p[0])-mr, float64(p[1])-mg, float64(p[2])-mb
+			saliency[i] = math.Abs(dx) + math.Abs(dy) + math.Sqrt(dr*dr+dg*dg+db*db)
+		}
+	}
+	return saliency
+}
+
+// blurredBackground returns img scaled to cover width×height and heavily
+// blurred. The blur runs on a 1/16 size copy, which the smooth upscale
+// back to full size spreads further.
+func blurredBackground(img image.Image, width, height int) *image.NRGBA {
+	b := img.Bounds()
+	scale := max(float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy()))
+	cw, ch := int(math.Ceil(float64(b.Dx())*scale)), int(math.Ceil(float64(b.Dy())*scale))
+
+	small := image.NewNRGBA(image.Rect(0, 0, max(cw/16, 1), max(ch/16, 1)))
+	xdraw.ApproxBiLinear.Scale(small, small.Bounds(), img, b, xdraw.Src, nil)
+	for range 3 {
+		boxBlur(small, 2)
+	}
+
+	// Scale the blurred copy to cover and keep the centre
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	sb := small.Bounds()
+	offX, offY := (cw-width)/2, (ch-height)/2
+	xdraw.BiLinear.Scale(out, image.Rect(-offX, -offY, cw-offX, ch-offY), small, sb, xdraw.Src, nil)
+	return out
+}
+
+// boxBlur blurs img in place with a (2r+1)² box, edges clamped
+func boxBlur(img *image.NRGBA, r int) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	tmp := make([]uint8, len(img.Pix))
+	pass := func(src, dst []uint8, n, stride, lines, lineStride int) {
+		for l := 0; l < lines; l++ {
+			base := l * lineStride
+			for i := 0; i < n; i++ {
+				for c := 0; c < 4; c++ {
+					var sum int
+					for k := -r; k <= r; k++ {
+						j := min(max(i+k, 0), n-1)
+						sum += int(src[base+j*stride+c])
+					}
+					dst[base+i*stride+c] = uint8(sum / (2*r + 1))
+				}
+			}
+		}
+	}
+	pass(img.Pix, tmp, w, 4, h, img.Stride)
+	pass(tmp, img.Pix, h, img.Stride, w, 4)
+}
+
+// mirrorExtend centres img, which fits inside width×height, and fills the
+// bands either side with its reflection
+func mirrorExtend(img image.Image, width, height int) *image.NRGBA {
+	b := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	xdraw.Draw(src, src.Bounds(), img, b.Min, xdraw.Src)
+
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	r := centered(src.Bounds(), out.Bounds())
+	for y := 0; y < height; y++ {
+		sy := reflect(y-r.Min.Y, src.Rect.Dy())
+		for x := 0; x < width; x++ {
+			sx := reflect(x-r.Min.X, src.Rect.Dx())
+			copy(out.Pix[y*out.Stride+x*4:y*out.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:])
+		}
+	}
+	return out
+}
+
+// reflect maps i onto 0..n-1, mirroring back and forth past either end
+func reflect(i, n int) int {
+	period := 2 * n
+	i %= period
+	if i < 0 {
+		i += period
+	}
+	if i >= n {
+		i = period - 1 - i
+	}
+	return i
+}
+
+// parseHexColor parses "#rgb" or "#rrggbb"; an empty string is black
+func parseHexColor(s string) (color.NRGBA, error) {
+	black := color.NRGBA{A: 255}
+	if s == "" {
+		return black, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return black, fmt.Errorf("invalid fill colour: %s", s)
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
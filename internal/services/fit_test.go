@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestUpscaleSize(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	cases := []struct {
+		w, h, tw, th int
+		mode         string
+		wantW, wantH int
+	}{
+		{1000, 1000, 3840, 2160, "", 3840, 2160},
+		{1000, 1000, 3840, 2160, FitStretch, 3840, 2160},
+		{1000, 1000, 3840, 2160, FitCrop, 3840, 3840},
+		{1000, 1000, 3840, 2160, FitLetterbox, 2160, 2160},
+		{2100, 900, 3840, 2160, FitSmartCrop, 5040, 2160},
+		{2100, 900, 3840, 2160, FitBlur, 3840, 1646},
+		{1280, 720, 3840, 2160, FitMirror, 3840, 2160}, // same aspect ratio
+	}
+	for _, c := range cases {
+		w, h := ip.UpscaleSize(c.w, c.h, c.tw, c.th, c.mode)
+		if w != c.wantW || h != c.wantH {
+			t.Errorf("UpscaleSize(%dx%d → %dx%d, %q) = %dx%d, want %dx%d", c.w, c.h, c.tw, c.th, c.mode, w, h, c.wantW, c.wantH)
+		}
+	}
+}
+
+func TestFitModes(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+
+	// A 200x100 landscape with its subject off to the left
+	src := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.RGBA{200, 200, 200, 255}), image.Point{}, draw.Src)
+	for y := 35; y < 65; y++ {
+		for x := 20; x < 50; x++ {
+			src.SetRGBA(x, y, subjectRed)
+		}
+	}
+
+	fit := func(mode, fill string, width, height int) image.Image {
+		t.Helper()
+		out, err := ip.Fit(src, width, height, ProcessingOptions{FitMode: mode, FillColor: fill})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := out.Bounds(); b.Dx() != width || b.Dy() != height {
+			t.Fatalf("%s: fitted to %v, want %dx%d", mode, b, width, height)
+		}
+		return out
+	}
+
+	if got := colorWidth(fit(FitStretch, "", 100, 100), subjectRed); got < 13 || got > 17 {
+		t.Errorf("stretched subject is %d pixels wide, want about 15", got)
+	}
+	if got := colorWidth(fit(FitCrop, "", 100, 100), subjectRed); got != 0 {
+		t.Errorf("centre crop kept %d pixels of the off-centre subject", got)
+	}
+	if got := colorWidth(fit(FitSmartCrop, "", 100, 100), subjectRed); got != 30 {
+		t.Errorf("smart crop kept %d pixels of the subject, want all 30", got)
+	}
+
+	// Padding modes place the image in the middle of a 200x200 canvas
+	isSource := func(mode string, out image.Image) {
+		t.Helper()
+		if got := colorWidth(out, subjectRed); got != 30 {
+			t.Errorf("%s: subject is %d pixels wide, want 30", mode, got)
+		}
+		if c := color.NRGBAModel.Convert(out.At(100, 100)).(color.NRGBA); c != (color.NRGBA{200, 200, 200, 255}) {
+			t.Errorf("%s: centre is %v, want the source background", mode, c)
+		}
+	}
+
+	out := fit(FitLetterbox, "#0f0", 200, 200)
+	isSource(FitLetterbox, out)
+	if c := color.NRGBAModel.Convert(out.At(0, 0)).(color.NRGBA); c != (color.NRGBA{0, 255, 0, 255}) {
+		t.Errorf("letterbox band is %v, want the fill colour", c)
+	}
+
+	out = fit(FitBlur, "", 200, 200)
+	isSource(FitBlur, out)
+	if r, g, b, _ := out.At(100, 10).RGBA(); r>>8 < 150 || g>>8 < 150 || b>>8 < 150 {
+		t.Errorf("blurred band is %d,%d,%d, want close to the source background", r>>8, g>>8, b>>8)
+	}
+
+	out = fit(FitMirror, "", 200, 200)
+	isSource(FitMirror, out)
+	for x := 0; x < 200; x++ {
+		// The image starts at row 50, so row 49 mirrors row 50
+		if out.At(x, 49) != out.At(x, 50) || out.At(x, 0) != out.At(x, 99) {
+			t.Fatalf("column %d is not mirrored about the image edge", x)
+		}
+	}
+	if got := colorWidth(out, subjectRed); got != 30 {
+		t.Errorf("mirrored subject is %d pixels wide, want 30", got)
+	}
+}
+
+func TestFitValidation(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	src := image.NewRGBA(image.Rect(0, 0, 20, 10))
+
+	for _, opts := range []ProcessingOptions{
+		{FitMode: "zoom"},
+		{FitMode: FitLetterbox, FillColor: "green"},
+		{FitMode: FitLetterbox, FillColor: "#12345"},
+	} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want an error", opts)
+		}
+		if _, err := ip.Fit(src, 10, 10, opts); err == nil {
+			t.Errorf("Fit with %+v succeeded, want an error", opts)
+		}
+	}
+
+	// An image already at the target size is not re-encoded
+	data := encodeImageToPNG(src)
+	out, changed, err := ip.FitBytes(data, 20, 10, ProcessingOptions{FitMode: FitCrop})
+	if err != nil || changed || !bytes.Equal(out, data) {
+		t.Errorf("FitBytes at the target size: changed = %v, err = %v, want the input back", changed, err)
+	}
+	out, changed, err = ip.FitBytes(data, 10, 10, ProcessingOptions{FitMode: FitCrop})
+	if err != nil || !changed {
+		t.Fatalf("FitBytes: changed = %v, err = %v", changed, err)
+	}
+	if w, h, format, _ := ip.DecodeDimensions(out); w != 10 || h != 10 || format != "png" {
+		t.Errorf("FitBytes gave a %dx%d %s, want a 10x10 png", w, h, format)
+	}
+}
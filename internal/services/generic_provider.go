@@ -0,0 +1,412 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GenericProviderDefinition describes a REST image API declaratively, so new
+// sources can be added with a JSON file instead of Go code.
+//
+// Example:
+//
+//	{
+//	  "name": "Openverse",
+//	  "baseURL": "https://api.openverse.org/v1/images/",
+//	  "params": {"query": "q", "page": "page", "perPage": "page_size"},
+//	  "staticParams": {"mature": "false"},
+//	  "resultsPath": "results",
+//	  "totalPath": "result_count",
+//	  "hosts": ["live.staticflickr.com", "upload.wikimedia.org"],
+//	  "fields": {
+//	    "id": "id", "url": "foreign_landing_url", "downloadURL": "url",
+//	    "previewURL": "thumbnail", "width": "width", "height": "height",
+//	    "author": "creator", "tags": "tags.name", "license": "license"
+//	  }
+//	}
+type GenericProviderDefinition struct {
+	Name         string            `json:"name"`
+	BaseURL      string            `json:"baseURL"`
+	Params       GenericParams     `json:"params"`
+	StaticParams map[string]string `json:"staticParams"`
+	Headers      map[string]string `json:"headers"`
+	Auth         *GenericAuth      `json:"auth"`
+	ResultsPath  string            `json:"resultsPath"`
+	TotalPath    string            `json:"totalPath"` // optional path to the total match count
+	Fields       GenericFields     `json:"fields"`
+
+	// Hosts lists the domains images are served from, besides the API host
+	Hosts []string `json:"hosts"`
+}
+
+// GenericParams maps SearchOptions to the API's query parameter names.
+// Empty names are not sent.
+type GenericParams struct {
+	Query       string `json:"query"`
+	Page        string `json:"page"`
+	PerPage     string `json:"perPage"`
+	MinWidth    string `json:"minWidth"`
+	MinHeight   string `json:"minHeight"`
+	Orientation string `json:"orientation"`
+	Category    string `json:"category"`
+
+	// OrientationValues translates "horizontal"/"vertical" to API values
+	OrientationValues map[string]string `json:"orientationValues"`
+}
+
+// GenericAuth describes how the API key is sent
+type GenericAuth struct {
+	Type   string `json:"type"`   // "header" or "query"
+	Name   string `json:"name"`   // header or parameter name
+	Prefix string `json:"prefix"` // e.g. "Bearer " or "Client-ID "
+	Key    string `json:"key"`    // inline key
+	KeyEnv string `json:"keyEnv"` // environment variable holding the key, overrides Key
+}
+
+// GenericFields holds dot-separated paths into each result object.
+// Numeric segments index arrays; a path that crosses an array without an
+// index collects the value from every element (e.g. "tags.title").
+type GenericFields struct {
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	DownloadURL string `json:"downloadURL"`
+	PreviewURL  string `json:"previewURL"`
+	Width       string `json:"width"`
+	Height      string `json:"height"`
+	Author      string `json:"author"`
+	AuthorURL   string `json:"authorURL"`
+	Tags        string `json:"tags"`
+	License     string `json:"license"`
+	LicenseURL  string `json:"licenseURL"`
+}
+
+// GenericProvider implements APIProvider from a GenericProviderDefinition
+type GenericProvider struct {
+	def    GenericProviderDefinition
+	client *ProviderClient
+	ctx    context.Context
+}
+
+// NewGenericProvider creates a provider from a definition
+func NewGenericProvider(ctx context.Context, def GenericProviderDefinition) (*GenericProvider, error) {
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return &GenericProvider{
+		def:    def,
+		client: NewProviderClient(def.Name, 30*time.Second),
+		ctx:    ctx,
+	}, nil
+}
+
+// Validate checks that a definition has the fields needed to search
+func (d GenericProviderDefinition) Validate() error {
+	if trim(d.Name) == "" {
+		return fmt.Errorf("provider definition is missing a name")
+	}
+	u, err := url.Parse(d.BaseURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("provider %s: baseURL must be an absolute http(s) URL", d.Name)
+	}
+	if d.Params.Query == "" {
+		return fmt.Errorf("provider %s: params.query is required", d.Name)
+	}
+	if d.Fields.DownloadURL == "" {
+		return fmt.Errorf("provider %s: fields.downloadURL is required", d.Name)
+	}
+	if d.Auth != nil && d.Auth.Type != "header" && d.Auth.Type != "query" {
+		return fmt.Errorf("provider %s: auth.type must be \"header\" or \"query\"", d.Name)
+	}
+	return nil
+}
+
+// LoadGenericProviders reads every *.json definition in dir. A missing
+// directory yields no providers; invalid files are reported individually
+// so one bad definition does not block the others.
+func LoadGenericProviders(ctx context.Context, dir string) ([]*GenericProvider, []error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to list provider definitions: %w", err)}
+	}
+	sort.Strings(paths)
+
+	providers := []*GenericProvider{}
+	errs := []error{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read %s: %w", path, err))
+			continue
+		}
+		var def GenericProviderDefinition
+		if err := json.Unmarshal(data, &def); err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse %s: %w", path, err))
+			continue
+		}
+		provider, err := NewGenericProvider(ctx, def)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers, errs
+}
+
+// GetName returns the provider name from the definition
+func (p *GenericProvider) GetName() string {
+	return p.def.Name
+}
+
+// Search queries the API and maps results through the field paths
+func (p *GenericProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	return p.SearchContext(p.ctx, query, options)
+}
+
+// SearchContext is Search, cancelled with ctx
+func (p *GenericProvider) SearchContext(ctx context.Context, query string, options SearchOptions) (*SearchPage, error) {
+	params := url.Values{}
+	for key, value := range p.def.StaticParams {
+		params.Set(key, value)
+	}
+
+	setParam := func(name, value string) {
+		if name != "" && value != "" {
+			params.Set(name, value)
+		}
+	}
+	setInt := func(name string, value int) {
+		if value > 0 {
+			setParam(name, strconv.Itoa(value))
+		}
+	}
+	setParam(p.def.Params.Query, query)
+	setInt(p.def.Params.Page, options.Page)
+	setInt(p.def.Params.PerPage, options.PerPage)
+	setInt(p.def.Params.MinWidth, options.MinWidth)
+	setInt(p.def.Params.MinHeight, options.MinHeight)
+	setParam(p.def.Params.Category, options.Category)
+	if options.Orientation != "" && options.Orientation != "all" {
+		orientation := options.Orientation
+		if mapped, ok := p.def.Params.OrientationValues[orientation]; ok {
+			orientation = mapped
+		}
+		setParam(p.def.Params.Orientation, orientation)
+	}
+
+	key := p.apiKey()
+	if p.def.Auth != nil && p.def.Auth.Type == "query" && key != "" {
+		params.Set(p.def.Auth.Name, p.def.Auth.Prefix+key)
+	}
+
+	fullURL := p.def.BaseURL
+	if encoded := params.Encode(); encoded != "" {
+		separator := "?"
+		if strings.Contains(fullURL, "?") {
+			separator = "&"
+		}
+		fullURL += separator + encoded
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, value := range p.def.Headers {
+		req.Header.Set(name, value)
+	}
+	if p.def.Auth != nil && p.def.Auth.Type == "header" && key != "" {
+		req.Header.Set(p.def.Auth.Name, p.def.Auth.Prefix+key)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	items, ok := body.([]interface{})
+	if p.def.ResultsPath != "" {
+		items, ok = lookupPath(body, p.def.ResultsPath).([]interface{})
+	}
+	if !ok {
+		return nil, fmt.Errorf("response has no result list at %q", p.def.ResultsPath)
+	}
+
+	images := make([]ImageResult, 0, len(items))
+	for _, item := range items {
+		img := p.mapResult(item)
+		if img.DownloadURL == "" {
+			continue
+		}
+		if img.Width > 0 && img.Height > 0 &&
+			(img.Width < options.MinWidth || img.Height < options.MinHeight) {
+			continue
+		}
+		images = append(images, img)
+	}
+
+	page, perPage := pageBounds(options, len(items))
+	result := &SearchPage{Results: images, Page: page, PerPage: perPage}
+	if p.def.TotalPath != "" {
+		result.Total, _ = strconv.Atoi(valueToString(lookupPath(body, p.def.TotalPath)))
+		result.TotalHits = result.Total
+	}
+	if result.Total > 0 {
+		result.HasMore = page*perPage < result.Total
+	} else {
+		// Without a total, a full page suggests there is another one
+		result.HasMore = len(items) > 0 && len(items) >= perPage
+	}
+	return result, nil
+}
+
+// MatchesURL reports whether u is on the API host or one of the declared image hosts
+func (p *GenericProvider) MatchesURL(u *url.URL) bool {
+	hosts := append([]string{}, p.def.Hosts...)
+	if base, err := url.Parse(p.def.BaseURL); err == nil {
+		hosts = append(hosts, strings.ToLower(base.Hostname()))
+	}
+	return hostMatches(u, hosts...)
+}
+
+// DownloadFile streams an image to a staging file
+func (p *GenericProvider) DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error) {
+	return d.Fetch(p.ctx, p.client, imageURL, nil, progress)
+}
+
+// Download downloads an image from URL
+func (p *GenericProvider) Download(imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(p.ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	return data, nil
+}
+
+// apiKey resolves the configured key, preferring the environment variable
+func (p *GenericProvider) apiKey() string {
+	if p.def.Auth == nil {
+		return ""
+	}
+	if p.def.Auth.KeyEnv != "" {
+		if key := os.Getenv(p.def.Auth.KeyEnv); key != "" {
+			return key
+		}
+	}
+	return p.def.Auth.Key
+}
+
+// mapResult converts one decoded result object into an ImageResult
+func (p *GenericProvider) mapResult(item interface{}) ImageResult {
+	f := p.def.Fields
+	str := func(path string) string {
+		if path == "" {
+			return ""
+		}
+		return valueToString(lookupPath(item, path))
+	}
+	num := func(path string) int {
+		n, _ := strconv.Atoi(str(path))
+		return n
+	}
+
+	tags := []string{}
+	if f.Tags != "" {
+		switch v := lookupPath(item, f.Tags).(type) {
+		case []interface{}:
+			for _, tag := range v {
+				if s := trim(valueToString(tag)); s != "" {
+					tags = append(tags, s)
+				}
+			}
+		case string:
+			tags = parseTags(v)
+		}
+	}
+
+	return ImageResult{
+		ID:          str(f.ID),
+		URL:         str(f.URL),
+		DownloadURL: str(f.DownloadURL),
+		PreviewURL:  str(f.PreviewURL),
+		Width:       num(f.Width),
+		Height:      num(f.Height),
+		Author:      str(f.Author),
+		AuthorURL:   str(f.AuthorURL),
+		Source:      p.def.Name,
+		Tags:        tags,
+		License:     str(f.License),
+		LicenseURL:  str(f.LicenseURL),
+	}
+}
+
+// lookupPath resolves a dot-separated path in decoded JSON. Crossing an
+// array without an index maps the rest of the path over its elements.
+func lookupPath(value interface{}, path string) interface{} {
+	if path == "" {
+		return value
+	}
+	segment, rest, _ := strings.Cut(path, ".")
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return lookupPath(v[segment], rest)
+	case []interface{}:
+		if index, err := strconv.Atoi(segment); err == nil {
+			if index < 0 || index >= len(v) {
+				return nil
+			}
+			return lookupPath(v[index], rest)
+		}
+		collected := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			if found := lookupPath(elem, path); found != nil {
+				collected = append(collected, found)
+			}
+		}
+		return collected
+	default:
+		return nil
+	}
+}
+
+// valueToString formats a decoded JSON scalar; integral numbers have no decimals
+func valueToString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupPath(t *testing.T) {
+	doc := map[string]interface{}{
+		"id":   float64(42),
+		"user": map[string]interface{}{"name": "Ana"},
+		"urls": []interface{}{"a.jpg", "b.jpg"},
+		"tags": []interface{}{
+			map[string]interface{}{"title": "sea"},
+			map[string]interface{}{"title": "sky"},
+		},
+	}
+
+	if got := valueToString(lookupPath(doc, "id")); got != "42" {
+		t.Errorf("id = %q", got)
+	}
+	if got := valueToString(lookupPath(doc, "user.name")); got != "Ana" {
+		t.Errorf("user.name = %q", got)
+	}
+	if got := valueToString(lookupPath(doc, "urls.1")); got != "b.jpg" {
+		t.Errorf("urls.1 = %q", got)
+	}
+	if got, ok := lookupPath(doc, "tags.title").([]interface{}); !ok || len(got) != 2 {
+		t.Errorf("tags.title = %v", got)
+	}
+	if got := lookupPath(doc, "missing.path"); got != nil {
+		t.Errorf("missing.path = %v", got)
+	}
+}
+
+func TestGenericProviderSearch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("q") != "forest" || r.URL.Query().Get("size") != "10" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"data":{"items":[
+			{"id":7,"link":"https://example.com/7","img":{"full":"https://cdn.example.com/7.jpg","w":4000,"h":3000},
+			 "by":"Ana","labels":[{"name":"forest"},{"name":"fog"}]},
+			{"id":8,"img":{"w":100,"h":100}}
+		]}}`))
+	}))
+	defer server.Close()
+
+	def := GenericProviderDefinition{
+		Name:        "Example",
+		BaseURL:     server.URL,
+		Params:      GenericParams{Query: "q", PerPage: "size"},
+		Auth:        &GenericAuth{Type: "header", Name: "Authorization", Prefix: "Bearer ", Key: "secret"},
+		ResultsPath: "data.items",
+		Fields: GenericFields{
+			ID: "id", URL: "link", DownloadURL: "img.full",
+			Width: "img.w", Height: "img.h", Author: "by", Tags: "labels.name",
+		},
+	}
+	p, err := NewGenericProvider(context.Background(), def)
+	if err != nil {
+		t.Fatalf("NewGenericProvider failed: %v", err)
+	}
+
+	page, err := p.Search("forest", SearchOptions{Page: 1, PerPage: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if page.HasMore {
+		t.Error("a short page without a total should not report more results")
+	}
+	results := page.Results
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result (entries without downloadURL are dropped), got %d", len(results))
+	}
+	got := results[0]
+	if got.ID != "7" || got.Width != 4000 || got.Height != 3000 || got.Author != "Ana" ||
+		got.Source != "Example" || len(got.Tags) != 2 {
+		t.Errorf("unexpected result %+v", got)
+	}
+}
+
+func TestLoadGenericProviders(t *testing.T) {
+	dir := t.TempDir()
+	valid := `{"name":"Valid","baseURL":"https://api.example.com/search",
+		"params":{"query":"q"},"fields":{"downloadURL":"url"}}`
+	os.WriteFile(filepath.Join(dir, "valid.json"), []byte(valid), 0644)
+	os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{`), 0644)
+	os.WriteFile(filepath.Join(dir, "incomplete.json"), []byte(`{"name":"NoURL"}`), 0644)
+
+	providers, errs := LoadGenericProviders(context.Background(), dir)
+	if len(providers) != 1 || providers[0].GetName() != "Valid" {
+		t.Errorf("expected only the valid provider, got %d", len(providers))
+	}
+	if len(errs) != 2 {
+		t.Errorf("expected 2 errors, got %v", errs)
+	}
+
+	providers, errs = LoadGenericProviders(context.Background(), filepath.Join(dir, "missing"))
+	if len(providers) != 0 || len(errs) != 0 {
+		t.Errorf("missing directory should yield nothing, got %d providers, %v", len(providers), errs)
+	}
+}
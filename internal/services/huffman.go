@@ -0,0 +1,128 @@
+package services
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// huffmanLengths returns Huffman code lengths for symbols with the given
+// frequencies, none longer than maxBits. Unused symbols get no code; a
+// single used symbol gets a 1-bit code.
+//
+// Over-long codes are shortened as in JPEG's Annex K.3: the length counts
+// are rebalanced, then handed out to the symbols by frequency.
+func huffmanLengths(freqs []int, maxBits int) []uint8 {
+	lengths := make([]uint8, len(freqs))
+	var used []int
+	for s, f := range freqs {
+		if f > 0 {
+			used = append(used, s)
+		}
+	}
+	switch len(used) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[used[0]] = 1
+		return lengths
+	}
+
+	// Plain Huffman tree; only the depth of each leaf is kept
+	h := &huffHeap{}
+	parent := make([]int, len(used), 2*len(used))
+	for i, s := range used {
+		*h = append(*h, huffNode{freq: freqs[s], id: i})
+		parent[i] = -1
+	}
+	heap.Init(h)
+	for h.Len() > 1 {
+		a, b := heap.Pop(h).(huffNode), heap.Pop(h).(huffNode)
+		id := len(parent)
+		parent = append(parent, -1)
+		parent[a.id], parent[b.id] = id, id
+		heap.Push(h, huffNode{freq: a.freq + b.freq, id: id})
+	}
+	depth := make([]int, len(used))
+	maxDepth := 0
+	for i := range used {
+		for n := parent[i]; n >= 0; n = parent[n] {
+			depth[i]++
+		}
+		maxDepth = max(maxDepth, depth[i])
+	}
+
+	counts := make([]int, max(maxDepth, maxBits)+1)
+	for _, d := range depth {
+		counts[d]++
+	}
+	for i := maxDepth; i > maxBits; i-- {
+		for counts[i] > 0 {
+			j := i - 2
+			for counts[j] == 0 {
+				j--
+			}
+			counts[i] -= 2
+			counts[i-1]++
+			counts[j+1] += 2
+			counts[j]--
+		}
+	}
+
+	// The most frequent symbols take the shortest codes
+	sort.SliceStable(used, func(i, j int) bool { return freqs[used[i]] > freqs[used[j]] })
+	n := 0
+	for length := 1; length <= maxBits; length++ {
+		for ; counts[length] > 0; counts[length]-- {
+			lengths[used[n]] = uint8(length)
+			n++
+		}
+	}
+	return lengths
+}
+
+// canonicalCodes assigns canonical Huffman codes to code lengths: shorter
+// codes first, equal lengths in symbol order
+func canonicalCodes(lengths []uint8) []uint16 {
+	var count [17]int
+	for _, l := range lengths {
+		count[l]++
+	}
+	count[0] = 0
+	var next [17]int
+	code := 0
+	for l := 1; l < len(next); l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	codes := make([]uint16, len(lengths))
+	for s, l := range lengths {
+		if l > 0 {
+			codes[s] = uint16(next[l])
+			next[l]++
+		}
+	}
+	return codes
+}
+
+type huffNode struct {
+	freq, id int
+}
+
+// huffHeap is a min-heap of tree nodes by frequency
+type huffHeap []huffNode
+
+func (h huffHeap) Len() int { return len(h) }
+func (h huffHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].id < h[j].id
+}
+func (h huffHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *huffHeap) Push(x any)   { *h = append(*h, x.(huffNode)) }
+func (h *huffHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
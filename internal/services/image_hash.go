@@ -0,0 +1,426 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DuplicateThreshold is the largest ImageHash distance at which two images
+// are treated as the same picture
+const DuplicateThreshold = 10
+
+// ImageHash holds two 64-bit perceptual hashes of an image. Both survive
+// resizing and re-encoding; requiring both to match keeps false positives
+// down on images with similar overall layouts.
+type ImageHash struct {
+	DHash uint64 `json:"dHash"` // gradient between neighbouring pixels
+	PHash uint64 `json:"pHash"` // low-frequency DCT coefficients
+}
+
+// Distance returns the number of differing bits in the less similar of
+// the two hashes
+func (h ImageHash) Distance(other ImageHash) int {
+	return max(bits.OnesCount64(h.DHash^other.DHash), bits.OnesCount64(h.PHash^other.PHash))
+}
+
+// IsDuplicate reports whether other is a near-duplicate of h
+func (h ImageHash) IsDuplicate(other ImageHash) bool {
+	return h.Distance(other) <= DuplicateThreshold
+}
+
+// HashImage computes the perceptual hashes of img
+func HashImage(img image.Image) ImageHash {
+	return ImageHash{DHash: dHash(img), PHash: pHash(img)}
+}
+
+// HashImageBytes decodes an encoded image and hashes it
+func HashImageBytes(data []byte) (ImageHash, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ImageHash{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return HashImage(img), nil
+}
+
+// FileHash is the hash of an image file on disk
+type FileHash struct {
+	Path   string    `json:"path"`
+	Width  int       `json:"width"`
+	Height int       `json:"height"`
+	Size   int64     `json:"size"`
+	Hash   ImageHash `json:"-"`
+
+	// Histogram is the image's colour distribution, see colorHistogram
+	Histogram []byte `json:"-"`
+}
+
+// HashImageFile decodes and hashes the image at path
+func HashImageFile(path string) (FileHash, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FileHash{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	fh, err := HashImageData(data)
+	if err != nil {
+		return FileHash{}, fmt.Errorf("failed to decode %s: %w", filepath.Base(path), err)
+	}
+	fh.Path = path
+	return fh, nil
+}
+
+// HashImageData decodes an encoded image and returns its hashes and
+// histogram. The result has no Path.
+func HashImageData(data []byte) (FileHash, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return FileHash{}, err
+	}
+	b := img.Bounds()
+	return FileHash{
+		Width:     b.Dx(),
+		Height:    b.Dy(),
+		Size:      int64(len(data)),
+		Hash:      HashImage(img),
+		Histogram: colorHistogram(img),
+	}, nil
+}
+
+// DuplicateGroup is a set of files showing the same picture. Files are
+// ordered best first: highest resolution, then largest file.
+type DuplicateGroup struct {
+	Files []FileHash `json:"files"`
+}
+
+// FindDuplicates groups files whose hashes are near-duplicates. Similarity
+// is transitive within a group, so a chain of small edits ends up together.
+func FindDuplicates(files []FileHash) []DuplicateGroup {
+	parent := make([]int, len(files))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range files {
+		for j := i + 1; j < len(files); j++ {
+			if files[i].Hash.IsDuplicate(files[j].Hash) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	members := map[int][]FileHash{}
+	for i, f := range files {
+		root := find(i)
+		members[root] = append(members[root], f)
+	}
+	var groups []DuplicateGroup
+	for _, group := range members {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			a, b := group[i], group[j]
+			if a.Width*a.Height != b.Width*b.Height {
+				return a.Width*a.Height > b.Width*b.Height
+			}
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+			return a.Path < b.Path
+		})
+		groups = append(groups, DuplicateGroup{Files: group})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Files[0].Path < groups[j].Files[0].Path })
+	return groups
+}
+
+// hashEntry is a cached FileHash, valid while the file is unchanged
+type hashEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Width   int       `json:"width"`
+	Height  int       `json:"height"`
+	Hash    ImageHash `json:"hash"`
+	// Histogram is stored as base64; entries written before it existed
+	// are rehashed
+	Histogram []byte `json:"histogram"`
+}
+
+// HashCache remembers file hashes by path, size and modification time so
+// rescanning a folder only decodes new or changed images
+type HashCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]hashEntry
+}
+
+// NewHashCache loads the cache stored at path, if the file exists. An
+// empty path keeps the cache in memory only.
+func NewHashCache(path string) (*HashCache, error) {
+	c := &HashCache{path: path, entries: map[string]hashEntry{}}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("failed to read image hashes: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		c.entries = map[string]hashEntry{}
+		return c, fmt.Errorf("failed to parse image hashes: %w", err)
+	}
+	return c, nil
+}
+
+// HashFile returns the hash of the image at path, decoding it only if it
+// changed since it was last hashed
+func (c *HashCache) HashFile(path string) (FileHash, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileHash{}, err
+	}
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = path
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) && len(entry.Histogram) == histogramBins {
+		return FileHash{Path: path, Width: entry.Width, Height: entry.Height, Size: entry.Size, Hash: entry.Hash, Histogram: entry.Histogram}, nil
+	}
+
+	fh, err := HashImageFile(path)
+	if err != nil {
+		return FileHash{}, err
+	}
+	c.mu.Lock()
+	c.entries[key] = hashEntry{
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		Width:     fh.Width,
+		Height:    fh.Height,
+		Hash:      fh.Hash,
+		Histogram: fh.Histogram,
+	}
+	c.mu.Unlock()
+	return fh, nil
+}
+
+// HashDir hashes the images in dir, descending into subfolders when
+// recursive is set. Files that cannot be decoded are left out.
+func (c *HashCache) HashDir(dir string, recursive bool) ([]FileHash, error) {
+	var files []FileHash
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil // unreadable entry, keep going
+		}
+		if d.IsDir() {
+			if path != dir && (!recursive || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !localImageExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		if fh, err := c.HashFile(path); err == nil {
+			files = append(files, fh)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// Dirs returns the folders holding the files in the cache
+func (c *HashCache) Dirs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seen := map[string]bool{}
+	var dirs []string
+	for path := range c.entries {
+		if dir := filepath.Dir(path); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Save writes the cache to disk, dropping entries for deleted files
+func (c *HashCache) Save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	for path := range c.entries {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			delete(c.entries, path)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save image hashes: %w", err)
+	}
+	return nil
+}
+
+// dHash sets one bit per pixel of a 9×8 thumbnail: whether it is darker
+// than its right-hand neighbour
+func dHash(img image.Image) uint64 {
+	gray := grayThumbnail(img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray[y*9+x] < gray[y*9+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// pHash sets one bit per coefficient in the top-left 8×8 block of a 32×32
+// thumbnail's DCT: whether it is above the block's median
+func pHash(img image.Image) uint64 {
+	const size, block = 32, 8
+	gray := grayThumbnail(img, size, size)
+
+	var cosines [block][size]float64
+	for u := 0; u < block; u++ {
+		for x := 0; x < size; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * size))
+		}
+	}
+
+	// The 2D DCT is separable: transform the rows, then the columns
+	var rows [size][block]float64
+	for y := 0; y < size; y++ {
+		for u := 0; u < block; u++ {
+			var sum float64
+			for x := 0; x < size; x++ {
+				sum += gray[y*size+x] * cosines[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	coeffs := make([]float64, 0, block*block)
+	for v := 0; v < block; v++ {
+		for u := 0; u < block; u++ {
+			var sum float64
+			for y := 0; y < size; y++ {
+				sum += rows[y][u] * cosines[v][y]
+			}
+			coeffs = append(coeffs, sum)
+		}
+	}
+
+	// The DC term is the overall brightness and would skew the median
+	sorted := append([]float64(nil), coeffs[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for _, c := range coeffs {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// grayThumbnail shrinks img to w×h luminance values. Each cell averages at
+// most 8×8 sampled pixels, so large images hash as fast as small ones.
+func grayThumbnail(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	out := make([]float64, w*h)
+	for ty := 0; ty < h; ty++ {
+		y0 := b.Min.Y + ty*b.Dy()/h
+		y1 := max(b.Min.Y+(ty+1)*b.Dy()/h, y0+1)
+		stepY := max((y1-y0)/8, 1)
+		for tx := 0; tx < w; tx++ {
+			x0 := b.Min.X + tx*b.Dx()/w
+			x1 := max(b.Min.X+(tx+1)*b.Dx()/w, x0+1)
+			stepX := max((x1-x0)/8, 1)
+
+			var sum float64
+			var n int
+			for y := y0; y < y1; y += stepY {
+				for x := x0; x < x1; x += stepX {
+					r, g, bl, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+					n++
+				}
+			}
+			out[ty*w+tx] = sum / float64(n)
+		}
+	}
+	return out
+}
+
+// histogramBins is the number of colour histogram bins: 4 levels per channel
+const histogramBins = 64
+
+// colorHistogram counts sampled pixels into 4×4×4 RGB bins. Each bin holds
+// its share of the pixels scaled to 0-255, so histograms of different
+// image sizes compare directly.
+func colorHistogram(img image.Image) []byte {
+	const samples = 64
+	b := img.Bounds()
+	var counts [histogramBins]int
+	var total int
+	for sy := 0; sy < samples; sy++ {
+		y := b.Min.Y + (2*sy+1)*b.Dy()/(2*samples)
+		for sx := 0; sx < samples; sx++ {
+			x := b.Min.X + (2*sx+1)*b.Dx()/(2*samples)
+			r, g, bl, _ := img.At(x, y).RGBA()
+			counts[(r>>14)<<4|(g>>14)<<2|bl>>14]++
+			total++
+		}
+	}
+	histogram := make([]byte, histogramBins)
+	for i, n := range counts {
+		histogram[i] = byte((n*255 + total/2) / total)
+	}
+	return histogram
+}
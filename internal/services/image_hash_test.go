@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createTestScene draws random coloured blocks, so different seeds give
+// pictures with different structure
+func createTestScene(width, height int, seed int64) image.Image {
+	rng := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < 12; i++ {
+		x0, y0 := rng.Intn(width), rng.Intn(height)
+		x1, y1 := x0+rng.Intn(width/2)+width/8, y0+rng.Intn(height/2)+height/8
+		c := color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+		for y := y0; y < min(y1, height); y++ {
+			for x := x0; x < min(x1, width); x++ {
+				img.Set(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+// resizeNearest scales img to width×height by nearest-neighbour sampling
+func resizeNearest(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			out.Set(x, y, img.At(b.Min.X+x*b.Dx()/width, b.Min.Y+y*b.Dy()/height))
+		}
+	}
+	return out
+}
+
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHashImageSurvivesResizeAndReencode(t *testing.T) {
+	original := createTestScene(640, 400, 1)
+	hash := HashImage(original)
+
+	resized, err := HashImageBytes(encodeJPEG(t, resizeNearest(original, 320, 200), 70))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hash.IsDuplicate(resized) {
+		t.Errorf("resized JPEG copy has distance %d, want at most %d", hash.Distance(resized), DuplicateThreshold)
+	}
+
+	for seed := int64(2); seed < 6; seed++ {
+		other := HashImage(createTestScene(640, 400, seed))
+		if hash.IsDuplicate(other) {
+			t.Errorf("different picture (seed %d) has distance %d, want above %d", seed, hash.Distance(other), DuplicateThreshold)
+		}
+	}
+}
+
+func TestFindDuplicatesInFolder(t *testing.T) {
+	dir := t.TempDir()
+	scene := createTestScene(640, 400, 7)
+	os.WriteFile(filepath.Join(dir, "beach.png"), encodeImageToPNG(scene), 0644)
+	os.WriteFile(filepath.Join(dir, "beach-small.jpg"), encodeJPEG(t, resizeNearest(scene, 320, 200), 80), 0644)
+	os.WriteFile(filepath.Join(dir, "forest.png"), encodeImageToPNG(createTestScene(640, 400, 8)), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0644)
+	os.WriteFile(filepath.Join(dir, "broken.png"), []byte("not a png"), 0644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "beach-copy.png"), encodeImageToPNG(scene), 0644)
+
+	cachePath := filepath.Join(t.TempDir(), "hashes.json")
+	cache, err := NewHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := cache.HashDir(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("hashed %d files without recursion, want 3", len(files))
+	}
+	groups := FindDuplicates(files)
+	if len(groups) != 1 || len(groups[0].Files) != 2 {
+		t.Fatalf("groups = %+v, want one pair", groups)
+	}
+	if got := filepath.Base(groups[0].Files[0].Path); got != "beach.png" {
+		t.Errorf("best copy = %s, want the full-resolution beach.png", got)
+	}
+
+	files, _ = cache.HashDir(dir, true)
+	if groups := FindDuplicates(files); len(groups) != 1 || len(groups[0].Files) != 3 {
+		t.Errorf("recursive groups = %+v, want one group of three", groups)
+	}
+
+	// Hashes are reused from disk while the files are unchanged
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.entries) != 4 {
+		t.Errorf("reloaded %d cached hashes, want 4", len(reloaded.entries))
+	}
+	os.Remove(filepath.Join(dir, "forest.png"))
+	reloaded.Save()
+	if len(reloaded.entries) != 3 {
+		t.Errorf("%d cached hashes after a file was deleted, want 3", len(reloaded.entries))
+	}
+}
@@ -6,12 +6,18 @@ import (
 	"encoding/base64"
 	"fmt"
 	"image"
-	"image/jpeg"
-	"image/png"
+	_ "image/jpeg"
+	_ "image/png"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
+
+	_ "golang.org/x/image/bmp"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
 // ImageProcessor handles all image processing operations
@@ -28,12 +34,44 @@ func NewImageProcessor(ctx context.Context) *ImageProcessor {
 
 // ProcessingOptions contains options for image processing
 type ProcessingOptions struct {
-	TargetResolution string // "4K", "5K", "8K"
-	AspectRatio      string // "16:9", "21:9", "auto"
-	UseSeamCarving   bool   // true for content-aware, false for crop
-	Quality          int    // JPEG quality (1-100)
+	TargetResolution string `json:"targetResolution,omitempty"` // "4K", "5K", "8K"
+	AspectRatio      string `json:"aspectRatio,omitempty"`      // "16:9", "21:9", "auto"
+	UseSeamCarving   bool   `json:"useSeamCarving,omitempty"`   // content-aware aspect change before upscaling
+	Quality          int    `json:"quality,omitempty"`          // JPEG quality (1-100)
+
+	// ProtectMask and RemoveMask are base64 images marking, in white, the
+	// parts seam carving must keep or carve away. Any size is scaled to
+	// the source.
+	ProtectMask string `json:"protectMask,omitempty"`
+	RemoveMask  string `json:"removeMask,omitempty"`
+
+	// FitMode is how the upscaled image fills a target of another aspect
+	// ratio, one of the Fit* constants; empty stretches it. FillColor is
+	// the "#rrggbb" letterbox colour, black by default.
+	FitMode   string `json:"fitMode,omitempty"`
+	FillColor string `json:"fillColor,omitempty"`
+
+	// Crop or FocalPoint pick the part of the source to keep, in normalised
+	// coordinates; a focal point keeps the largest window of the target
+	// aspect ratio around it. Rotate turns the source clockwise by a
+	// multiple of 90 degrees; the flips follow the rotation.
+	Crop       *CropRect   `json:"crop,omitempty"`
+	FocalPoint *FocalPoint `json:"focalPoint,omitempty"`
+	Rotate     int         `json:"rotate,omitempty"`
+	FlipH      bool        `json:"flipH,omitempty"`
+	FlipV      bool        `json:"flipV,omitempty"`
 }
 
+// maxSeamInsertion is the largest share by which seam carving grows the
+// source's narrow dimension; the rest of an aspect change is removed from
+// the other dimension
+const maxSeamInsertion = 0.5
+
+// maxSeamCarvePixels is the working size seam carving is done at. Larger
+// sources are scaled down first: carving takes time and memory in
+// proportion to the pixel count, and the upscaler restores the resolution.
+var maxSeamCarvePixels = 1920 * 1080
+
 // ProcessingResult contains the result of image processing
 type ProcessingResult struct {
 	ImageData   []byte
@@ -44,6 +82,105 @@ type ProcessingResult struct {
 	ProcessTime float64
 }
 
+// PrepareSource applies the options that work on the source image, before
+// upscaling, so the upscaler only spends time on pixels that are kept: the
+// crop, rotation and flips, then seam carving to the target aspect ratio,
+// which is too slow at the upscaled size. data is returned as-is, without
+// re-encoding, when no step applies; otherwise the result is PNG.
+func (ip *ImageProcessor) PrepareSource(data []byte, targetWidth, targetHeight int, opts ProcessingOptions) ([]byte, bool, error) {
+	carve := opts.UseSeamCarving && targetWidth > 0 && targetHeight > 0
+	if !carve && !opts.hasTransform() {
+		return data, false, nil
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, false, err
+	}
+	img, _, err := ip.LoadImageFromBytes(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Masks are drawn on the original source, so they are cropped and
+	// turned along with it
+	b := img.Bounds()
+	crop := sourceCrop(b.Dx(), b.Dy(), targetWidth, targetHeight, opts)
+	changed := opts.hasTransform()
+	if changed {
+		img = transformImage(img, crop, opts)
+	}
+
+	if carve {
+		b = img.Bounds()
+		width, height := seamCarveSize(b.Dx(), b.Dy(), targetWidth, targetHeight)
+		if width != b.Dx() || height != b.Dy() || opts.RemoveMask != "" {
+			if pixels := b.Dx() * b.Dy(); pixels > maxSeamCarvePixels {
+				scale := math.Sqrt(float64(maxSeamCarvePixels) / float64(pixels))
+				img = scaleImage(img, max(int(float64(b.Dx())*scale), 1), max(int(float64(b.Dy())*scale), 1), xdraw.CatmullRom)
+				b = img.Bounds()
+				width, height = seamCarveSize(b.Dx(), b.Dy(), targetWidth, targetHeight)
+			}
+			var masks SeamCarveOptions
+			if masks.Protect, err = ip.decodeMask(opts.ProtectMask, crop, opts); err != nil {
+				return nil, false, fmt.Errorf("invalid protect mask: %w", err)
+			}
+			if masks.Remove, err = ip.decodeMask(opts.RemoveMask, crop, opts); err != nil {
+				return nil, false, fmt.Errorf("invalid remove mask: %w", err)
+			}
+			if img, err = ip.SeamCarve(img, width, height, masks); err != nil {
+				return nil, false, err
+			}
+			changed = true
+		}
+	}
+
+	if !changed {
+		return data, false, nil
+	}
+	out, err := ip.EncodeImage(img, "png", 100)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// decodeMask decodes a base64 mask image and gives it the source's crop
+// and orientation; an empty string is no mask
+func (ip *ImageProcessor) decodeMask(data string, crop *CropRect, opts ProcessingOptions) (image.Image, error) {
+	if data == "" {
+		return nil, nil
+	}
+	raw, err := ip.ConvertFromBase64(data)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := ip.LoadImageFromBytes(raw)
+	if err != nil {
+		return nil, err
+	}
+	if opts.hasTransform() {
+		img = transformImage(img, crop, opts)
+	}
+	return img, nil
+}
+
+// seamCarveSize returns the size a width×height source is carved to for
+// the aspect ratio of targetWidth×targetHeight. The narrow dimension grows
+// by seam insertion, which keeps every source pixel, by up to
+// maxSeamInsertion; any remaining difference is carved from the other.
+func seamCarveSize(width, height, targetWidth, targetHeight int) (int, int) {
+	target := float64(targetWidth) / float64(targetHeight)
+	source := float64(width) / float64(height)
+	switch {
+	case target > source:
+		w := min(int(math.Round(float64(height)*target)), int(float64(width)*(1+maxSeamInsertion)))
+		return w, min(int(math.Round(float64(w)/target)), height)
+	case target < source:
+		h := min(int(math.Round(float64(width)/target)), int(float64(height)*(1+maxSeamInsertion)))
+		return min(int(math.Round(float64(h)*target)), width), h
+	}
+	return width, height
+}
+
 // LoadImageFromBytes loads an image from byte array
 func (ip *ImageProcessor) LoadImageFromBytes(data []byte) (image.Image, string, error) {
 	img, format, err := image.Decode(bytes.NewReader(data))
@@ -53,26 +190,40 @@ func (ip *ImageProcessor) LoadImageFromBytes(data []byte) (image.Image, string,
 	return img, format, nil
 }
 
-// EncodeImage encodes an image to bytes
-func (ip *ImageProcessor) EncodeImage(img image.Image, format string, quality int) ([]byte, error) {
-	var buf bytes.Buffer
+// DecodeDimensions reads the width, height and format of an encoded image
+// without decoding its pixels
+func (ip *ImageProcessor) DecodeDimensions(data []byte) (int, int, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to decode image config: %w", err)
+	}
+	return cfg.Width, cfg.Height, format, nil
+}
 
-	switch format {
-	case "jpeg", "jpg":
-		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
-		if err != nil {
-			return nil, fmt.Errorf("failed to encode jpeg: %w", err)
-		}
-	case "png":
-		err := png.Encode(&buf, img)
-		if err != nil {
-			return nil, fmt.Errorf("failed to encode png: %w", err)
-		}
-	default:
-		return nil, fmt.Errorf("unsupported format: %s", format)
+// DecodeFileDimensions is DecodeDimensions for an image file on disk. Only
+// the header is read.
+func (ip *ImageProcessor) DecodeFileDimensions(path string) (int, int, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to open image: %w", err)
 	}
+	defer file.Close()
 
-	return buf.Bytes(), nil
+	cfg, format, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to decode image config: %w", err)
+	}
+	return cfg.Width, cfg.Height, format, nil
+}
+
+// EncodeImage encodes an image to bytes in the given format, using quality
+// for JPEG. Encode takes the other output settings.
+func (ip *ImageProcessor) EncodeImage(img image.Image, format string, quality int) ([]byte, error) {
+	out := OutputFormat{Format: format}
+	if out.name() == FormatJPEG {
+		out.Quality = min(max(quality, 1), 100)
+	}
+	return ip.Encode(img, out)
 }
 
 // ConvertToBase64 converts image bytes to base64 string
@@ -88,3 +88,20 @@ func TestConvertBase64RoundTrip(t *testing.T) {
 		t.Error("base64 round-trip failed: data mismatch")
 	}
 }
+
+func TestDecodeDimensions(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+
+	data := encodeImageToPNG(createTestImage(64, 36))
+	width, height, format, err := ip.DecodeDimensions(data)
+	if err != nil {
+		t.Fatalf("DecodeDimensions failed: %v", err)
+	}
+	if width != 64 || height != 36 || format != "png" {
+		t.Errorf("got %dx%d %s, want 64x36 png", width, height, format)
+	}
+
+	if _, _, _, err := ip.DecodeDimensions([]byte("not an image")); err == nil {
+		t.Error("expected error for invalid image data")
+	}
+}
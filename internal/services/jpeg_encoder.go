@@ -0,0 +1,557 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+)
+
+// Chroma subsampling modes for JPEG output
+const (
+	Subsampling420 = "4:2:0" // chroma at half width and height
+	Subsampling422 = "4:2:2" // chroma at half width
+	Subsampling444 = "4:4:4" // chroma at full resolution
+)
+
+// The standard library's JPEG encoder always subsamples to 4:2:0 and only
+// writes baseline files, so JPEG output goes through this encoder instead.
+// It also builds Huffman tables for each image rather than using the
+// example tables, which saves several percent.
+
+// Quantisation tables from the JPEG specification, Annex K, in natural order
+var jpegBaseQuant = [2][64]int{
+	{
+		16, 11, 10, 16, 24, 40, 51, 61,
+		12, 12, 14, 19, 26, 58, 60, 55,
+		14, 13, 16, 24, 40, 57, 69, 56,
+		14, 17, 22, 29, 51, 87, 80, 62,
+		18, 22, 37, 56, 68, 109, 103, 77,
+		24, 35, 55, 64, 81, 104, 113, 92,
+		49, 64, 78, 87, 103, 121, 120, 101,
+		72, 92, 95, 98, 112, 100, 103, 99,
+	},
+	{
+		17, 18, 24, 47, 99, 99, 99, 99,
+		18, 21, 26, 66, 99, 99, 99, 99,
+		24, 26, 56, 99, 99, 99, 99, 99,
+		47, 66, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// jpegZigzag maps zigzag order to natural order
+var jpegZigzag = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// jpegCosine[u][x] is C(u)/2·cos((2x+1)uπ/16), one factor of the 8x8 DCT
+var jpegCosine = func() (t [8][8]float32) {
+	for u := 0; u < 8; u++ {
+		c := 0.5
+		if u == 0 {
+			c = 0.5 / math.Sqrt2
+		}
+		for x := 0; x < 8; x++ {
+			t[u][x] = float32(c * math.Cos(float64(2*x+1)*float64(u)*math.Pi/16))
+		}
+	}
+	return t
+}()
+
+// jpegComponent is one colour plane of a jpegImage
+type jpegComponent struct {
+	id   byte
+	h, v int // sampling factors
+	tq   int // quantisation and Huffman table index
+
+	// Blocks across and down, padded to whole MCUs; the blocks of the
+	// component's own size are the ones coded in non-interleaved scans
+	bw, bh int
+	cw, ch int
+
+	// DCT coefficients, 64 per block in natural order, scaled by 8
+	coef []int16
+}
+
+// jpegImage holds an image's DCT coefficients, so it can be encoded at
+// several qualities without transforming it again
+type jpegImage struct {
+	width, height int
+	comps         [3]jpegComponent
+	mcuW, mcuH    int // MCUs across and down
+}
+
+// newJPEGImage converts img to YCbCr with the given chroma subsampling and
+// computes the DCT of every block. Transparent pixels are composited on
+// black, as the standard library does.
+func newJPEGImage(img image.Image, subsampling string) (*jpegImage, error) {
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("cannot encode an empty image")
+	}
+	if b.Dx() > 65535 || b.Dy() > 65535 {
+		return nil, fmt.Errorf("image too large for JPEG: %dx%d", b.Dx(), b.Dy())
+	}
+	hy, vy := 2, 2
+	switch subsampling {
+	case "", Subsampling420:
+	case Subsampling422:
+		vy = 1
+	case Subsampling444:
+		hy, vy = 1, 1
+	default:
+		return nil, fmt.Errorf("unknown chroma subsampling: %s", subsampling)
+	}
+
+	j := &jpegImage{width: b.Dx(), height: b.Dy()}
+	j.mcuW = (j.width + 8*hy - 1) / (8 * hy)
+	j.mcuH = (j.height + 8*vy - 1) / (8 * vy)
+	j.comps[0] = jpegComponent{id: 1, h: hy, v: vy, tq: 0}
+	j.comps[1] = jpegComponent{id: 2, h: 1, v: 1, tq: 1}
+	j.comps[2] = jpegComponent{id: 3, h: 1, v: 1, tq: 1}
+	for i := range j.comps {
+		c := &j.comps[i]
+		c.bw, c.bh = j.mcuW*c.h, j.mcuH*c.v
+		c.cw = ((j.width*c.h+hy-1)/hy + 7) / 8
+		c.ch = ((j.height*c.v+vy-1)/vy + 7) / 8
+		c.coef = make([]int16, c.bw*c.bh*64)
+	}
+
+	// Convert one row of MCUs at a time, replicating the edge pixels into
+	// the padding
+	rgba, ok := img.(*image.RGBA)
+	if !ok || rgba.Rect.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	}
+	stripW, stripH := j.mcuW*8*hy, 8*vy
+	var planes [3][]float32
+	for i := range planes {
+		planes[i] = make([]float32, stripW*stripH)
+	}
+	var block [64]float32
+	for my := 0; my < j.mcuH; my++ {
+		for y := 0; y < stripH; y++ {
+			sy := min(my*stripH+y, j.height-1)
+			row := rgba.Pix[sy*rgba.Stride:]
+			for x := 0; x < stripW; x++ {
+				sx := min(x, j.width-1) * 4
+				yy, cb, cr := color.RGBToYCbCr(row[sx], row[sx+1], row[sx+2])
+				planes[0][y*stripW+x] = float32(yy) - 128
+				planes[1][y*stripW+x] = float32(cb) - 128
+				planes[2][y*stripW+x] = float32(cr) - 128
+			}
+		}
+		for ci := range j.comps {
+			c := &j.comps[ci]
+			sx, sy := hy/c.h, vy/c.v // pixels averaged per sample
+			for by := 0; by < c.v; by++ {
+				for bx := 0; bx < c.bw; bx++ {
+					for y := 0; y < 8; y++ {
+						for x := 0; x < 8; x++ {
+							px, py := (bx*8+x)*sx, (by*8+y)*sy
+							var sum float32
+							for dy := 0; dy < sy; dy++ {
+								for dx := 0; dx < sx; dx++ {
+									sum += planes[ci][(py+dy)*stripW+px+dx]
+								}
+							}
+							block[y*8+x] = sum / float32(sx*sy)
+						}
+					}
+					n := ((my*c.v+by)*c.bw + bx) * 64
+					fdct(&block, c.coef[n:n+64])
+				}
+			}
+		}
+	}
+	return j, nil
+}
+
+// fdct computes the 8x8 DCT of block into out, scaled by 8
+func fdct(block *[64]float32, out []int16) {
+	var tmp [64]float32
+	for y := 0; y < 8; y++ {
+		for u := 0; u < 8; u++ {
+			var s float32
+			for x := 0; x < 8; x++ {
+				s += jpegCosine[u][x] * block[y*8+x]
+			}
+			tmp[y*8+u] = s
+		}
+	}
+	for u := 0; u < 8; u++ {
+		for v := 0; v < 8; v++ {
+			var s float32
+			for y := 0; y < 8; y++ {
+				s += jpegCosine[v][y] * tmp[y*8+u]
+			}
+			out[v*8+u] = int16(math.Round(float64(s * 8)))
+		}
+	}
+}
+
+// jpegQuantTables scales the standard tables for quality 1-100, as libjpeg
+// does
+func jpegQuantTables(quality int) [2][64]int {
+	quality = min(max(quality, 1), 100)
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	var q [2][64]int
+	for t := range q {
+		for i := range q[t] {
+			q[t][i] = min(max((jpegBaseQuant[t][i]*scale+50)/100, 1), 255)
+		}
+	}
+	return q
+}
+
+// jpegScan is one scan of the image: all components interleaved, or a
+// single one, and a range of zigzag coefficients
+type jpegScan struct {
+	comps  []int
+	ss, se int
+}
+
+// encode writes the image as a baseline or progressive JPEG
+func (j *jpegImage) encode(w io.Writer, quality int, progressive bool) error {
+	quant := jpegQuantTables(quality)
+
+	// Quantised coefficients in zigzag order
+	q := make([][]int16, len(j.comps))
+	for ci := range j.comps {
+		c := &j.comps[ci]
+		q[ci] = make([]int16, len(c.coef))
+		table := quant[c.tq]
+		for n := 0; n < len(c.coef); n += 64 {
+			for k, z := range jpegZigzag {
+				d := int32(table[z] * 8)
+				v := int32(c.coef[n+z])
+				if v >= 0 {
+					q[ci][n+k] = int16((v + d/2) / d)
+				} else {
+					q[ci][n+k] = int16(-((-v + d/2) / d))
+				}
+			}
+		}
+	}
+
+	scans := []jpegScan{{comps: []int{0, 1, 2}, ss: 0, se: 63}}
+	if progressive {
+		// DC first, then the low and high frequencies of each component
+		scans = []jpegScan{{comps: []int{0, 1, 2}, ss: 0, se: 0}}
+		for ci := range j.comps {
+			scans = append(scans, jpegScan{comps: []int{ci}, ss: 1, se: 5}, jpegScan{comps: []int{ci}, ss: 6, se: 63})
+		}
+	}
+
+	bw := &jpegBitWriter{w: bufio.NewWriter(w)}
+	bw.marker(0xd8, nil)
+	dqt := []byte{}
+	for t, table := range quant {
+		dqt = append(dqt, byte(t))
+		for _, z := range jpegZigzag {
+			dqt = append(dqt, byte(table[z]))
+		}
+	}
+	bw.marker(0xdb, dqt)
+
+	sof := []byte{8, byte(j.height >> 8), byte(j.height), byte(j.width >> 8), byte(j.width), 3}
+	for _, c := range j.comps {
+		sof = append(sof, c.id, byte(c.h<<4|c.v), byte(c.tq))
+	}
+	if progressive {
+		bw.marker(0xc2, sof)
+	} else {
+		bw.marker(0xc0, sof)
+	}
+
+	for _, scan := range scans {
+		// Count the symbols first to build this scan's tables
+		var stats jpegStats
+		j.codeScan(scan, q, &stats)
+		tables := stats.tables()
+
+		dht := []byte{}
+		for class := 0; class < 2; class++ {
+			for t := 0; t < 2; t++ {
+				if tables[class][t] != nil {
+					dht = append(dht, byte(class<<4|t))
+					dht = append(dht, tables[class][t].spec()...)
+				}
+			}
+		}
+		bw.marker(0xc4, dht)
+
+		sos := []byte{byte(len(scan.comps))}
+		for _, ci := range scan.comps {
+			t := byte(j.comps[ci].tq)
+			sos = append(sos, j.comps[ci].id, t<<4|t)
+		}
+		sos = append(sos, byte(scan.ss), byte(scan.se), 0)
+		bw.marker(0xda, sos)
+
+		bw.tables = tables
+		j.codeScan(scan, q, bw)
+		bw.pad()
+	}
+
+	bw.marker(0xd9, nil)
+	if bw.err != nil {
+		return bw.err
+	}
+	return bw.w.Flush()
+}
+
+// jpegSink receives the entropy-coded symbols of a scan. class is 0 for
+// DC and 1 for AC tables; extra holds n raw bits.
+type jpegSink interface {
+	emit(class, table int, symbol byte, extra uint32, n int)
+}
+
+// codeScan walks the blocks of a scan, sending their symbols to sink
+func (j *jpegImage) codeScan(scan jpegScan, q [][]int16, sink jpegSink) {
+	if len(scan.comps) > 1 {
+		// Interleaved: whole MCUs, each component's blocks in turn
+		var pred [3]int
+		for my := 0; my < j.mcuH; my++ {
+			for mx := 0; mx < j.mcuW; mx++ {
+				for _, ci := range scan.comps {
+					c := &j.comps[ci]
+					for v := 0; v < c.v; v++ {
+						for h := 0; h < c.h; h++ {
+							n := ((my*c.v+v)*c.bw + mx*c.h + h) * 64
+							block := q[ci][n : n+64]
+							pred[ci] = codeDC(sink, c.tq, block[0], pred[ci])
+							if scan.se > 0 {
+								codeAC(sink, c.tq, block[1:64])
+							}
+						}
+					}
+				}
+			}
+		}
+		return
+	}
+
+	// Non-interleaved AC scan over the component's own blocks; runs of
+	// blocks with nothing left in the band share one end-of-band code
+	ci := scan.comps[0]
+	c := &j.comps[ci]
+	eobRun := 0
+	flush := func() {
+		if eobRun > 0 {
+			n := bitLength(eobRun) - 1
+			sink.emit(1, c.tq, byte(n<<4), uint32(eobRun), n)
+			eobRun = 0
+		}
+	}
+	for by := 0; by < c.ch; by++ {
+		for bx := 0; bx < c.cw; bx++ {
+			n := (by*c.bw + bx) * 64
+			band := q[ci][n+scan.ss : n+scan.se+1]
+			run := 0
+			for _, v := range band {
+				if v == 0 {
+					run++
+					continue
+				}
+				flush()
+				for ; run > 15; run -= 16 {
+					sink.emit(1, c.tq, 0xf0, 0, 0)
+				}
+				s, bits := jpegMagnitude(int(v))
+				sink.emit(1, c.tq, byte(run<<4|s), bits, s)
+				run = 0
+			}
+			if run > 0 {
+				if eobRun++; eobRun == 0x7fff {
+					flush()
+				}
+			}
+		}
+	}
+	flush()
+}
+
+// codeDC codes a DC coefficient as the difference from pred
+func codeDC(sink jpegSink, table int, dc int16, pred int) int {
+	s, bits := jpegMagnitude(int(dc) - pred)
+	sink.emit(0, table, byte(s), bits, s)
+	return int(dc)
+}
+
+// codeAC codes the 63 AC coefficients of a baseline block
+func codeAC(sink jpegSink, table int, ac []int16) {
+	run := 0
+	for _, v := range ac {
+		if v == 0 {
+			run++
+			continue
+		}
+		for ; run > 15; run -= 16 {
+			sink.emit(1, table, 0xf0, 0, 0)
+		}
+		s, bits := jpegMagnitude(int(v))
+		sink.emit(1, table, byte(run<<4|s), bits, s)
+		run = 0
+	}
+	if run > 0 {
+		sink.emit(1, table, 0x00, 0, 0)
+	}
+}
+
+// jpegMagnitude returns the size category of v and its s-bit encoding
+func jpegMagnitude(v int) (int, uint32) {
+	if v < 0 {
+		s := bitLength(-v)
+		return s, uint32(v-1) & (1<<s - 1)
+	}
+	return bitLength(v), uint32(v)
+}
+
+func bitLength(v int) int {
+	n := 0
+	for ; v > 0; v >>= 1 {
+		n++
+	}
+	return n
+}
+
+// jpegStats counts symbol frequencies per table
+type jpegStats struct {
+	freq [2][2][]int
+}
+
+func (s *jpegStats) emit(class, table int, symbol byte, extra uint32, n int) {
+	if s.freq[class][table] == nil {
+		s.freq[class][table] = make([]int, 257)
+	}
+	s.freq[class][table][symbol]++
+}
+
+// tables builds a Huffman table for every table used
+func (s *jpegStats) tables() (t [2][2]*jpegHuffman) {
+	for class := range s.freq {
+		for table, freq := range s.freq[class] {
+			if freq != nil {
+				t[class][table] = newJPEGHuffman(freq)
+			}
+		}
+	}
+	return t
+}
+
+// jpegHuffman is a Huffman table of at most 16-bit codes
+type jpegHuffman struct {
+	lengths []uint8
+	codes   []uint16
+}
+
+// newJPEGHuffman builds a table for freq, whose entry 256 is reserved so
+// that no symbol gets the all-ones code JPEG forbids
+func newJPEGHuffman(freq []int) *jpegHuffman {
+	freq[256] = 1
+	lengths := huffmanLengths(freq, 16)
+	// The reserved symbol must take a longest code; it sorts last there
+	longest := 0
+	for s, l := range lengths {
+		if l > lengths[longest] || (l == lengths[longest] && l > 0) {
+			longest = s
+		}
+	}
+	lengths[256], lengths[longest] = lengths[longest], lengths[256]
+	return &jpegHuffman{lengths: lengths, codes: canonicalCodes(lengths)}
+}
+
+// spec returns the table as stored in a DHT segment, without the reserved
+// symbol
+func (h *jpegHuffman) spec() []byte {
+	counts := make([]byte, 16)
+	var values []byte
+	for l := 1; l <= 16; l++ {
+		for s := 0; s < 256; s++ {
+			if int(h.lengths[s]) == l {
+				counts[l-1]++
+				values = append(values, byte(s))
+			}
+		}
+	}
+	return append(counts, values...)
+}
+
+// jpegBitWriter writes markers and entropy-coded data, stuffing a zero
+// after every 0xff byte of the latter
+type jpegBitWriter struct {
+	w      *bufio.Writer
+	err    error
+	bits   uint32
+	nBits  int
+	tables [2][2]*jpegHuffman
+}
+
+func (b *jpegBitWriter) emit(class, table int, symbol byte, extra uint32, n int) {
+	h := b.tables[class][table]
+	b.write(uint32(h.codes[symbol]), int(h.lengths[symbol]))
+	if n > 0 {
+		b.write(extra&(1<<n-1), n)
+	}
+}
+
+func (b *jpegBitWriter) write(v uint32, n int) {
+	b.bits = b.bits<<n | v
+	b.nBits += n
+	for b.nBits >= 8 {
+		c := byte(b.bits >> (b.nBits - 8))
+		b.writeByte(c)
+		if c == 0xff {
+			b.writeByte(0)
+		}
+		b.nBits -= 8
+	}
+	b.bits &= 1<<b.nBits - 1
+}
+
+// pad fills the last byte of a scan with one bits
+func (b *jpegBitWriter) pad() {
+	if b.nBits > 0 {
+		b.write(1<<(8-b.nBits)-1, 8-b.nBits)
+	}
+}
+
+func (b *jpegBitWriter) writeByte(c byte) {
+	if b.err == nil {
+		b.err = b.w.WriteByte(c)
+	}
+}
+
+// marker writes a marker segment; data nil writes a bare marker
+func (b *jpegBitWriter) marker(m byte, data []byte) {
+	b.writeByte(0xff)
+	b.writeByte(m)
+	if data == nil {
+		return
+	}
+	n := len(data) + 2
+	b.writeByte(byte(n >> 8))
+	b.writeByte(byte(n))
+	for _, c := range data {
+		b.writeByte(c)
+	}
+}
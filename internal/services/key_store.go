@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// providerKeyEnv maps each provider that takes an API key to the
+// environment variable that overrides the stored key
+var providerKeyEnv = map[string]string{
+	"Pixabay":   "PIXABAY_API_KEY",
+	"Unsplash":  "UNSPLASH_ACCESS_KEY",
+	"Pexels":    "PEXELS_API_KEY",
+	"Wallhaven": "WALLHAVEN_API_KEY",
+}
+
+// keyProviders lists providerKeyEnv's keys in display order
+var keyProviders = []string{"Pixabay", "Unsplash", "Pexels", "Wallhaven"}
+
+// ProviderKeyInfo describes a provider's API key without revealing it
+type ProviderKeyInfo struct {
+	Provider   string `json:"provider"`
+	EnvVar     string `json:"envVar"`
+	Configured bool   `json:"configured"`
+	Source     string `json:"source,omitempty"` // "env" or "config"
+	Hint       string `json:"hint,omitempty"`   // last characters of the key
+}
+
+// KeyCheckResult is the outcome of validating an API key
+type KeyCheckResult struct {
+	Provider  string          `json:"provider"`
+	Valid     bool            `json:"valid"`
+	Error     string          `json:"error,omitempty"`
+	RateLimit *RateLimitState `json:"rateLimit,omitempty"`
+}
+
+// KeyStore keeps provider API keys in a file only the user can read.
+// Environment variables take precedence over stored keys.
+type KeyStore struct {
+	path string
+
+	mu   sync.RWMutex
+	keys map[string]string
+}
+
+// NewKeyStore loads the keys stored at path, if the file exists
+func NewKeyStore(path string) (*KeyStore, error) {
+	s := &KeyStore{path: path, keys: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.keys); err != nil {
+		return s, fmt.Errorf("failed to parse API keys: %w", err)
+	}
+	return s, nil
+}
+
+// Key returns the key to use for provider and where it came from
+func (s *KeyStore) Key(provider string) (key string, source string) {
+	name, ok := keyProviderName(provider)
+	if !ok {
+		return "", ""
+	}
+	if key := trim(os.Getenv(providerKeyEnv[name])); key != "" {
+		return key, "env"
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key := s.keys[name]; key != "" {
+		return key, "config"
+	}
+	return "", ""
+}
+
+// Set stores a key for provider; an empty key removes it
+func (s *KeyStore) Set(provider string, key string) error {
+	name, ok := keyProviderName(provider)
+	if !ok {
+		return fmt.Errorf("%s does not use an API key", provider)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make(map[string]string, len(s.keys)+1)
+	for k, v := range s.keys {
+		keys[k] = v
+	}
+	if key = trim(key); key == "" {
+		delete(keys, name)
+	} else {
+		keys[name] = key
+	}
+	if err := s.save(keys); err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+// List reports every provider that takes a key
+func (s *KeyStore) List() []ProviderKeyInfo {
+	infos := make([]ProviderKeyInfo, 0, len(keyProviders))
+	for _, name := range keyProviders {
+		key, source := s.Key(name)
+		info := ProviderKeyInfo{Provider: name, EnvVar: providerKeyEnv[name], Configured: key != "", Source: source}
+		if len(key) >= 8 {
+			info.Hint = "…" + key[len(key)-4:]
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// save writes keys atomically with 0600 permissions
+func (s *KeyStore) save(keys map[string]string) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".keys-*")
+	if err != nil {
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+	return nil
+}
+
+// CheckProviderKey validates key with a one-page search and reports the
+// quota the provider returned. A rejected key is reported in the result;
+// the error is only for providers that do not take a key.
+func CheckProviderKey(ctx context.Context, provider string, key string) (*KeyCheckResult, error) {
+	name, ok := keyProviderName(provider)
+	if !ok {
+		return nil, fmt.Errorf("%s does not use an API key", provider)
+	}
+	result := &KeyCheckResult{Provider: name}
+	if key = trim(key); key == "" {
+		result.Error = "no API key set"
+		return result, nil
+	}
+
+	var p APIProvider
+	switch name {
+	case "Pixabay":
+		p = NewPixabayProvider(ctx, key)
+	case "Unsplash":
+		p = NewUnsplashProvider(ctx, key)
+	case "Pexels":
+		p = NewPexelsProvider(ctx, key)
+	case "Wallhaven":
+		p = NewWallhavenProvider(ctx, key)
+	}
+
+	page, err := p.Search("wallpaper", SearchOptions{Page: 1, PerPage: 3})
+	if err != nil {
+		result.Error = err.Error()
+		var rateLimited *RateLimitError
+		if errors.As(err, &rateLimited) {
+			// The key was accepted, its quota is just used up
+			result.Valid = true
+		}
+		return result, nil
+	}
+	result.Valid = true
+	result.RateLimit = page.RateLimit
+	return result, nil
+}
+
+// keyProviderName returns the canonical name of a provider that takes a key
+func keyProviderName(provider string) (string, bool) {
+	for _, name := range keyProviders {
+		if strings.EqualFold(name, trim(provider)) {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyStore(t *testing.T) {
+	t.Setenv("PIXABAY_API_KEY", "")
+	t.Setenv("PEXELS_API_KEY", "")
+	path := filepath.Join(t.TempDir(), "config", "keys.json")
+
+	store, err := NewKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("pixabay", "pixabay-key-1234"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("Pexels", "pexels-key-5678"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("Reddit", "anything"); err == nil {
+		t.Error("expected a provider without keys to be rejected")
+	}
+
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("keys file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	reloaded, err := NewKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, source := reloaded.Key("Pixabay"); key != "pixabay-key-1234" || source != "config" {
+		t.Errorf("Key(Pixabay) = %q, %q", key, source)
+	}
+
+	// The environment overrides the stored key
+	t.Setenv("PIXABAY_API_KEY", "from-env-key")
+	if key, source := reloaded.Key("Pixabay"); key != "from-env-key" || source != "env" {
+		t.Errorf("Key(Pixabay) with env = %q, %q", key, source)
+	}
+
+	// An empty key removes the stored one
+	if err := reloaded.Set("Pexels", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range reloaded.List() {
+		switch info.Provider {
+		case "Pexels":
+			if info.Configured {
+				t.Error("expected Pexels to be unconfigured after removing its key")
+			}
+		case "Pixabay":
+			if !info.Configured || info.Source != "env" || info.Hint != "…-key" {
+				t.Errorf("Pixabay info = %+v", info)
+			}
+		}
+	}
+}
@@ -0,0 +1,538 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+// localImageExtensions lists the file extensions indexed by LocalFolderProvider
+var localImageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+	".webp": true, ".bmp": true, ".tif": true, ".tiff": true,
+}
+
+// maxMetadataBytes bounds how much of each file is scanned for metadata
+const maxMetadataBytes = 256 * 1024
+
+// LocalFolderProvider implements APIProvider for images on local disk.
+// It indexes one or more directories and answers searches offline.
+type LocalFolderProvider struct {
+	dirs []string
+	ctx  context.Context
+
+	mu    sync.Mutex
+	index map[string]localEntry // keyed by absolute path
+}
+
+// localEntry is a cached index record for a single file
+type localEntry struct {
+	path     string
+	modTime  time.Time
+	size     int64
+	width    int
+	height   int
+	author   string
+	text     string // lower-cased searchable text
+	keywords []string
+}
+
+// NewLocalFolderProvider creates a provider that indexes the given directories
+func NewLocalFolderProvider(ctx context.Context, dirs []string) *LocalFolderProvider {
+	cleaned := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if dir = trim(dir); dir == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		cleaned = append(cleaned, filepath.Clean(dir))
+	}
+	return &LocalFolderProvider{
+		dirs:  cleaned,
+		ctx:   ctx,
+		index: make(map[string]localEntry),
+	}
+}
+
+// GetName returns the provider name
+func (p *LocalFolderProvider) GetName() string {
+	return "Local"
+}
+
+// Search matches the query against file names, folder names and embedded
+// metadata of the images in the configured directories
+func (p *LocalFolderProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	entries, err := p.refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := strings.Fields(strings.ToLower(query))
+	matches := make([]localEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !matchesAllTerms(entry.text, terms) {
+			continue
+		}
+		if entry.width < options.MinWidth || entry.height < options.MinHeight {
+			continue
+		}
+		if !matchesOrientation(entry.width, entry.height, options.Orientation) {
+			continue
+		}
+		matches = append(matches, entry)
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].path < matches[j].path })
+
+	page, perPage := pageBounds(options, len(matches))
+	start := (page - 1) * perPage
+	if start > len(matches) {
+		start = len(matches)
+	}
+	end := start + perPage
+	if end > len(matches) {
+		end = len(matches)
+	}
+
+	images := make([]ImageResult, 0, end-start)
+	for _, entry := range matches[start:end] {
+		fileURL := localFileURL(entry.path)
+		sum := sha1.Sum([]byte(entry.path))
+		images = append(images, ImageResult{
+			ID:          hex.EncodeToString(sum[:8]),
+			URL:         fileURL,
+			DownloadURL: fileURL,
+			PreviewURL:  fileURL,
+			Width:       entry.width,
+			Height:      entry.height,
+			Author:      entry.author,
+			Source:      "Local",
+			Tags:        entry.keywords,
+		})
+	}
+
+	return &SearchPage{
+		Results:   images,
+		Page:      page,
+		PerPage:   perPage,
+		Total:     len(matches),
+		TotalHits: len(matches),
+		HasMore:   end < len(matches),
+	}, nil
+}
+
+// MatchesURL reports whether u is a file URL inside the configured directories
+func (p *LocalFolderProvider) MatchesURL(u *url.URL) bool {
+	if u.Scheme != "file" {
+		return false
+	}
+	path, err := localPathFromURL(u.String())
+	return err == nil && p.contains(path)
+}
+
+// DownloadFile copies an image from disk to a staging file
+func (p *LocalFolderProvider) DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error) {
+	path, err := localPathFromURL(imageURL)
+	if err != nil {
+		return "", err
+	}
+	if !p.contains(path) {
+		return "", fmt.Errorf("file is outside the configured folders: %s", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image file: %w", err)
+	}
+	defer file.Close()
+
+	total := int64(-1)
+	if info, err := file.Stat(); err == nil {
+		total = info.Size()
+	}
+	return d.Stage(file, progress, total)
+}
+
+// Download reads an image from disk. Only files inside the configured
+// directories can be read.
+func (p *LocalFolderProvider) Download(imageURL string) ([]byte, error) {
+	path, err := localPathFromURL(imageURL)
+	if err != nil {
+		return nil, err
+	}
+	if !p.contains(path) {
+		return nil, fmt.Errorf("file is outside the configured folders: %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image file: %w", err)
+	}
+	return data, nil
+}
+
+// refresh walks the configured directories, re-reading only files that
+// changed since the last search, and returns the current index
+func (p *LocalFolderProvider) refresh() ([]localEntry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, root := range p.dirs {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Skip unreadable entries instead of aborting the whole scan
+				if d != nil && d.IsDir() && path != root {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if p.ctx != nil && p.ctx.Err() != nil {
+				return p.ctx.Err()
+			}
+			if d.IsDir() || !localImageExtensions[strings.ToLower(filepath.Ext(path))] {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			seen[path] = true
+			if cached, ok := p.index[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+				return nil
+			}
+			if entry, ok := indexLocalFile(root, path, info); ok {
+				p.index[path] = entry
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+		}
+	}
+
+	entries := make([]localEntry, 0, len(seen))
+	for path, entry := range p.index {
+		if !seen[path] {
+			delete(p.index, path)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// contains reports whether path is inside one of the configured directories
+func (p *LocalFolderProvider) contains(path string) bool {
+	for _, root := range p.dirs {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexLocalFile builds the index record for a single image file
+func indexLocalFile(root, path string, info fs.FileInfo) (localEntry, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return localEntry{}, false
+	}
+	defer f.Close()
+
+	head, err := io.ReadAll(io.LimitReader(f, maxMetadataBytes))
+	if err != nil {
+		return localEntry{}, false
+	}
+
+	entry := localEntry{path: path, modTime: info.ModTime(), size: info.Size()}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(head)); err == nil {
+		entry.width, entry.height = cfg.Width, cfg.Height
+	} else if _, err := f.Seek(0, io.SeekStart); err == nil {
+		// Some encoders place large metadata blocks before the frame header
+		if cfg, _, err := image.DecodeConfig(f); err == nil {
+			entry.width, entry.height = cfg.Width, cfg.Height
+		}
+	}
+
+	// Searchable text: file name, root and sub-folder names, metadata
+	parts := []string{strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), filepath.Base(root)}
+	if rel, err := filepath.Rel(root, filepath.Dir(path)); err == nil && rel != "." {
+		parts = append(parts, strings.Split(rel, string(filepath.Separator))...)
+	}
+	metadata := readImageMetadata(head)
+	for key, value := range metadata {
+		parts = append(parts, value)
+		switch key {
+		case "Artist", "Author":
+			if entry.author == "" {
+				entry.author = value
+			}
+		case "Keywords":
+			for _, kw := range splitString(strings.ReplaceAll(value, ";", ","), ",") {
+				if kw = trim(kw); kw != "" {
+					entry.keywords = append(entry.keywords, kw)
+				}
+			}
+		}
+	}
+	if entry.keywords == nil {
+		entry.keywords = []string{}
+	}
+	entry.text = strings.ToLower(strings.Join(parts, " "))
+	return entry, true
+}
+
+// matchesAllTerms reports whether every search term occurs in text
+func matchesAllTerms(text string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesOrientation applies SearchOptions.Orientation to known dimensions
+func matchesOrientation(width, height int, orientation string) bool {
+	switch orientation {
+	case "horizontal":
+		return width >= height
+	case "vertical":
+		return height >= width
+	default:
+		return true
+	}
+}
+
+// localFileURL converts an absolute path into a file:// URL
+func localFileURL(path string) string {
+	slashed := filepath.ToSlash(path)
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed // Windows drive paths: /C:/Users/...
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String()
+}
+
+// localPathFromURL converts a file:// URL back into a cleaned absolute path
+func localPathFromURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid file URL: %w", err)
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URL scheme for local file: %q", u.Scheme)
+	}
+	path := u.Path
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:] // strip the leading slash before a Windows drive letter
+	}
+	path = filepath.Clean(filepath.FromSlash(path))
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("file URL must be absolute: %s", rawURL)
+	}
+	return path, nil
+}
+
+// readImageMetadata extracts textual metadata from the head of a PNG or
+// JPEG file: PNG tEXt/iTXt chunks, JPEG comments, EXIF strings and XMP.
+func readImageMetadata(data []byte) map[string]string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return readPNGText(data)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return readJPEGText(data)
+	default:
+		return map[string]string{}
+	}
+}
+
+// readPNGText collects uncompressed tEXt and iTXt chunks
+func readPNGText(data []byte) map[string]string {
+	result := map[string]string{}
+	pos := 8
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		if length < 0 || pos+8+length > len(data) || chunkType == "IDAT" {
+			break
+		}
+		chunk := data[pos+8 : pos+8+length]
+		switch chunkType {
+		case "tEXt":
+			if key, value, ok := bytes.Cut(chunk, []byte{0}); ok {
+				result[string(key)] = string(value)
+			}
+		case "iTXt":
+			// keyword \0 compression-flag compression-method language \0 translated \0 text
+			key, rest, ok := bytes.Cut(chunk, []byte{0})
+			if !ok || len(rest) < 2 || rest[0] != 0 {
+				break
+			}
+			_, rest, ok = bytes.Cut(rest[2:], []byte{0})
+			if !ok {
+				break
+			}
+			if _, text, ok := bytes.Cut(rest, []byte{0}); ok {
+				result[string(key)] = string(text)
+			}
+		}
+		pos += 12 + length
+	}
+	return result
+}
+
+// readJPEGText collects COM segments, XMP packets and EXIF string tags
+func readJPEGText(data []byte) map[string]string {
+	result := map[string]string{}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			break
+		}
+		marker := data[pos+1]
+		if marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			pos += 2
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			break // start of scan: no more metadata
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segment := data[pos+4 : pos+2+length]
+		switch {
+		case marker == 0xFE:
+			result["Comment"] = string(segment)
+		case marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			for key, value := range readEXIFText(segment[6:]) {
+				result[key] = value
+			}
+		case marker == 0xE1 && bytes.HasPrefix(segment, []byte("http://ns.adobe.com/xap/1.0/\x00")):
+			result["XMP"] = stripXMLTags(string(segment[29:]))
+		}
+		pos += 2 + length
+	}
+	return result
+}
+
+// readEXIFText reads the string tags of IFD0 from a TIFF-structured EXIF block
+func readEXIFText(tiff []byte) map[string]string {
+	result := map[string]string{}
+	if len(tiff) < 8 {
+		return result
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return result
+	}
+
+	names := map[uint16]string{
+		0x010E: "Description",
+		0x013B: "Artist",
+		0x8298: "Copyright",
+		0x9C9B: "XPTitle",
+		0x9C9C: "XPComment",
+		0x9C9D: "XPAuthor",
+		0x9C9E: "XPKeywords",
+		0x9C9F: "XPSubject",
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return result
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		tag := order.Uint16(tiff[entry:])
+		name, ok := names[tag]
+		if !ok {
+			continue
+		}
+		typ := order.Uint16(tiff[entry+2:])
+		n := int(order.Uint32(tiff[entry+4:]))
+		if typ != 2 && typ != 1 && typ != 7 { // ASCII, BYTE, UNDEFINED
+			continue
+		}
+		var value []byte
+		if n <= 4 {
+			value = tiff[entry+8 : entry+8+n]
+		} else {
+			offset := int(order.Uint32(tiff[entry+8:]))
+			if offset < 0 || offset+n > len(tiff) {
+				continue
+			}
+			value = tiff[offset : offset+n]
+		}
+
+		var text string
+		if strings.HasPrefix(name, "XP") {
+			// Windows XP* tags are UTF-16LE regardless of the TIFF byte order
+			u := make([]uint16, 0, len(value)/2)
+			for j := 0; j+1 < len(value); j += 2 {
+				u = append(u, binary.LittleEndian.Uint16(value[j:]))
+			}
+			text = string(utf16.Decode(u))
+			name = strings.TrimPrefix(name, "XP")
+			if name == "Author" {
+				name = "Artist"
+			}
+		} else {
+			text = string(value)
+		}
+		if text = trim(strings.TrimRight(text, "\x00")); text != "" {
+			result[name] = text
+		}
+	}
+	return result
+}
+
+// stripXMLTags reduces an XMP packet to its text content
+func stripXMLTags(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+			b.WriteByte(' ')
+		case r == '>':
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
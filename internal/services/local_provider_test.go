@@ -0,0 +1,121 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withPNGText inserts a tEXt chunk right after the IHDR chunk of a PNG
+func withPNGText(data []byte, key, value string) []byte {
+	payload := append([]byte(key+"\x00"), value...)
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	copy(chunk[4:], "tEXt")
+	chunk = append(chunk, payload...)
+	crc := crc32.ChecksumIEEE(chunk[4:])
+	chunk = binary.BigEndian.AppendUint32(chunk, crc)
+
+	ihdrEnd := 8 + 12 + int(binary.BigEndian.Uint32(data[8:]))
+	var buf bytes.Buffer
+	buf.Write(data[:ihdrEnd])
+	buf.Write(chunk)
+	buf.Write(data[ihdrEnd:])
+	return buf.Bytes()
+}
+
+func TestLocalFolderProvider(t *testing.T) {
+	root := t.TempDir()
+	mustWrite := func(rel string, data []byte) {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wide := encodeImageToPNG(createTestImage(64, 36))
+	tall := encodeImageToPNG(createTestImage(36, 64))
+	mustWrite("sunset-beach.png", wide)
+	mustWrite(filepath.Join("mountains", "alps.png"), tall)
+	mustWrite("untitled.png", withPNGText(wide, "Keywords", "aurora, night sky"))
+	mustWrite("notes.txt", []byte("not an image"))
+
+	p := NewLocalFolderProvider(context.Background(), []string{root})
+
+	search := func(query string, opts SearchOptions) []ImageResult {
+		t.Helper()
+		page, err := p.Search(query, opts)
+		if err != nil {
+			t.Fatalf("Search(%q) failed: %v", query, err)
+		}
+		return page.Results
+	}
+
+	t.Run("matches file names", func(t *testing.T) {
+		results := search("beach", SearchOptions{})
+		if len(results) != 1 || results[0].Width != 64 || results[0].Height != 36 {
+			t.Fatalf("unexpected results %+v", results)
+		}
+	})
+
+	t.Run("matches folder names", func(t *testing.T) {
+		if results := search("mountains", SearchOptions{}); len(results) != 1 {
+			t.Fatalf("expected 1 result, got %d", len(results))
+		}
+	})
+
+	t.Run("matches embedded metadata", func(t *testing.T) {
+		results := search("aurora", SearchOptions{})
+		if len(results) != 1 {
+			t.Fatalf("expected 1 result, got %d", len(results))
+		}
+		if len(results[0].Tags) != 2 || results[0].Tags[0] != "aurora" {
+			t.Errorf("unexpected tags %v", results[0].Tags)
+		}
+	})
+
+	t.Run("applies orientation and pagination", func(t *testing.T) {
+		if results := search("", SearchOptions{Orientation: "horizontal"}); len(results) != 2 {
+			t.Errorf("expected 2 horizontal results, got %d", len(results))
+		}
+		if results := search("", SearchOptions{Page: 2, PerPage: 2}); len(results) != 1 {
+			t.Errorf("expected 1 result on page 2, got %d", len(results))
+		}
+		first, _ := p.Search("", SearchOptions{Page: 1, PerPage: 2})
+		last, _ := p.Search("", SearchOptions{Page: 2, PerPage: 2})
+		if first.Total != 3 || !first.HasMore || last.HasMore {
+			t.Errorf("page info: first %d/%v, last %v", first.Total, first.HasMore, last.HasMore)
+		}
+	})
+
+	t.Run("downloads from disk", func(t *testing.T) {
+		results := search("beach", SearchOptions{})
+		data, err := p.Download(results[0].DownloadURL)
+		if err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+		if !bytes.Equal(data, wide) {
+			t.Error("downloaded data does not match file contents")
+		}
+	})
+
+	t.Run("refuses files outside the configured folders", func(t *testing.T) {
+		outside := filepath.Join(t.TempDir(), "secret.png")
+		if err := os.WriteFile(outside, wide, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Download(localFileURL(outside)); err == nil {
+			t.Error("expected error for file outside configured folders")
+		}
+		if _, err := p.Download("https://example.com/a.png"); err == nil {
+			t.Error("expected error for non-file URL")
+		}
+	})
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultUserAgent is sent by providers that do not set their own
+const DefaultUserAgent = "SweetDesk/1.0 (https://github.com/pedro3pv/SweetDesk)"
+
+// NetworkSettings configures the HTTP transport shared by every provider
+type NetworkSettings struct {
+	// ProxyURL is an http, https or socks5 proxy. Credentials may be given
+	// as user:password@host. Empty uses HTTP_PROXY/HTTPS_PROXY/NO_PROXY.
+	ProxyURL string `json:"proxyURL"`
+
+	// CABundle is a PEM file of extra trusted certificates, added to the
+	// system roots (e.g. a corporate TLS-inspecting proxy's CA)
+	CABundle string `json:"caBundle"`
+
+	UserAgent       string `json:"userAgent"`       // empty uses DefaultUserAgent
+	MaxConnsPerHost int    `json:"maxConnsPerHost"` // 0 means unlimited
+	MaxIdleConns    int    `json:"maxIdleConns"`
+
+	// RequestTimeoutSeconds overrides the providers' per-request timeout
+	// when greater than zero
+	RequestTimeoutSeconds int `json:"requestTimeoutSeconds"`
+}
+
+// DefaultNetworkSettings returns the settings used until others are applied
+func DefaultNetworkSettings() NetworkSettings {
+	return NetworkSettings{MaxIdleConns: 100}
+}
+
+// LoadNetworkSettings reads settings from path, returning the defaults if
+// the file does not exist
+func LoadNetworkSettings(path string) (NetworkSettings, error) {
+	settings := DefaultNetworkSettings()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, fmt.Errorf("failed to read network settings: %w", err)
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return DefaultNetworkSettings(), fmt.Errorf("failed to parse network settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SaveNetworkSettings writes settings to path. The file may hold proxy
+// credentials, so it is only readable by the user.
+func SaveNetworkSettings(path string, settings NetworkSettings) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save network settings: %w", err)
+	}
+	return nil
+}
+
+// sharedNetwork is the transport every ProviderClient sends requests
+// through, so connections are reused across providers and requests
+var sharedNetwork = struct {
+	mu        sync.RWMutex
+	settings  NetworkSettings
+	transport *http.Transport
+}{settings: DefaultNetworkSettings()}
+
+// ConfigureNetwork rebuilds the shared transport from settings. Requests
+// already in flight finish on the previous transport.
+func ConfigureNetwork(settings NetworkSettings) error {
+	transport, err := newTransport(settings)
+	if err != nil {
+		return err
+	}
+
+	sharedNetwork.mu.Lock()
+	old := sharedNetwork.transport
+	sharedNetwork.settings = settings
+	sharedNetwork.transport = transport
+	sharedNetwork.mu.Unlock()
+
+	if old != nil {
+		old.CloseIdleConnections()
+	}
+	return nil
+}
+
+// currentNetwork returns the shared transport and its settings, building
+// the default transport on first use
+func currentNetwork() (*http.Transport, NetworkSettings) {
+	sharedNetwork.mu.RLock()
+	transport, settings := sharedNetwork.transport, sharedNetwork.settings
+	sharedNetwork.mu.RUnlock()
+	if transport != nil {
+		return transport, settings
+	}
+
+	sharedNetwork.mu.Lock()
+	defer sharedNetwork.mu.Unlock()
+	if sharedNetwork.transport == nil {
+		// The default settings have no CA bundle or proxy to fail on
+		sharedNetwork.transport, _ = newTransport(sharedNetwork.settings)
+	}
+	return sharedNetwork.transport, sharedNetwork.settings
+}
+
+// sharedRoundTripper sends requests through the current shared transport
+type sharedRoundTripper struct{}
+
+func (sharedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, _ := currentNetwork()
+	return transport.RoundTrip(req)
+}
+
+// newTransport builds a transport from settings. Connections are checked
+// against the DownloadPolicy attached to the request context, if any.
+func newTransport(settings NetworkSettings) (*http.Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	proxyAddrs := map[string]bool{}
+	if settings.ProxyURL != "" {
+		u, err := url.Parse(settings.ProxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", settings.ProxyURL)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		proxy = http.ProxyURL(u)
+		proxyAddrs[proxyAddr(u)] = true
+	} else {
+		for _, name := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy"} {
+			if u, err := url.Parse(os.Getenv(name)); err == nil && u.Host != "" {
+				proxyAddrs[proxyAddr(u)] = true
+			}
+		}
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if settings.CABundle != "" {
+		pem, err := os.ReadFile(settings.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", settings.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// Downloads have no overall timeout, so waiting for the response is
+	// bounded here
+	headerTimeout := 30 * time.Second
+	if settings.RequestTimeoutSeconds > 0 {
+		headerTimeout = time.Duration(settings.RequestTimeoutSeconds) * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	guarded := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, ControlContext: guardDial}
+
+	return &http.Transport{
+		Proxy: guardProxy(proxy),
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			// The proxy itself may well be on a private network; the
+			// target was already checked by guardProxy
+			if proxyAddrs[addr] {
+				return dialer.DialContext(ctx, network, addr)
+			}
+			return guarded.DialContext(ctx, network, addr)
+		},
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          settings.MaxIdleConns,
+		MaxIdleConnsPerHost:   max(settings.MaxConnsPerHost, http.DefaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:       settings.MaxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: headerTimeout,
+		ExpectContinueTimeout: time.Second,
+	}, nil
+}
+
+// guardProxy wraps a proxy function. When a request with a DownloadPolicy
+// goes through a proxy the connection is made to the proxy, so the target
+// host is resolved and checked here instead.
+func guardProxy(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil || proxyURL == nil || policyFromContext(req.Context()) == nil {
+			return proxyURL, err
+		}
+		addrs, err := net.DefaultResolver.LookupNetIP(req.Context(), "ip", req.URL.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if isBlockedAddr(addr) {
+				return nil, &URLRefusedError{URL: req.URL.Redacted(), Reason: "address is not public"}
+			}
+		}
+		return proxyURL, nil
+	}
+}
+
+// proxyAddr returns the host:port a transport dials for proxy u
+func proxyAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
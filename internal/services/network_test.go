@@ -0,0 +1,121 @@
+package services
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// configureTestNetwork applies settings for one test and restores the
+// defaults afterwards
+func configureTestNetwork(t *testing.T, settings NetworkSettings) {
+	t.Helper()
+	if err := ConfigureNetwork(settings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ConfigureNetwork(DefaultNetworkSettings()) })
+}
+
+func TestNetworkSettingsProxy(t *testing.T) {
+	var gotAuth, gotTarget string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Proxy-Authorization")
+		gotTarget = r.URL.String()
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	configureTestNetwork(t, NetworkSettings{
+		ProxyURL: strings.Replace(proxy.URL, "http://", "http://alice:s3cret@", 1),
+	})
+
+	resp, err := get(t, newTestClient(), "http://images.example.com/a.png")
+	if err != nil {
+		t.Fatalf("request through proxy failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if gotTarget != "http://images.example.com/a.png" {
+		t.Errorf("proxy saw target %q", gotTarget)
+	}
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:s3cret"))
+	if gotAuth != want {
+		t.Errorf("Proxy-Authorization = %q, want %q", gotAuth, want)
+	}
+}
+
+func TestNetworkSettingsUserAgent(t *testing.T) {
+	var gotUA string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	resp, err := get(t, newTestClient(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if gotUA != DefaultUserAgent {
+		t.Errorf("User-Agent = %q, want the default", gotUA)
+	}
+
+	configureTestNetwork(t, NetworkSettings{UserAgent: "CorpDesk/2.0"})
+	resp, err = get(t, newTestClient(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if gotUA != "CorpDesk/2.0" {
+		t.Errorf("User-Agent = %q, want the configured one", gotUA)
+	}
+}
+
+func TestNetworkSettingsValidation(t *testing.T) {
+	dir := t.TempDir()
+	badPEM := filepath.Join(dir, "bad.pem")
+	os.WriteFile(badPEM, []byte("not a certificate"), 0644)
+
+	invalid := []NetworkSettings{
+		{ProxyURL: "ftp://proxy.local:21"},
+		{ProxyURL: "proxy.local:8080"},
+		{CABundle: filepath.Join(dir, "missing.pem")},
+		{CABundle: badPEM},
+	}
+	for _, settings := range invalid {
+		if err := ConfigureNetwork(settings); err == nil {
+			t.Errorf("ConfigureNetwork(%+v) succeeded, want an error", settings)
+		}
+	}
+	// A rejected configuration leaves the previous one in place
+	if _, settings := currentNetwork(); settings != DefaultNetworkSettings() {
+		t.Errorf("settings changed to %+v after a failed configuration", settings)
+	}
+}
+
+func TestSaveNetworkSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "network.json")
+	want := NetworkSettings{ProxyURL: "http://u:p@proxy.corp:3128", MaxConnsPerHost: 4, RequestTimeoutSeconds: 60}
+	if err := SaveNetworkSettings(path, want); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("settings file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	got, err := LoadNetworkSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+
+	if got, err := LoadNetworkSettings(filepath.Join(t.TempDir(), "none.json")); err != nil || got != DefaultNetworkSettings() {
+		t.Errorf("missing file: got %+v, %v", got, err)
+	}
+}
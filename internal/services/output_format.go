@@ -0,0 +1,219 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+)
+
+// Output formats accepted by OutputFormat.Format
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatTIFF = "tiff"
+	FormatBMP  = "bmp"
+	FormatWebP = "webp"
+)
+
+// PNG compression levels accepted by OutputFormat.PNGCompression
+const (
+	PNGDefault = "default"
+	PNGNone    = "none"
+	PNGFast    = "fast"
+	PNGBest    = "best"
+)
+
+// DefaultJPEGQuality is used when no JPEG quality is given
+const DefaultJPEGQuality = 95
+
+// OutputFormat selects the file format of processed images and its
+// settings. Only the settings of the chosen format are used; the zero
+// value is PNG at default compression. WebP is always lossless.
+type OutputFormat struct {
+	Format          string `json:"format,omitempty"`          // "png", "jpeg", "tiff", "bmp", "webp"
+	PNGCompression  string `json:"pngCompression,omitempty"`  // "default", "none", "fast", "best"
+	Quality         int    `json:"quality,omitempty"`         // JPEG quality (1-100), 95 by default
+	Progressive     bool   `json:"progressive,omitempty"`     // progressive JPEG
+	Subsampling     string `json:"subsampling,omitempty"`     // JPEG chroma: "4:2:0" (default), "4:2:2", "4:4:4"
+	TIFFCompression string `json:"tiffCompression,omitempty"` // "deflate" (default), "lzw", "none"
+
+	// MaxBytes limits the size of JPEG files. The quality is then searched
+	// for, with Quality as its upper bound; see EncodeToSize.
+	MaxBytes int `json:"maxBytes,omitempty"`
+}
+
+// name returns the Format* constant for o.Format, accepting the usual
+// aliases and any case
+func (o OutputFormat) name() string {
+	switch f := strings.ToLower(o.Format); f {
+	case "":
+		return FormatPNG
+	case "jpg":
+		return FormatJPEG
+	case "tif":
+		return FormatTIFF
+	default:
+		return f
+	}
+}
+
+// Validate checks that the format and its settings are known
+func (o OutputFormat) Validate() error {
+	switch o.name() {
+	case FormatPNG, FormatJPEG, FormatTIFF, FormatBMP, FormatWebP:
+	default:
+		return fmt.Errorf("unsupported output format: %s", o.Format)
+	}
+	switch o.PNGCompression {
+	case "", PNGDefault, PNGNone, PNGFast, PNGBest:
+	default:
+		return fmt.Errorf("unknown PNG compression: %s", o.PNGCompression)
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("JPEG quality must be between 1 and 100, got %d", o.Quality)
+	}
+	switch o.Subsampling {
+	case "", Subsampling420, Subsampling422, Subsampling444:
+	default:
+		return fmt.Errorf("unknown chroma subsampling: %s", o.Subsampling)
+	}
+	switch o.TIFFCompression {
+	case "", TIFFDeflate, TIFFLZW, TIFFNone:
+	default:
+		return fmt.Errorf("unsupported TIFF compression: %s", o.TIFFCompression)
+	}
+	if o.MaxBytes < 0 {
+		return fmt.Errorf("invalid size limit: %d bytes", o.MaxBytes)
+	}
+	if o.MaxBytes > 0 && o.name() != FormatJPEG {
+		return fmt.Errorf("a size limit needs JPEG output, not %s", o.name())
+	}
+	return nil
+}
+
+// Extension returns the file extension for the format, with its dot
+func (o OutputFormat) Extension() string {
+	switch o.name() {
+	case FormatJPEG:
+		return ".jpg"
+	case FormatTIFF:
+		return ".tiff"
+	case FormatBMP:
+		return ".bmp"
+	case FormatWebP:
+		return ".webp"
+	default:
+		return ".png"
+	}
+}
+
+// FileName gives name the format's extension, replacing an image extension
+// it already has
+func (o OutputFormat) FileName(name string) string {
+	if ext := filepath.Ext(name); localImageExtensions[strings.ToLower(ext)] {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name + o.Extension()
+}
+
+// Reencodes reports whether an image already in the given decoded format
+// must still be encoded again to match o
+func (o OutputFormat) Reencodes(format string) bool {
+	tunedPNG := o.PNGCompression != "" && o.PNGCompression != PNGDefault
+	return o.name() != format || tunedPNG || o.Quality != 0 ||
+		o.Progressive || o.Subsampling != "" || o.TIFFCompression != "" || o.MaxBytes != 0
+}
+
+// Encode encodes img in the output format
+func (ip *ImageProcessor) Encode(img image.Image, out OutputFormat) ([]byte, error) {
+	data, _, err := ip.encode(img, out)
+	return data, err
+}
+
+// encode is Encode, also returning the JPEG quality used; 0 for other
+// formats
+func (ip *ImageProcessor) encode(img image.Image, out OutputFormat) ([]byte, int, error) {
+	if err := out.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	var buf bytes.Buffer
+	switch out.name() {
+	case FormatPNG:
+		enc := png.Encoder{}
+		switch out.PNGCompression {
+		case PNGNone:
+			enc.CompressionLevel = png.NoCompression
+		case PNGFast:
+			enc.CompressionLevel = png.BestSpeed
+		case PNGBest:
+			enc.CompressionLevel = png.BestCompression
+		}
+		if err := enc.Encode(&buf, img); err != nil {
+			return nil, 0, fmt.Errorf("failed to encode png: %w", err)
+		}
+	case FormatJPEG:
+		if out.MaxBytes > 0 {
+			return ip.encodeToSize(img, out.MaxBytes, out)
+		}
+		quality := out.Quality
+		if quality == 0 {
+			quality = DefaultJPEGQuality
+		}
+		j, err := newJPEGImage(img, out.Subsampling)
+		if err == nil {
+			err = j.encode(&buf, quality, out.Progressive)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encode jpeg: %w", err)
+		}
+		return buf.Bytes(), quality, nil
+	case FormatTIFF:
+		if err := encodeTIFF(&buf, img, out.TIFFCompression); err != nil {
+			return nil, 0, fmt.Errorf("failed to encode tiff: %w", err)
+		}
+	case FormatBMP:
+		if err := bmp.Encode(&buf, img); err != nil {
+			return nil, 0, fmt.Errorf("failed to encode bmp: %w", err)
+		}
+	case FormatWebP:
+		if err := encodeWebPLossless(&buf, img); err != nil {
+			return nil, 0, fmt.Errorf("failed to encode webp: %w", err)
+		}
+	}
+	return buf.Bytes(), 0, nil
+}
+
+// EncodeOutput fits an upscaled image to targetWidth×targetHeight like
+// FitBytes and encodes it in the output format, returning the JPEG quality
+// used as well. data is returned as-is when it already has the target size
+// and format.
+func (ip *ImageProcessor) EncodeOutput(data []byte, targetWidth, targetHeight int, opts ProcessingOptions, out OutputFormat) ([]byte, int, bool, error) {
+	width, height, format, err := ip.DecodeDimensions(data)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	fits := width == targetWidth && height == targetHeight
+	if fits && !out.Reencodes(format) {
+		return data, 0, false, nil
+	}
+	img, _, err := ip.LoadImageFromBytes(data)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if !fits {
+		if img, err = ip.Fit(img, targetWidth, targetHeight, opts); err != nil {
+			return nil, 0, false, err
+		}
+	}
+	encoded, quality, err := ip.encode(img, out)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return encoded, quality, true, nil
+}
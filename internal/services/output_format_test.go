@@ -0,0 +1,230 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// createNoisyImage draws gradients, flat areas, repeated rows and noise,
+// so encoders meet both compressible and incompressible content
+func createNoisyImage(width, height int, alpha bool) *image.NRGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 90, A: 255}
+			switch {
+			case y%20 >= 15:
+				c = img.NRGBAAt(x, y-5)
+			case x > width/2 && y > height/2:
+				c = color.NRGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+			case x < width/4:
+				c = color.NRGBA{R: 30, G: 60, B: 200, A: 255}
+			}
+			if alpha {
+				c.A = uint8(x * 7)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncodeLossless(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	src := createNoisyImage(301, 77, true)
+	opaque := createNoisyImage(301, 77, false)
+
+	cases := []struct {
+		out    OutputFormat
+		src    image.Image
+		decode func([]byte) (image.Image, error)
+	}{
+		{OutputFormat{}, src, nil},
+		{OutputFormat{PNGCompression: PNGNone}, src, nil},
+		{OutputFormat{PNGCompression: PNGBest}, src, nil},
+		{OutputFormat{Format: FormatTIFF}, src, decodeWith(tiff.Decode)},
+		{OutputFormat{Format: FormatTIFF, TIFFCompression: TIFFLZW}, src, decodeWith(tiff.Decode)},
+		{OutputFormat{Format: FormatTIFF, TIFFCompression: TIFFLZW}, opaque, decodeWith(tiff.Decode)},
+		{OutputFormat{Format: "tif", TIFFCompression: TIFFNone}, opaque, decodeWith(tiff.Decode)},
+		{OutputFormat{Format: FormatBMP}, opaque, decodeWith(bmp.Decode)},
+		{OutputFormat{Format: FormatWebP}, src, decodeWith(webp.Decode)},
+		{OutputFormat{Format: FormatWebP}, opaque, decodeWith(webp.Decode)},
+		{OutputFormat{Format: FormatWebP}, createTestImage(1, 1), decodeWith(webp.Decode)},
+	}
+	for _, c := range cases {
+		data, err := ip.Encode(c.src, c.out)
+		if err != nil {
+			t.Fatalf("%+v: %v", c.out, err)
+		}
+		var got image.Image
+		if c.decode != nil {
+			got, err = c.decode(data)
+		} else {
+			got, _, err = ip.LoadImageFromBytes(data)
+		}
+		if err != nil {
+			t.Fatalf("%+v: decoding: %v", c.out, err)
+		}
+		if !sameNRGBA(got, c.src) {
+			t.Errorf("%+v: decoded image differs from the source", c.out)
+		}
+	}
+
+	// Registered decoders accept the new formats as inputs
+	for _, format := range []string{FormatTIFF, FormatBMP, FormatWebP} {
+		data, _ := ip.Encode(opaque, OutputFormat{Format: format})
+		if _, got, err := ip.LoadImageFromBytes(data); err != nil || got != format {
+			t.Errorf("decoding %s: got format %q, err %v", format, got, err)
+		}
+	}
+}
+
+func TestEncodeJPEG(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	src := createNoisyImage(203, 61, false)
+	// Keep the noise out of the quality measure; it only checks the
+	// encoder's handling of incompressible blocks
+	smooth := createTestImage(203, 61)
+
+	for _, subsampling := range []string{Subsampling420, Subsampling422, Subsampling444} {
+		for _, progressive := range []bool{false, true} {
+			out := OutputFormat{Format: FormatJPEG, Quality: 90, Subsampling: subsampling, Progressive: progressive}
+			for _, img := range []image.Image{src, smooth} {
+				data, err := ip.Encode(img, out)
+				if err != nil {
+					t.Fatalf("%+v: %v", out, err)
+				}
+				got, err := jpeg.Decode(bytes.NewReader(data))
+				if err != nil {
+					t.Fatalf("%+v: decoding: %v", out, err)
+				}
+				if got.Bounds().Size() != img.Bounds().Size() {
+					t.Errorf("%+v: decoded size %v", out, got.Bounds())
+				}
+				if img == smooth {
+					if p := psnr(got, smooth); p < 35 {
+						t.Errorf("%+v: PSNR %.1f dB, want at least 35", out, p)
+					}
+				}
+			}
+		}
+	}
+
+	low, _ := ip.Encode(src, OutputFormat{Format: FormatJPEG, Quality: 30})
+	high, _ := ip.Encode(src, OutputFormat{Format: FormatJPEG})
+	if len(low) >= len(high) {
+		t.Errorf("quality 30 is %d bytes, default quality %d", len(low), len(high))
+	}
+}
+
+func TestOutputFormatFileName(t *testing.T) {
+	cases := []struct {
+		format, name, want string
+	}{
+		{"", "wall.png", "wall.png"},
+		{"", "wall.JPG", "wall.png"},
+		{FormatJPEG, "wall.png", "wall.jpg"},
+		{"JPG", "wall", "wall.jpg"},
+		{FormatTIFF, "wall.v2.webp", "wall.v2.tiff"},
+		{FormatWebP, "wall.final", "wall.final.webp"},
+		{FormatBMP, "wall.tif", "wall.bmp"},
+	}
+	for _, c := range cases {
+		if got := (OutputFormat{Format: c.format}).FileName(c.name); got != c.want {
+			t.Errorf("FileName(%q) as %q = %q, want %q", c.name, c.format, got, c.want)
+		}
+	}
+
+	for _, out := range []OutputFormat{
+		{Format: "gif"},
+		{PNGCompression: "max"},
+		{Format: FormatJPEG, Quality: 101},
+		{Format: FormatJPEG, Subsampling: "4:1:1"},
+		{Format: FormatTIFF, TIFFCompression: "jpeg"},
+	} {
+		if err := out.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", out)
+		}
+	}
+}
+
+func TestEncodeOutput(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	data := encodeImageToPNG(createTestImage(64, 36))
+
+	out, _, changed, err := ip.EncodeOutput(data, 64, 36, ProcessingOptions{}, OutputFormat{})
+	if err != nil || changed || !bytes.Equal(out, data) {
+		t.Errorf("PNG at the target size was re-encoded: changed = %v, err = %v", changed, err)
+	}
+
+	out, _, changed, err = ip.EncodeOutput(data, 64, 36, ProcessingOptions{}, OutputFormat{Format: FormatJPEG})
+	if err != nil || !changed {
+		t.Fatalf("EncodeOutput to JPEG: changed = %v, err = %v", changed, err)
+	}
+	if _, _, format, _ := ip.DecodeDimensions(out); format != "jpeg" {
+		t.Errorf("output format = %q, want jpeg", format)
+	}
+
+	out, _, _, err = ip.EncodeOutput(data, 32, 32, ProcessingOptions{FitMode: FitCrop}, OutputFormat{Format: FormatWebP})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h, format, _ := ip.DecodeDimensions(out); w != 32 || h != 32 || format != "webp" {
+		t.Errorf("fitted output is %dx%d %s, want 32x32 webp", w, h, format)
+	}
+}
+
+func decodeWith(decode func(r io.Reader) (image.Image, error)) func([]byte) (image.Image, error) {
+	return func(data []byte) (image.Image, error) {
+		return decode(bytes.NewReader(data))
+	}
+}
+
+// sameNRGBA reports whether a and b have the same size and, compared as
+// non-premultiplied colours, the same pixels; fully transparent pixels
+// only have to be transparent
+func sameNRGBA(a, b image.Image) bool {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		return false
+	}
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(ab.Min.X+x, ab.Min.Y+y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA)
+			if ca != cb && (ca.A != 0 || cb.A != 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// psnr is the peak signal-to-noise ratio of got against want, in dB
+func psnr(got, want image.Image) float64 {
+	b := want.Bounds()
+	var sum float64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r1, g1, b1, _ := got.At(x, y).RGBA()
+			r2, g2, b2, _ := want.At(x, y).RGBA()
+			for _, d := range []float64{float64(r1>>8) - float64(r2>>8), float64(g1>>8) - float64(g2>>8), float64(b1>>8) - float64(b2>>8)} {
+				sum += d * d
+			}
+		}
+	}
+	mse := sum / float64(3*b.Dx()*b.Dy())
+	return 10 * math.Log10(255*255/mse)
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// pexelsBaseURL is the Pexels API endpoint
+const pexelsBaseURL = "https://api.pexels.com/v1"
+
+// PexelsProvider implements APIProvider for Pexels
+type PexelsProvider struct {
+	apiKey  string
+	client  *ProviderClient
+	ctx     context.Context
+	baseURL string
+}
+
+// pexelsSrc mirrors the "src" object of a Pexels photo
+type pexelsSrc struct {
+	Original  string `json:"original"`
+	Large2x   string `json:"large2x"`
+	Large     string `json:"large"`
+	Medium    string `json:"medium"`
+	Small     string `json:"small"`
+	Portrait  string `json:"portrait"`
+	Landscape string `json:"landscape"`
+	Tiny      string `json:"tiny"`
+}
+
+// pexelsVariant is a single size variant with its resulting dimensions
+type pexelsVariant struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+// NewPexelsProvider creates a new Pexels provider
+func NewPexelsProvider(ctx context.Context, apiKey string) *PexelsProvider {
+	return &PexelsProvider{
+		apiKey:  apiKey,
+		client:  NewProviderClient("Pexels", 30*time.Second),
+		ctx:     ctx,
+		baseURL: pexelsBaseURL,
+	}
+}
+
+// SetBaseURL points the provider at another API endpoint, such as a mirror
+// or a local test server
+func (p *PexelsProvider) SetBaseURL(baseURL string) {
+	p.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// GetName returns the provider name
+func (p *PexelsProvider) GetName() string {
+	return "Pexels"
+}
+
+// Search searches for photos on Pexels
+func (p *PexelsProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	return p.SearchContext(p.ctx, query, options)
+}
+
+// SearchContext is Search, cancelled with ctx
+func (p *PexelsProvider) SearchContext(ctx context.Context, query string, options SearchOptions) (*SearchPage, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("page", fmt.Sprintf("%d", options.Page))
+	params.Set("per_page", fmt.Sprintf("%d", options.PerPage))
+	if orientation := pexelsOrientation(options.Orientation); orientation != "" {
+		params.Set("orientation", orientation)
+	}
+	if size := pexelsSize(options.MinWidth, options.MinHeight); size != "" {
+		params.Set("size", size)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", p.apiKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		TotalResults int    `json:"total_results"`
+		NextPage     string `json:"next_page"`
+		Photos       []struct {
+			ID              int       `json:"id"`
+			Width           int       `json:"width"`
+			Height          int       `json:"height"`
+			URL             string    `json:"url"`
+			Photographer    string    `json:"photographer"`
+			PhotographerURL string    `json:"photographer_url"`
+			Alt             string    `json:"alt"`
+			Src             pexelsSrc `json:"src"`
+		} `json:"photos"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	images := make([]ImageResult, 0, len(result.Photos))
+	for _, photo := range result.Photos {
+		variant, ok := selectPexelsVariant(photo.Src, photo.Width, photo.Height, options.MinWidth, options.MinHeight)
+		if !ok {
+			continue
+		}
+
+		tags := []string{}
+		if photo.Alt != "" {
+			tags = append(tags, photo.Alt)
+		}
+
+		images = append(images, ImageResult{
+			ID:          fmt.Sprintf("%d", photo.ID),
+			URL:         photo.URL,
+			DownloadURL: variant.URL,
+			PreviewURL:  photo.Src.Medium,
+			Width:       variant.Width,
+			Height:      variant.Height,
+			Author:      photo.Photographer,
+			AuthorURL:   photo.PhotographerURL,
+			Source:      "Pexels",
+			Tags:        tags,
+			License:     "Pexels License",
+			LicenseURL:  "https://www.pexels.com/license/",
+		})
+	}
+
+	page, perPage := pageBounds(options, 15)
+	return &SearchPage{
+		Results:   images,
+		Page:      page,
+		PerPage:   perPage,
+		Total:     result.TotalResults,
+		TotalHits: result.TotalResults,
+		HasMore:   result.NextPage != "",
+		RateLimit: parseRateLimit(resp.Header),
+	}, nil
+}
+
+// MatchesURL reports whether u is served by Pexels
+func (p *PexelsProvider) MatchesURL(u *url.URL) bool {
+	return hostMatches(u, "pexels.com")
+}
+
+// DownloadFile streams an image to a staging file
+func (p *PexelsProvider) DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error) {
+	return d.Fetch(p.ctx, p.client, imageURL, nil, progress)
+}
+
+// Download downloads an image from URL
+func (p *PexelsProvider) Download(imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(p.ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	return data, nil
+}
+
+// pexelsVariants returns the size variants of a photo from largest to
+// smallest, with dimensions computed from Pexels' documented resize rules.
+func pexelsVariants(src pexelsSrc, width, height int) []pexelsVariant {
+	variants := []pexelsVariant{}
+	add := func(u string, w, h int) {
+		if u != "" && w > 0 && h > 0 {
+			variants = append(variants, pexelsVariant{URL: u, Width: w, Height: h})
+		}
+	}
+
+	add(src.Original, width, height)
+	w, h := fitWithin(width, height, 1880, 1300) // large2x: 940x650 @2x
+	add(src.Large2x, w, h)
+	w, h = fitWithin(width, height, 940, 650)
+	add(src.Large, w, h)
+	add(src.Landscape, 1200, 627)
+	add(src.Portrait, 800, 1200)
+	if height > 0 {
+		add(src.Medium, width*350/height, 350)
+	}
+
+	return variants
+}
+
+// pexelsSize maps minimum dimensions to the largest of Pexels' megapixel
+// buckets that no photo meeting them can fall short of: "large" (24MP),
+// "medium" (12MP) or "small" (4MP). 4K (8.3MP) is "small"; the exact
+// dimensions are checked by selectPexelsVariant.
+func pexelsSize(minWidth, minHeight int) string {
+	pixels := minWidth * minHeight
+	switch {
+	case pixels >= 24_000_000:
+		return "large"
+	case pixels >= 12_000_000:
+		return "medium"
+	case pixels >= 4_000_000:
+		return "small"
+	}
+	return ""
+}
+
+// selectPexelsVariant picks the largest variant that satisfies the minimum
+// dimensions. Photos with no qualifying variant are rejected, since the
+// Pexels API only filters by coarse megapixel buckets.
+func selectPexelsVariant(src pexelsSrc, width, height, minWidth, minHeight int) (pexelsVariant, bool) {
+	var best pexelsVariant
+	found := false
+	for _, v := range pexelsVariants(src, width, height) {
+		if v.Width < minWidth || v.Height < minHeight {
+			continue
+		}
+		if !found || v.Width*v.Height > best.Width*best.Height {
+			best = v
+			found = true
+		}
+	}
+	return best, found
+}
+
+// fitWithin scales width x height down to fit inside maxW x maxH,
+// preserving the aspect ratio. Images that already fit are unchanged.
+func fitWithin(width, height, maxW, maxH int) (int, int) {
+	if width <= 0 || height <= 0 {
+		return 0, 0
+	}
+	if width <= maxW && height <= maxH {
+		return width, height
+	}
+	if width*maxH > height*maxW {
+		return maxW, height * maxW / width
+	}
+	return width * maxH / height, maxH
+}
+
+// pexelsOrientation maps SearchOptions.Orientation to Pexels' values
+func pexelsOrientation(orientation string) string {
+	switch orientation {
+	case "horizontal", "landscape":
+		return "landscape"
+	case "vertical", "portrait":
+		return "portrait"
+	case "square":
+		return "square"
+	default:
+		return ""
+	}
+}
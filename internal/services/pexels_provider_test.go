@@ -0,0 +1,79 @@
+package services
+
+import "testing"
+
+func TestSelectPexelsVariant(t *testing.T) {
+	src := pexelsSrc{
+		Original: "https://images.pexels.com/photos/1/original.jpeg",
+		Large2x:  "https://images.pexels.com/photos/1/large2x.jpeg",
+		Large:    "https://images.pexels.com/photos/1/large.jpeg",
+		Medium:   "https://images.pexels.com/photos/1/medium.jpeg",
+	}
+
+	t.Run("picks the largest qualifying variant", func(t *testing.T) {
+		v, ok := selectPexelsVariant(src, 6000, 4000, 1920, 1080)
+		if !ok {
+			t.Fatal("expected a variant")
+		}
+		if v.URL != src.Original || v.Width != 6000 || v.Height != 4000 {
+			t.Errorf("unexpected variant %+v", v)
+		}
+	})
+
+	t.Run("falls back to a resized variant when original is missing", func(t *testing.T) {
+		noOriginal := src
+		noOriginal.Original = ""
+		v, ok := selectPexelsVariant(noOriginal, 6000, 4000, 1000, 600)
+		if !ok {
+			t.Fatal("expected a variant")
+		}
+		if v.URL != src.Large2x || v.Width != 1880 || v.Height != 1253 {
+			t.Errorf("unexpected variant %+v", v)
+		}
+	})
+
+	t.Run("rejects photos below the minimum", func(t *testing.T) {
+		if _, ok := selectPexelsVariant(src, 1280, 720, 1920, 1080); ok {
+			t.Error("expected no variant for a 720p photo with a 1080p minimum")
+		}
+	})
+}
+
+func TestFitWithin(t *testing.T) {
+	tests := []struct {
+		w, h, maxW, maxH int
+		wantW, wantH     int
+	}{
+		{6000, 4000, 940, 650, 940, 626},
+		{4000, 6000, 940, 650, 433, 650},
+		{800, 600, 940, 650, 800, 600},
+		{0, 600, 940, 650, 0, 0},
+	}
+	for _, tt := range tests {
+		w, h := fitWithin(tt.w, tt.h, tt.maxW, tt.maxH)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("fitWithin(%d, %d, %d, %d) = %dx%d, want %dx%d",
+				tt.w, tt.h, tt.maxW, tt.maxH, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestPexelsSize(t *testing.T) {
+	tests := []struct {
+		minW, minH int
+		want       string
+	}{
+		{0, 0, ""},
+		{1920, 1080, ""},
+		{3840, 0, ""}, // a wide panorama can be under 4MP
+		{2560, 1600, "small"},
+		{3840, 2160, "small"},
+		{5120, 2880, "medium"},
+		{7680, 4320, "large"},
+	}
+	for _, tt := range tests {
+		if got := pexelsSize(tt.minW, tt.minH); got != tt.want {
+			t.Errorf("pexelsSize(%d, %d) = %q, want %q", tt.minW, tt.minH, got, tt.want)
+		}
+	}
+}
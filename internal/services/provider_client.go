@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxRetries is how many times a transient failure is retried
+	defaultMaxRetries = 3
+
+	// defaultBaseDelay and defaultMaxDelay bound the exponential backoff
+	defaultBaseDelay = 500 * time.Millisecond
+	defaultMaxDelay  = 8 * time.Second
+
+	// defaultMaxQuotaWait is the longest a request is held back waiting for
+	// a quota window to reset before failing with a RateLimitError
+	defaultMaxQuotaWait = 10 * time.Second
+
+	// quotaReserveDivisor marks the quota as running low once no more than
+	// Limit/quotaReserveDivisor requests remain in the window
+	quotaReserveDivisor = 10
+)
+
+// RateLimitError is returned when a provider's quota is exhausted
+type RateLimitError struct {
+	Provider   string
+	RetryAfter time.Duration // zero if unknown
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s rate limit reached, try again in %s", e.Provider, e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("%s rate limit reached, try again later", e.Provider)
+}
+
+// UnauthorizedError is returned when a provider rejects the credentials
+type UnauthorizedError struct {
+	Provider   string
+	StatusCode int
+}
+
+func (e *UnauthorizedError) Error() string {
+	return fmt.Sprintf("%s rejected the API key (status %d)", e.Provider, e.StatusCode)
+}
+
+// NotFoundError is returned when the requested resource does not exist
+type NotFoundError struct {
+	Provider string
+	URL      string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s: not found: %s", e.Provider, e.URL)
+}
+
+// StatusError is returned for any other unsuccessful response
+type StatusError struct {
+	Provider   string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d", e.Provider, e.StatusCode)
+}
+
+// ProviderClient is the HTTP client shared by the providers. It tracks the
+// quota reported in rate-limit headers and holds requests back once it is
+// used up, retries transient failures (429, 5xx, timeouts) with jittered
+// exponential backoff, and turns failed responses into typed errors.
+type ProviderClient struct {
+	name   string
+	client *http.Client
+
+	maxRetries   int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	maxQuotaWait time.Duration
+	minInterval  time.Duration
+
+	mu          sync.Mutex
+	quota       *RateLimitState
+	lastRequest time.Time
+}
+
+// NewProviderClient creates a client for the named provider
+func NewProviderClient(name string, timeout time.Duration) *ProviderClient {
+	return &ProviderClient{
+		name:         name,
+		client:       &http.Client{Timeout: timeout, Transport: sharedRoundTripper{}, CheckRedirect: guardRedirect},
+		maxRetries:   defaultMaxRetries,
+		baseDelay:    defaultBaseDelay,
+		maxDelay:     defaultMaxDelay,
+		maxQuotaWait: defaultMaxQuotaWait,
+	}
+}
+
+// SetMinInterval spaces requests at least d apart, for providers that
+// enforce a request rate without reporting it in headers
+func (c *ProviderClient) SetMinInterval(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.minInterval = d
+}
+
+// RateLimit returns the last quota reported by the provider, or nil
+func (c *ProviderClient) RateLimit() *RateLimitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.quota == nil {
+		return nil
+	}
+	quota := *c.quota
+	return &quota
+}
+
+// httpClient applies the shared network settings to the client
+func (c *ProviderClient) httpClient() *http.Client {
+	_, settings := currentNetwork()
+	if settings.RequestTimeoutSeconds <= 0 {
+		return c.client
+	}
+	client := *c.client
+	client.Timeout = time.Duration(settings.RequestTimeoutSeconds) * time.Second
+	return &client
+}
+
+// downloadClient is the client without an overall timeout, which would
+// also cut off a response body that is still arriving. The transport's
+// ResponseHeaderTimeout still bounds the wait for a response.
+func (c *ProviderClient) downloadClient() *http.Client {
+	client := *c.client
+	client.Timeout = 0
+	return &client
+}
+
+// Do sends a request, retrying transient failures. It only returns a
+// response for 2xx statuses; the caller must close its body.
+func (c *ProviderClient) Do(req *http.Request) (*http.Response, error) {
+	return c.do(req, c.httpClient)
+}
+
+// Download is Do for file transfers: reading the body has no time limit,
+// so the caller must bound stalls itself
+func (c *ProviderClient) Download(req *http.Request) (*http.Response, error) {
+	return c.do(req, c.downloadClient)
+}
+
+func (c *ProviderClient) do(req *http.Request, httpClient func() *http.Client) (*http.Response, error) {
+	ctx := req.Context()
+	if req.Header.Get("User-Agent") == "" {
+		_, settings := currentNetwork()
+		if settings.UserAgent != "" {
+			req.Header.Set("User-Agent", settings.UserAgent)
+		} else {
+			req.Header.Set("User-Agent", DefaultUserAgent)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForQuota(ctx); err != nil {
+			return nil, err
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := httpClient().Do(req)
+		if err != nil {
+			if ctx.Err() == nil && isTransientError(err) && attempt < c.maxRetries {
+				if err := sleepContext(ctx, c.backoff(attempt)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, fmt.Errorf("failed to execute request: %w", err)
+		}
+
+		c.recordQuota(resp.Header)
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		// The body is not needed for error statuses
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			wait := retryAfter(resp.Header)
+			if wait == 0 {
+				wait = c.backoff(attempt)
+			}
+			if attempt >= c.maxRetries || wait > c.maxQuotaWait {
+				return nil, &RateLimitError{Provider: c.name, RetryAfter: wait}
+			}
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+		case resp.StatusCode >= 500:
+			if attempt >= c.maxRetries {
+				return nil, &StatusError{Provider: c.name, StatusCode: resp.StatusCode}
+			}
+			if err := sleepContext(ctx, c.backoff(attempt)); err != nil {
+				return nil, err
+			}
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			return nil, &UnauthorizedError{Provider: c.name, StatusCode: resp.StatusCode}
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, &NotFoundError{Provider: c.name, URL: req.URL.Redacted()}
+		default:
+			return nil, &StatusError{Provider: c.name, StatusCode: resp.StatusCode}
+		}
+	}
+}
+
+// waitForQuota delays the request until the quota window resets when no
+// requests are left, spreads the last requests of a window over the time
+// until it resets, and enforces the minimum interval between requests
+func (c *ProviderClient) waitForQuota(ctx context.Context) error {
+	c.mu.Lock()
+	var wait time.Duration
+	exhausted := false
+	if q := c.quota; q != nil && !q.Reset.IsZero() {
+		switch {
+		case q.Remaining <= 0:
+			wait = time.Until(q.Reset)
+			exhausted = true
+		case q.Remaining <= q.Limit/quotaReserveDivisor:
+			// Pacing never fails the request, it only slows a burst down
+			pace := time.Until(q.Reset) / time.Duration(q.Remaining+1)
+			wait = min(pace-time.Since(c.lastRequest), c.maxQuotaWait)
+		}
+	}
+	if c.minInterval > 0 && !c.lastRequest.IsZero() {
+		if gap := c.minInterval - time.Since(c.lastRequest); gap > wait {
+			wait = gap
+		}
+	}
+	if wait > c.maxQuotaWait {
+		c.mu.Unlock()
+		return &RateLimitError{Provider: c.name, RetryAfter: wait}
+	}
+	// Reserve the slot before sleeping so concurrent requests queue up
+	c.lastRequest = time.Now().Add(wait)
+	if exhausted {
+		c.quota = nil // the window has reset by the time the request is sent
+	} else if c.quota != nil && c.quota.Remaining > 0 {
+		c.quota.Remaining-- // until the response reports the new count
+	}
+	c.mu.Unlock()
+
+	return sleepContext(ctx, wait)
+}
+
+func (c *ProviderClient) recordQuota(h http.Header) {
+	if state := parseRateLimit(h); state != nil {
+		c.mu.Lock()
+		c.quota = state
+		c.mu.Unlock()
+	}
+}
+
+// backoff returns a random delay up to base*2^attempt, capped at maxDelay
+func (c *ProviderClient) backoff(attempt int) time.Duration {
+	ceiling := c.baseDelay << attempt
+	if ceiling > c.maxDelay || ceiling <= 0 {
+		ceiling = c.maxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// retryAfter parses a Retry-After header in seconds or HTTP-date form
+func retryAfter(h http.Header) time.Duration {
+	value := h.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// isTransientError reports whether a transport error is worth retrying
+func isTransientError(err error) bool {
+	var refused *URLRefusedError
+	if errors.As(err, &refused) {
+		return false
+	}
+	if errors.Is(err, errDownloadStalled) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true // connection refused or reset
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient() *ProviderClient {
+	c := NewProviderClient("Test", 5*time.Second)
+	c.baseDelay = time.Millisecond
+	c.maxDelay = 5 * time.Millisecond
+	return c
+}
+
+func get(t *testing.T, c *ProviderClient, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestProviderClientRetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	if _, err := get(t, newTestClient(), server.URL); err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestProviderClientTypedErrors(t *testing.T) {
+	status := http.StatusUnauthorized
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "3600")
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	c := newTestClient()
+
+	_, err := get(t, c, server.URL)
+	var unauthorized *UnauthorizedError
+	if !errors.As(err, &unauthorized) || unauthorized.StatusCode != 401 {
+		t.Errorf("401: got %v", err)
+	}
+
+	status = http.StatusNotFound
+	_, err = get(t, c, server.URL)
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("404: got %v", err)
+	}
+
+	// A Retry-After beyond the wait budget fails immediately
+	status = http.StatusTooManyRequests
+	_, err = get(t, c, server.URL)
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.RetryAfter != time.Hour {
+		t.Errorf("429: got %v", err)
+	}
+
+	status = http.StatusBadRequest
+	_, err = get(t, c, server.URL)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 400 {
+		t.Errorf("400: got %v", err)
+	}
+}
+
+func TestProviderClientPacesLowQuota(t *testing.T) {
+	var remaining atomic.Int32
+	remaining.Store(50)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(remaining.Load())))
+		w.Header().Set("X-RateLimit-Reset", "1")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	c := newTestClient()
+
+	// Plenty of quota left, so requests go out back to back
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := get(t, c, server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("requests with quota to spare were delayed (%s)", elapsed)
+	}
+
+	// Two requests left in the window: the next is spaced a third of the
+	// way to the reset instead of spending the remainder at once
+	remaining.Store(2)
+	if _, err := get(t, c, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	if _, err := get(t, c, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("request was not paced while the quota ran low (%s)", elapsed)
+	}
+}
+
+func TestProviderClientWaitsForQuota(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	c := newTestClient()
+
+	if _, err := get(t, c, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if quota := c.RateLimit(); quota == nil || quota.Limit != 100 || quota.Remaining != 0 {
+		t.Fatalf("unexpected quota %+v", quota)
+	}
+
+	// The quota is used up, so the next request waits for the reset
+	start := time.Now()
+	if _, err := get(t, c, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("request was not delayed until the quota reset (%s)", elapsed)
+	}
+
+	// A reset beyond the wait budget fails without sending a request
+	c.maxQuotaWait = 100 * time.Millisecond
+	_, err := get(t, c, server.URL)
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || calls.Load() != 2 {
+		t.Errorf("expected RateLimitError without a request, got %v after %d calls", err, calls.Load())
+	}
+}
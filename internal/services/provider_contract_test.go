@@ -0,0 +1,415 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// contractCase describes one provider for the contract suite. Each provider
+// is pointed at a local server that replays the recorded fixtures in
+// testdata/contract/<fixtures>: page1.json, page2.json and empty.json.
+type contractCase struct {
+	fixtures string
+
+	// newProvider returns the provider using baseURL and its HTTP client
+	newProvider func(baseURL string) (APIProvider, *ProviderClient)
+
+	// page reports which page a request asks for
+	page func(r *http.Request) int
+
+	// first is the expected mapping of the first result on page 1
+	first ImageResult
+}
+
+func queryPage(r *http.Request) int {
+	if r.URL.Query().Get("page") == "2" {
+		return 2
+	}
+	return 1
+}
+
+var contractCases = []contractCase{
+	{
+		fixtures: "pixabay",
+		newProvider: func(baseURL string) (APIProvider, *ProviderClient) {
+			p := NewPixabayProvider(context.Background(), "test-key")
+			p.SetBaseURL(baseURL)
+			return p, p.client
+		},
+		page: queryPage,
+		first: ImageResult{
+			ID: "195893", URL: "https://pixabay.com/en/blossom-bloom-flower-195893/",
+			DownloadURL: "{{server}}/images/195893.jpg", Width: 4000, Height: 2250,
+			Author: "Josch13", AuthorURL: "https://pixabay.com/users/Josch13-48777/", Source: "Pixabay",
+			Tags: []string{"blossom", "bloom", "flower"}, License: "Pixabay Content License",
+		},
+	},
+	{
+		fixtures: "unsplash",
+		newProvider: func(baseURL string) (APIProvider, *ProviderClient) {
+			p := NewUnsplashProvider(context.Background(), "test-key")
+			p.SetBaseURL(baseURL)
+			return p, p.client
+		},
+		page: queryPage,
+		first: ImageResult{
+			ID: "eOLpJytrbsQ", URL: "https://unsplash.com/photos/eOLpJytrbsQ",
+			DownloadURL: "{{server}}/photos/eOLpJytrbsQ/download", Width: 5245, Height: 3497,
+			Author: "Jeff Sheldon", AuthorURL: "https://unsplash.com/@ugmonk", Source: "Unsplash",
+			Tags: []string{"desk", "workspace"}, License: "Unsplash License",
+		},
+	},
+	{
+		fixtures: "pexels",
+		newProvider: func(baseURL string) (APIProvider, *ProviderClient) {
+			p := NewPexelsProvider(context.Background(), "test-key")
+			p.SetBaseURL(baseURL)
+			return p, p.client
+		},
+		page: queryPage,
+		first: ImageResult{
+			ID: "2014422", URL: "https://www.pexels.com/photo/brown-rocks-during-golden-hour-2014422/",
+			DownloadURL: "{{server}}/images/2014422.jpeg", Width: 3024, Height: 3024,
+			Author: "Joey Farina", AuthorURL: "https://www.pexels.com/@joey", Source: "Pexels",
+			Tags: []string{"Brown Rocks During Golden Hour"}, License: "Pexels License",
+		},
+	},
+	{
+		fixtures: "wallhaven",
+		newProvider: func(baseURL string) (APIProvider, *ProviderClient) {
+			p := NewWallhavenProvider(context.Background(), "")
+			p.SetBaseURL(baseURL)
+			return p, p.client
+		},
+		page: queryPage,
+		first: ImageResult{
+			ID: "94x38z", URL: "https://wallhaven.cc/w/94x38z",
+			DownloadURL: "{{server}}/full/94/wallhaven-94x38z.jpg", Width: 6742, Height: 3534,
+			Source: "Wallhaven", Tags: []string{"anime"},
+		},
+	},
+	{
+		fixtures: "wikimedia",
+		newProvider: func(baseURL string) (APIProvider, *ProviderClient) {
+			p := NewWikimediaProvider(context.Background())
+			p.SetBaseURL(baseURL)
+			return p, p.client
+		},
+		page: func(r *http.Request) int {
+			if offset := r.URL.Query().Get("gsroffset"); offset != "" && offset != "0" {
+				return 2
+			}
+			return 1
+		},
+		first: ImageResult{
+			ID: "2000", URL: "https://commons.wikimedia.org/wiki/File:Lake_Louise.jpg",
+			DownloadURL: "{{server}}/wikipedia/commons/a/a1/Lake_Louise.jpg", Width: 5000, Height: 3000,
+			Author: "Gorgo", Source: "Wikimedia", Tags: []string{}, License: "Public domain",
+		},
+	},
+	{
+		fixtures: "reddit",
+		newProvider: func(baseURL string) (APIProvider, *ProviderClient) {
+			p := NewRedditProvider(context.Background(), []string{"wallpapers"})
+			p.SetBaseURL(baseURL)
+			return p, p.client
+		},
+		page: func(r *http.Request) int {
+			if r.URL.Query().Get("after") != "" {
+				return 2
+			}
+			return 1
+		},
+		first: ImageResult{
+			ID: "1a9", URL: "https://www.reddit.com/r/wallpapers/comments/1a9/misty_mountains_at_dawn/",
+			DownloadURL: "{{server}}/i/misty.jpg", Width: 3840, Height: 2160,
+			Author: "alpine_shots", AuthorURL: "https://www.reddit.com/user/alpine_shots", Source: "Reddit",
+			Tags: []string{"wallpapers", "Landscape"},
+		},
+	},
+	{
+		fixtures: "generic",
+		newProvider: func(baseURL string) (APIProvider, *ProviderClient) {
+			p, err := NewGenericProvider(context.Background(), GenericProviderDefinition{
+				Name:        "Openverse",
+				BaseURL:     baseURL,
+				Params:      GenericParams{Query: "q", Page: "page", PerPage: "page_size"},
+				ResultsPath: "results",
+				TotalPath:   "result_count",
+				Fields: GenericFields{
+					ID: "id", URL: "foreign_landing_url", DownloadURL: "url", PreviewURL: "thumbnail",
+					Width: "width", Height: "height", Author: "creator", Tags: "tags.name", License: "license",
+				},
+			})
+			if err != nil {
+				panic(err)
+			}
+			return p, p.client
+		},
+		page: queryPage,
+		first: ImageResult{
+			ID: "a1", URL: "https://example.org/a1", DownloadURL: "{{server}}/images/a1.jpg",
+			Width: 4000, Height: 3000, Author: "Ana", Source: "Openverse",
+			Tags: []string{"forest"}, License: "by",
+		},
+	},
+}
+
+// contractServer replays a provider's fixtures. By default it serves the
+// requested page; respond overrides that for error cases.
+type contractServer struct {
+	*httptest.Server
+	t       *testing.T
+	tc      contractCase
+	mu      sync.Mutex
+	respond func(w http.ResponseWriter, r *http.Request)
+}
+
+func newContractServer(t *testing.T, tc contractCase) *contractServer {
+	s := &contractServer{t: t, tc: tc}
+	image := encodeImageToPNG(createTestImage(128, 128))
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		respond := s.respond
+		s.mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/download"):
+			fmt.Fprintf(w, `{"url":"http://%s/image.png"}`, r.Host) // Unsplash download tracking
+		case r.URL.Path == "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(image)
+		case respond != nil:
+			respond(w, r)
+		default:
+			s.serveFixture(w, fmt.Sprintf("page%d.json", tc.page(r)))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *contractServer) serveFixture(w http.ResponseWriter, name string) {
+	data, err := os.ReadFile(filepath.Join("testdata", "contract", s.tc.fixtures, name))
+	if err != nil {
+		s.t.Errorf("missing fixture: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(strings.ReplaceAll(string(data), "{{server}}", s.URL)))
+}
+
+func (s *contractServer) setResponse(respond func(w http.ResponseWriter, r *http.Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.respond = respond
+}
+
+func TestProviderContract(t *testing.T) {
+	for _, tc := range contractCases {
+		t.Run(tc.fixtures, func(t *testing.T) {
+			server := newContractServer(t, tc)
+			provider, client := tc.newProvider(server.URL)
+			client.baseDelay = time.Millisecond
+			client.maxDelay = 5 * time.Millisecond
+			client.SetMinInterval(0)
+			options := SearchOptions{Page: 1, PerPage: 2}
+
+			t.Run("pagination", func(t *testing.T) {
+				first, err := provider.Search("nature", options)
+				if err != nil {
+					t.Fatalf("page 1: %v", err)
+				}
+				if len(first.Results) != 2 || !first.HasMore {
+					t.Fatalf("page 1: got %d results, hasMore=%v; want 2 and true", len(first.Results), first.HasMore)
+				}
+				assertResult(t, first.Results[0], tc.first, server.URL)
+
+				next := options
+				next.Page = 2
+				second, err := provider.Search("nature", next)
+				if err != nil {
+					t.Fatalf("page 2: %v", err)
+				}
+				if len(second.Results) != 1 || second.HasMore {
+					t.Errorf("page 2: got %d results, hasMore=%v; want 1 and false", len(second.Results), second.HasMore)
+				}
+				if second.Page != 2 {
+					t.Errorf("page 2 reported page %d", second.Page)
+				}
+			})
+
+			t.Run("empty results", func(t *testing.T) {
+				server.setResponse(func(w http.ResponseWriter, r *http.Request) {
+					server.serveFixture(w, "empty.json")
+				})
+				page, err := provider.Search("no such thing", options)
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				if page.Results == nil || len(page.Results) != 0 || page.HasMore {
+					t.Errorf("got %d results (nil=%v), hasMore=%v", len(page.Results), page.Results == nil, page.HasMore)
+				}
+			})
+
+			t.Run("malformed JSON", func(t *testing.T) {
+				server.setResponse(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`{"results": [{"id": `))
+				})
+				if _, err := provider.Search("broken", options); err == nil {
+					t.Error("expected an error for a truncated response")
+				}
+			})
+
+			t.Run("non-200 statuses", func(t *testing.T) {
+				statuses := []struct {
+					code  int
+					check func(error) bool
+				}{
+					{http.StatusUnauthorized, func(err error) bool { var e *UnauthorizedError; return errors.As(err, &e) }},
+					{http.StatusNotFound, func(err error) bool { var e *NotFoundError; return errors.As(err, &e) }},
+					{http.StatusTooManyRequests, func(err error) bool { var e *RateLimitError; return errors.As(err, &e) }},
+					{http.StatusBadGateway, func(err error) bool {
+						var e *StatusError
+						return errors.As(err, &e) && e.StatusCode == http.StatusBadGateway
+					}},
+				}
+				for _, status := range statuses {
+					server.setResponse(func(w http.ResponseWriter, r *http.Request) {
+						http.Error(w, http.StatusText(status.code), status.code)
+					})
+					_, err := provider.Search("status", options)
+					if !status.check(err) {
+						t.Errorf("status %d: got %T %v", status.code, err, err)
+					}
+				}
+			})
+
+			t.Run("oversized download", func(t *testing.T) {
+				streaming, ok := provider.(StreamingProvider)
+				if !ok {
+					t.Fatal("provider does not stream downloads")
+				}
+				d := newTestDownloader(t, 64)
+				if path, err := streaming.DownloadFile(d, server.URL+"/image.png", nil); err == nil {
+					os.Remove(path)
+					t.Error("expected a download over the size limit to fail")
+				}
+				if entries, _ := os.ReadDir(d.dir); len(entries) != 0 {
+					t.Errorf("failed download left %d staging files", len(entries))
+				}
+
+				d = newTestDownloader(t, DefaultMaxDownloadBytes)
+				path, err := streaming.DownloadFile(d, server.URL+"/image.png", nil)
+				if err != nil {
+					t.Fatalf("download within the limit failed: %v", err)
+				}
+				os.Remove(path)
+			})
+		})
+	}
+}
+
+// assertResult compares the fields the fixtures pin down. {{server}} in
+// want's DownloadURL is replaced with the test server's URL.
+func assertResult(t *testing.T, got, want ImageResult, serverURL string) {
+	t.Helper()
+	want.DownloadURL = strings.ReplaceAll(want.DownloadURL, "{{server}}", serverURL)
+	if got.ID != want.ID || got.URL != want.URL || got.DownloadURL != want.DownloadURL ||
+		got.Width != want.Width || got.Height != want.Height || got.Author != want.Author ||
+		got.AuthorURL != want.AuthorURL || got.Source != want.Source || got.License != want.License {
+		t.Errorf("first result = %+v\nwant %+v", got, want)
+	}
+	if strings.Join(got.Tags, "|") != strings.Join(want.Tags, "|") {
+		t.Errorf("tags = %q, want %q", got.Tags, want.Tags)
+	}
+	if got.PreviewURL != "" && !strings.HasPrefix(got.PreviewURL, serverURL) {
+		t.Errorf("preview URL %q does not point at the fixture server", got.PreviewURL)
+	}
+}
+
+// TestLocalFolderProviderContract runs the parts of the contract that apply
+// to a provider reading from disk against a temporary folder. There is no
+// HTTP response to break, so the malformed JSON and status cases are left out.
+func TestLocalFolderProviderContract(t *testing.T) {
+	root := t.TempDir()
+	image := encodeImageToPNG(createTestImage(128, 72))
+	for _, name := range []string{"nature-1.png", "nature-2.png", "nature-3.png"} {
+		if err := os.WriteFile(filepath.Join(root, name), image, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	provider := NewLocalFolderProvider(context.Background(), []string{root})
+	options := SearchOptions{Page: 1, PerPage: 2}
+
+	t.Run("pagination", func(t *testing.T) {
+		first, err := provider.Search("nature", options)
+		if err != nil {
+			t.Fatalf("page 1: %v", err)
+		}
+		if len(first.Results) != 2 || !first.HasMore {
+			t.Fatalf("page 1: got %d results, hasMore=%v; want 2 and true", len(first.Results), first.HasMore)
+		}
+		path := filepath.Join(root, "nature-1.png")
+		sum := sha1.Sum([]byte(path))
+		assertResult(t, first.Results[0], ImageResult{
+			ID: hex.EncodeToString(sum[:8]), URL: localFileURL(path), DownloadURL: localFileURL(path),
+			Width: 128, Height: 72, Source: "Local",
+		}, "file://")
+
+		next := options
+		next.Page = 2
+		second, err := provider.Search("nature", next)
+		if err != nil {
+			t.Fatalf("page 2: %v", err)
+		}
+		if len(second.Results) != 1 || second.HasMore {
+			t.Errorf("page 2: got %d results, hasMore=%v; want 1 and false", len(second.Results), second.HasMore)
+		}
+		if second.Page != 2 {
+			t.Errorf("page 2 reported page %d", second.Page)
+		}
+	})
+
+	t.Run("empty results", func(t *testing.T) {
+		page, err := provider.Search("no such thing", options)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if page.Results == nil || len(page.Results) != 0 || page.HasMore {
+			t.Errorf("got %d results (nil=%v), hasMore=%v", len(page.Results), page.Results == nil, page.HasMore)
+		}
+	})
+
+	t.Run("oversized download", func(t *testing.T) {
+		fileURL := localFileURL(filepath.Join(root, "nature-1.png"))
+		d := newTestDownloader(t, 64)
+		if path, err := provider.DownloadFile(d, fileURL, nil); err == nil {
+			os.Remove(path)
+			t.Error("expected a download over the size limit to fail")
+		}
+		if entries, _ := os.ReadDir(d.dir); len(entries) != 0 {
+			t.Errorf("failed download left %d staging files", len(entries))
+		}
+
+		d = newTestDownloader(t, DefaultMaxDownloadBytes)
+		path, err := provider.DownloadFile(d, fileURL, nil)
+		if err != nil {
+			t.Fatalf("download within the limit failed: %v", err)
+		}
+		os.Remove(path)
+	})
+}
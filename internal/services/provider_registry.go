@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProviderTimeout bounds how long a fan-out search waits for one provider
+const DefaultProviderTimeout = 15 * time.Second
+
+// maxSearchSessions bounds how many queries keep cross-page dedupe state
+const maxSearchSessions = 32
+
+// FanOutResult is the merged outcome of searching several providers.
+// Failing providers are reported in Errors instead of failing the search.
+type FanOutResult struct {
+	Results []ImageResult     `json:"results"`
+	Errors  map[string]string `json:"errors"`
+	Total   int               `json:"total"`   // sum of the totals reported by each provider
+	HasMore bool              `json:"hasMore"` // true if any provider has another page
+}
+
+// ProviderStatus reports whether a provider is usable
+type ProviderStatus struct {
+	Name        string    `json:"name"`
+	Configured  bool      `json:"configured"`
+	Healthy     bool      `json:"healthy"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastChecked time.Time `json:"lastChecked"`
+}
+
+// ProviderRegistry holds the configured providers and fans searches out to them
+type ProviderRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]APIProvider // keyed by lower-case name
+	order     []string
+	health    map[string]ProviderStatus
+
+	// sessions remembers which results were already returned for a query,
+	// so later pages do not repeat them
+	sessionMu sync.Mutex
+	sessions  map[string]*searchSession
+}
+
+type searchSession struct {
+	seen     map[string]int // dedupe key → page it was first returned on
+	lastUsed time.Time
+}
+
+// NewProviderRegistry creates an empty registry
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{
+		providers: make(map[string]APIProvider),
+		health:    make(map[string]ProviderStatus),
+		sessions:  make(map[string]*searchSession),
+	}
+}
+
+// Register adds a provider, replacing any provider with the same name
+func (r *ProviderRegistry) Register(p APIProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := strings.ToLower(p.GetName())
+	if _, exists := r.providers[key]; !exists {
+		r.order = append(r.order, key)
+	}
+	r.providers[key] = p
+}
+
+// Get returns the provider registered under name (case-insensitive)
+func (r *ProviderRegistry) Get(name string) (APIProvider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.providers[strings.ToLower(name)]
+	return p, ok
+}
+
+// Names returns the registered provider names in registration order
+func (r *ProviderRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.order))
+	for _, key := range r.order {
+		names = append(names, r.providers[key].GetName())
+	}
+	return names
+}
+
+// Resolve turns a list of provider names into providers. An empty list or
+// "all" selects every registered provider.
+func (r *ProviderRegistry) Resolve(names []string) ([]APIProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := len(names) == 0
+	for _, name := range names {
+		if strings.EqualFold(trim(name), "all") {
+			all = true
+		}
+	}
+	if all {
+		providers := make([]APIProvider, 0, len(r.order))
+		for _, key := range r.order {
+			providers = append(providers, r.providers[key])
+		}
+		return providers, nil
+	}
+
+	providers := []APIProvider{}
+	added := map[string]bool{}
+	for _, name := range names {
+		key := strings.ToLower(trim(name))
+		p, ok := r.providers[key]
+		if !ok {
+			return nil, fmt.Errorf("provider not configured: %s", name)
+		}
+		if !added[key] {
+			providers = append(providers, p)
+			added[key] = true
+		}
+	}
+	return providers, nil
+}
+
+// ProviderForURL returns the registered provider that recognises rawURL
+func (r *ProviderRegistry) ProviderForURL(rawURL string) (APIProvider, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.order {
+		if m, ok := r.providers[key].(URLMatcher); ok && m.MatchesURL(u) {
+			return r.providers[key], true
+		}
+	}
+	return nil, false
+}
+
+// Search queries the named providers in parallel, each bounded by timeout,
+// and interleaves their results. Results already returned for earlier pages
+// of the same query, or by another provider, are dropped.
+//
+// Requests of providers that implement ContextSearcher are cancelled at
+// the timeout; other providers finish their search in the background.
+func (r *ProviderRegistry) Search(names []string, query string, options SearchOptions, timeout time.Duration) (*FanOutResult, error) {
+	providers, err := r.Resolve(names)
+	if err != nil {
+		return nil, err
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no image providers configured")
+	}
+	if timeout <= 0 {
+		timeout = DefaultProviderTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type outcome struct {
+		page *SearchPage
+		err  error
+	}
+	outcomes := make([]outcome, len(providers))
+
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p APIProvider) {
+			defer wg.Done()
+
+			done := make(chan outcome, 1)
+			go func() {
+				page, err := searchContext(ctx, p, query, options)
+				done <- outcome{page: page, err: err}
+			}()
+
+			var o outcome
+			select {
+			case o = <-done:
+			case <-ctx.Done():
+				o.err = ctx.Err()
+			}
+			if errors.Is(o.err, context.DeadlineExceeded) {
+				o.err = fmt.Errorf("timed out after %s", timeout)
+			}
+			outcomes[i] = o
+			r.recordHealth(p.GetName(), o.err)
+		}(i, p)
+	}
+	wg.Wait()
+
+	result := &FanOutResult{Results: []ImageResult{}, Errors: map[string]string{}}
+	lists := make([][]ImageResult, 0, len(providers))
+	for i, o := range outcomes {
+		if o.err != nil {
+			result.Errors[providers[i].GetName()] = o.err.Error()
+			continue
+		}
+		if o.page == nil {
+			continue
+		}
+		lists = append(lists, o.page.Results)
+		result.Total += o.page.Total
+		result.HasMore = result.HasMore || o.page.HasMore
+	}
+
+	result.Results = r.dropSeen(providers, query, options, interleaveResults(lists))
+	return result, nil
+}
+
+// SearchOne queries a single provider by name and records its health
+func (r *ProviderRegistry) SearchOne(name string, query string, options SearchOptions) (*SearchPage, error) {
+	p, ok := r.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("provider not configured: %s", name)
+	}
+	page, err := p.Search(query, options)
+	r.recordHealth(p.GetName(), err)
+	return page, err
+}
+
+// Status reports the health of every registered provider. Providers that
+// have not been queried yet are assumed healthy.
+func (r *ProviderRegistry) Status() []ProviderStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]ProviderStatus, 0, len(r.order))
+	for _, key := range r.order {
+		status, ok := r.health[key]
+		if !ok {
+			status = ProviderStatus{Healthy: true}
+		}
+		status.Name = r.providers[key].GetName()
+		status.Configured = true
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func (r *ProviderRegistry) recordHealth(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := ProviderStatus{Healthy: err == nil, LastChecked: time.Now()}
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	r.health[strings.ToLower(name)] = status
+}
+
+// dropSeen removes results already returned for this query, either by
+// another provider or on an earlier page. The first page starts afresh;
+// requesting a page again returns the same results.
+func (r *ProviderRegistry) dropSeen(providers []APIProvider, query string, options SearchOptions, results []ImageResult) []ImageResult {
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, strings.ToLower(p.GetName()))
+	}
+	sort.Strings(names)
+
+	keyOptions := options
+	keyOptions.Page = 0
+	key := fmt.Sprintf("%s|%s|%+v", strings.Join(names, ","), strings.ToLower(trim(query)), keyOptions)
+
+	r.sessionMu.Lock()
+	defer r.sessionMu.Unlock()
+
+	s, ok := r.sessions[key]
+	if !ok || options.Page <= 1 {
+		if !ok && len(r.sessions) >= maxSearchSessions {
+			r.evictOldestSession()
+		}
+		s = &searchSession{seen: make(map[string]int)}
+		r.sessions[key] = s
+	}
+	s.lastUsed = time.Now()
+
+	page := max(options.Page, 1)
+	inPage := make(map[string]bool)
+	unique := make([]ImageResult, 0, len(results))
+	for _, img := range results {
+		keys := dedupeKeys(img)
+		duplicate := false
+		for _, k := range keys {
+			first, ok := s.seen[k]
+			if inPage[k] || (ok && first < page) {
+				duplicate = true
+			}
+			inPage[k] = true
+			if !ok {
+				s.seen[k] = page
+			}
+		}
+		if !duplicate {
+			unique = append(unique, img)
+		}
+	}
+	return unique
+}
+
+// evictOldestSession must be called with sessionMu held
+func (r *ProviderRegistry) evictOldestSession() {
+	oldestKey := ""
+	var oldest time.Time
+	for key, s := range r.sessions {
+		if oldestKey == "" || s.lastUsed.Before(oldest) {
+			oldestKey, oldest = key, s.lastUsed
+		}
+	}
+	delete(r.sessions, oldestKey)
+}
+
+// interleaveResults merges result lists round-robin so no single provider
+// dominates the first screen of results
+func interleaveResults(lists [][]ImageResult) []ImageResult {
+	merged := []ImageResult{}
+	for i := 0; ; i++ {
+		added := false
+		for _, list := range lists {
+			if i < len(list) {
+				merged = append(merged, list[i])
+				added = true
+			}
+		}
+		if !added {
+			return merged
+		}
+	}
+}
+
+// dedupeKeys identifies a result by provider ID and by download location,
+// ignoring query strings that CDNs use for resizing and tracking
+func dedupeKeys(img ImageResult) []string {
+	keys := []string{}
+	if img.ID != "" {
+		keys = append(keys, "id:"+strings.ToLower(img.Source)+":"+img.ID)
+	}
+	if u, err := url.Parse(img.DownloadURL); err == nil && u.Path != "" {
+		keys = append(keys, "url:"+strings.ToLower(u.Host)+u.Path)
+	}
+	return keys
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// fakeProvider returns canned results for registry tests
+type fakeProvider struct {
+	name    string
+	host    string
+	results []ImageResult
+	err     error
+	delay   time.Duration
+}
+
+func (p *fakeProvider) GetName() string { return p.name }
+
+func (p *fakeProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	time.Sleep(p.delay)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &SearchPage{Results: p.results, Page: options.Page, Total: len(p.results)}, nil
+}
+
+func (p *fakeProvider) Download(imageURL string) ([]byte, error) { return nil, nil }
+
+func (p *fakeProvider) MatchesURL(u *url.URL) bool { return hostMatches(u, p.host) }
+
+func fakeResults(source string, ids ...string) []ImageResult {
+	results := []ImageResult{}
+	for _, id := range ids {
+		results = append(results, ImageResult{
+			ID:          id,
+			Source:      source,
+			DownloadURL: fmt.Sprintf("https://%s.example/%s.jpg?w=1920", source, id),
+		})
+	}
+	return results
+}
+
+func resultIDs(results []ImageResult) []string {
+	ids := []string{}
+	for _, r := range results {
+		ids = append(ids, r.Source+":"+r.ID)
+	}
+	return ids
+}
+
+func TestProviderRegistrySearchInterleaves(t *testing.T) {
+	registry := NewProviderRegistry()
+	registry.Register(&fakeProvider{name: "A", results: fakeResults("a", "1", "2", "3")})
+	registry.Register(&fakeProvider{name: "B", results: fakeResults("b", "1")})
+
+	result, err := registry.Search(nil, "sea", SearchOptions{Page: 1}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprint(resultIDs(result.Results))
+	if want := "[a:1 b:1 a:2 a:3]"; got != want {
+		t.Errorf("results = %s, want %s", got, want)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("errors = %v", result.Errors)
+	}
+}
+
+func TestProviderRegistrySearchPartialFailure(t *testing.T) {
+	registry := NewProviderRegistry()
+	registry.Register(&fakeProvider{name: "Good", results: fakeResults("good", "1")})
+	registry.Register(&fakeProvider{name: "Broken", err: fmt.Errorf("API returned status 500")})
+	registry.Register(&fakeProvider{name: "Slow", results: fakeResults("slow", "1"), delay: 200 * time.Millisecond})
+
+	result, err := registry.Search([]string{"all"}, "sea", SearchOptions{Page: 1}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(resultIDs(result.Results)); got != "[good:1]" {
+		t.Errorf("results = %s", got)
+	}
+	if result.Errors["Broken"] == "" || result.Errors["Slow"] == "" {
+		t.Errorf("errors = %v, want Broken and Slow", result.Errors)
+	}
+
+	healthy := map[string]bool{}
+	for _, status := range registry.Status() {
+		healthy[status.Name] = status.Healthy
+	}
+	if !healthy["Good"] || healthy["Broken"] || healthy["Slow"] {
+		t.Errorf("health = %v", healthy)
+	}
+}
+
+func TestProviderRegistrySearchDedupesAcrossPages(t *testing.T) {
+	a := &fakeProvider{name: "A", results: fakeResults("a", "1", "2")}
+	registry := NewProviderRegistry()
+	registry.Register(a)
+
+	if _, err := registry.Search(nil, "sea", SearchOptions{Page: 1}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// Page 2 overlaps with page 1, as happens when new uploads shift results
+	a.results = fakeResults("a", "2", "3")
+	result, err := registry.Search(nil, "sea", SearchOptions{Page: 2}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(resultIDs(result.Results)); got != "[a:3]" {
+		t.Errorf("page 2 = %s, want [a:3]", got)
+	}
+
+	// Requesting page 2 again, e.g. on a refresh, still returns it
+	result, _ = registry.Search(nil, "sea", SearchOptions{Page: 2}, time.Second)
+	if got := fmt.Sprint(resultIDs(result.Results)); got != "[a:3]" {
+		t.Errorf("page 2 again = %s, want [a:3]", got)
+	}
+
+	// Starting over from page 1 forgets what was seen
+	result, _ = registry.Search(nil, "sea", SearchOptions{Page: 1}, time.Second)
+	if len(result.Results) != 2 {
+		t.Errorf("new page 1 returned %d results, want 2", len(result.Results))
+	}
+}
+
+// blockingProvider searches until its context is cancelled
+type blockingProvider struct {
+	fakeProvider
+	cancelled chan struct{}
+}
+
+func (p *blockingProvider) SearchContext(ctx context.Context, query string, options SearchOptions) (*SearchPage, error) {
+	<-ctx.Done()
+	close(p.cancelled)
+	return nil, ctx.Err()
+}
+
+func TestProviderRegistrySearchCancelsTimedOutProviders(t *testing.T) {
+	slow := &blockingProvider{fakeProvider: fakeProvider{name: "Slow"}, cancelled: make(chan struct{})}
+	registry := NewProviderRegistry()
+	registry.Register(&fakeProvider{name: "Good", results: fakeResults("good", "1")})
+	registry.Register(slow)
+
+	result, err := registry.Search(nil, "sea", SearchOptions{Page: 1}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Errors["Slow"]; got != "timed out after 50ms" {
+		t.Errorf("Slow error = %q", got)
+	}
+	select {
+	case <-slow.cancelled:
+	case <-time.After(time.Second):
+		t.Error("timed out search was not cancelled")
+	}
+}
+
+func TestProviderRegistryResolveAndRoute(t *testing.T) {
+	registry := NewProviderRegistry()
+	registry.Register(&fakeProvider{name: "Alpha", host: "alpha.example"})
+	registry.Register(&fakeProvider{name: "Beta", host: "beta.example"})
+
+	providers, err := registry.Resolve([]string{"beta", "BETA"})
+	if err != nil || len(providers) != 1 || providers[0].GetName() != "Beta" {
+		t.Errorf("Resolve(beta) = %v, %v", providers, err)
+	}
+	if _, err := registry.Resolve([]string{"gamma"}); err == nil {
+		t.Error("Resolve(gamma) should fail")
+	}
+
+	p, ok := registry.ProviderForURL("https://cdn.beta.example/x.jpg")
+	if !ok || p.GetName() != "Beta" {
+		t.Errorf("ProviderForURL routed to %v", p)
+	}
+	if _, ok := registry.ProviderForURL("https://notbeta.example/x.jpg"); ok {
+		t.Error("ProviderForURL matched an unrelated host")
+	}
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redditUserAgent follows Reddit's required "platform:app:version" format
+const redditUserAgent = "desktop:sweetdesk:v1.0 (+https://github.com/pedro3pv/SweetDesk)"
+
+// DefaultSubreddits are used when no subreddits are configured
+var DefaultSubreddits = []string{"wallpapers", "wallpaper", "WidescreenWallpaper"}
+
+// redditResolutionPattern matches resolution tags like "[3840x2160]" or "(1920 × 1080)"
+var redditResolutionPattern = regexp.MustCompile(`[\[\(]\s*(\d{3,5})\s*[xX×*]\s*(\d{3,5})\s*[\]\)]`)
+
+// redditBaseURL is the Reddit API endpoint
+const redditBaseURL = "https://www.reddit.com"
+
+// RedditProvider implements APIProvider for wallpaper subreddits using
+// Reddit's public JSON listings
+type RedditProvider struct {
+	subreddits []string
+	client     *ProviderClient
+	ctx        context.Context
+	baseURL    string
+
+	// Reddit paginates with "after" cursors rather than page numbers, so the
+	// cursor for each page of a listing is remembered as pages are fetched.
+	mu       sync.Mutex
+	listings map[string]*redditListing
+}
+
+// maxRedditListings bounds how many listings keep their page cursors. Reddit
+// stops a listing after about 1000 posts, so each one holds few pages.
+const maxRedditListings = 32
+
+// redditListing holds the "after" cursor of each page fetched for a listing
+type redditListing struct {
+	cursors  map[int]string
+	lastUsed time.Time
+}
+
+// redditPost is the subset of a Reddit link post we use
+type redditPost struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+	Permalink string `json:"permalink"`
+	Author    string `json:"author"`
+	Subreddit string `json:"subreddit"`
+	Flair     string `json:"link_flair_text"`
+	PostHint  string `json:"post_hint"`
+	IsVideo   bool   `json:"is_video"`
+	IsGallery bool   `json:"is_gallery"`
+	Over18    bool   `json:"over_18"`
+	Thumbnail string `json:"thumbnail"`
+	Preview   struct {
+		Images []struct {
+			Source      redditImage   `json:"source"`
+			Resolutions []redditImage `json:"resolutions"`
+		} `json:"images"`
+	} `json:"preview"`
+}
+
+type redditImage struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// NewRedditProvider creates a provider for the given subreddits
+func NewRedditProvider(ctx context.Context, subreddits []string) *RedditProvider {
+	cleaned := []string{}
+	for _, sub := range subreddits {
+		sub = strings.TrimPrefix(trim(sub), "r/")
+		if sub != "" {
+			cleaned = append(cleaned, sub)
+		}
+	}
+	if len(cleaned) == 0 {
+		cleaned = DefaultSubreddits
+	}
+	return &RedditProvider{
+		subreddits: cleaned,
+		client:     NewProviderClient("Reddit", 30*time.Second),
+		ctx:        ctx,
+		baseURL:    redditBaseURL,
+		listings:   make(map[string]*redditListing),
+	}
+}
+
+// SetBaseURL points the provider at another API endpoint, such as a mirror
+// or a local test server
+func (p *RedditProvider) SetBaseURL(baseURL string) {
+	p.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// GetName returns the provider name
+func (p *RedditProvider) GetName() string {
+	return "Reddit"
+}
+
+// Search lists image posts from the configured subreddits. An empty query
+// browses the listing; SearchOptions.Sort and TimeWindow select the order.
+func (p *RedditProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	return p.SearchContext(p.ctx, query, options)
+}
+
+// SearchContext is Search, cancelled with ctx
+func (p *RedditProvider) SearchContext(ctx context.Context, query string, options SearchOptions) (*SearchPage, error) {
+	page := options.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := options.PerPage
+	if limit <= 0 || limit > 100 {
+		limit = 25
+	}
+
+	listing := p.listingKey(query, options, limit)
+
+	// Walk forward from the closest known cursor to the requested page
+	start := page
+	for start > 1 {
+		if _, ok := p.cursor(listing, start); ok {
+			break
+		}
+		start--
+	}
+
+	// Reddit does not report totals, only whether another page exists
+	result := &SearchPage{Results: []ImageResult{}, Page: page, PerPage: limit}
+
+	var posts []redditPost
+	for current := start; current <= page; current++ {
+		after, _ := p.cursor(listing, current)
+		if current > 1 && after == "" {
+			return result, nil // listing exhausted
+		}
+		fetched, next, err := p.fetch(ctx, query, options, limit, after)
+		if err != nil {
+			return nil, err
+		}
+		p.setCursor(listing, current+1, next)
+		posts = fetched
+		result.HasMore = next != ""
+	}
+
+	for _, post := range posts {
+		if img, ok := redditImageResult(post, options); ok {
+			result.Results = append(result.Results, img)
+		}
+	}
+	return result, nil
+}
+
+// MatchesURL reports whether u is served by Reddit or the image hosts linked from it
+func (p *RedditProvider) MatchesURL(u *url.URL) bool {
+	return hostMatches(u, "redd.it", "reddit.com", "redditmedia.com", "imgur.com")
+}
+
+// DownloadFile streams an image to a staging file
+func (p *RedditProvider) DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error) {
+	return d.Fetch(p.ctx, p.client, imageURL, http.Header{"User-Agent": {redditUserAgent}}, progress)
+}
+
+// Download downloads an image from URL
+func (p *RedditProvider) Download(imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(p.ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", redditUserAgent)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	return data, nil
+}
+
+// fetch retrieves one listing page and returns its posts and next cursor
+func (p *RedditProvider) fetch(ctx context.Context, query string, options SearchOptions, limit int, after string) ([]redditPost, string, error) {
+	sort := redditSort(options.Sort, query != "")
+	subs := strings.Join(p.subreddits, "+")
+
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("raw_json", "1")
+	if after != "" {
+		params.Set("after", after)
+	}
+	if window := redditTimeWindow(options.TimeWindow); window != "" && (sort == "top" || query != "") {
+		params.Set("t", window)
+	}
+
+	var endpoint string
+	if query != "" {
+		endpoint = fmt.Sprintf("%s/r/%s/search.json", p.baseURL, subs)
+		params.Set("q", query)
+		params.Set("restrict_sr", "on")
+		params.Set("sort", sort)
+	} else {
+		endpoint = fmt.Sprintf("%s/r/%s/%s.json", p.baseURL, subs, sort)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", redditUserAgent)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			After    string `json:"after"`
+			Children []struct {
+				Kind string     `json:"kind"`
+				Data redditPost `json:"data"`
+			} `json:"children"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	posts := make([]redditPost, 0, len(result.Data.Children))
+	for _, child := range result.Data.Children {
+		if child.Kind == "t3" {
+			posts = append(posts, child.Data)
+		}
+	}
+	return posts, result.Data.After, nil
+}
+
+func (p *RedditProvider) listingKey(query string, options SearchOptions, limit int) string {
+	return strings.Join([]string{
+		strings.ToLower(query), options.Sort, options.TimeWindow, strconv.Itoa(limit),
+	}, "|")
+}
+
+func (p *RedditProvider) cursor(listing string, page int) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.listings[listing]
+	if !ok {
+		return "", false
+	}
+	after, ok := l.cursors[page]
+	return after, ok
+}
+
+func (p *RedditProvider) setCursor(listing string, page int, after string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.listings[listing]
+	if !ok {
+		if len(p.listings) >= maxRedditListings {
+			p.evictOldestListing()
+		}
+		l = &redditListing{cursors: make(map[int]string)}
+		p.listings[listing] = l
+	}
+	l.cursors[page] = after
+	l.lastUsed = time.Now()
+}
+
+// evictOldestListing must be called with mu held
+func (p *RedditProvider) evictOldestListing() {
+	oldestKey := ""
+	var oldest time.Time
+	for key, l := range p.listings {
+		if oldestKey == "" || l.lastUsed.Before(oldest) {
+			oldestKey, oldest = key, l.lastUsed
+		}
+	}
+	delete(p.listings, oldestKey)
+}
+
+// redditImageResult maps a post to an ImageResult, rejecting galleries,
+// videos, link posts and posts below the requested resolution
+func redditImageResult(post redditPost, options SearchOptions) (ImageResult, bool) {
+	if post.IsGallery || post.IsVideo {
+		return ImageResult{}, false
+	}
+	if post.PostHint != "" && post.PostHint != "image" {
+		return ImageResult{}, false
+	}
+	if !isDirectImageURL(post.URL) {
+		return ImageResult{}, false
+	}
+	if post.Over18 && !strings.Contains(strings.ToLower(options.Purity), "nsfw") {
+		return ImageResult{}, false
+	}
+
+	width, height, ok := parseResolutionTag(post.Title)
+	if !ok && len(post.Preview.Images) > 0 {
+		// Reddit's preview source is the original upload for i.redd.it images
+		width = post.Preview.Images[0].Source.Width
+		height = post.Preview.Images[0].Source.Height
+	}
+	if width < options.MinWidth || height < options.MinHeight {
+		return ImageResult{}, false
+	}
+	if width > 0 && height > 0 && !matchesOrientation(width, height, options.Orientation) {
+		return ImageResult{}, false
+	}
+
+	preview := post.Thumbnail
+	if len(post.Preview.Images) > 0 {
+		for _, res := range post.Preview.Images[0].Resolutions {
+			if res.Width <= 640 {
+				preview = html.UnescapeString(res.URL)
+			}
+		}
+	}
+	if !strings.HasPrefix(preview, "http") {
+		preview = post.URL
+	}
+
+	tags := []string{post.Subreddit}
+	if flair := trim(post.Flair); flair != "" {
+		tags = append(tags, flair)
+	}
+
+	return ImageResult{
+		ID:          post.ID,
+		URL:         "https://www.reddit.com" + post.Permalink,
+		DownloadURL: post.URL,
+		PreviewURL:  preview,
+		Width:       width,
+		Height:      height,
+		Author:      post.Author,
+		AuthorURL:   redditAuthorURL(post.Author),
+		Source:      "Reddit",
+		Tags:        tags,
+	}, true
+}
+
+// redditAuthorURL links to a poster's profile; deleted accounts have none
+func redditAuthorURL(author string) string {
+	if author == "" || author == "[deleted]" {
+		return ""
+	}
+	return "https://www.reddit.com/user/" + url.PathEscape(author)
+}
+
+// parseResolutionTag extracts "[WxH]"-style resolution tags from a post title
+func parseResolutionTag(title string) (int, int, bool) {
+	match := redditResolutionPattern.FindStringSubmatch(title)
+	if match == nil {
+		return 0, 0, false
+	}
+	width, _ := strconv.Atoi(match[1])
+	height, _ := strconv.Atoi(match[2])
+	return width, height, width > 0 && height > 0
+}
+
+// isDirectImageURL reports whether a post links straight to an image file
+func isDirectImageURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return false
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".jpg", ".jpeg", ".png", ".webp":
+		return true
+	}
+	return false
+}
+
+// redditSort validates SearchOptions.Sort for listings and searches
+func redditSort(sort string, isSearch bool) string {
+	switch sort {
+	case "hot", "top", "new":
+		return sort
+	case "relevance":
+		if isSearch {
+			return sort
+		}
+	}
+	if isSearch {
+		return "relevance"
+	}
+	return "hot"
+}
+
+// redditTimeWindow validates SearchOptions.TimeWindow
+func redditTimeWindow(window string) string {
+	switch window {
+	case "hour", "day", "week", "month", "year", "all":
+		return window
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestParseResolutionTag(t *testing.T) {
+	tests := []struct {
+		title         string
+		width, height int
+		ok            bool
+	}{
+		{"Misty forest [3840x2160]", 3840, 2160, true},
+		{"Neon city (2560 × 1440) OC", 2560, 1440, true},
+		{"[OC] Dunes [5120X1440]", 5120, 1440, true},
+		{"Lake at dusk 1920x1080", 0, 0, false},
+		{"No resolution here", 0, 0, false},
+	}
+	for _, tt := range tests {
+		width, height, ok := parseResolutionTag(tt.title)
+		if width != tt.width || height != tt.height || ok != tt.ok {
+			t.Errorf("parseResolutionTag(%q) = %d, %d, %v; want %d, %d, %v",
+				tt.title, width, height, ok, tt.width, tt.height, tt.ok)
+		}
+	}
+}
+
+func TestRedditImageResult(t *testing.T) {
+	base := redditPost{
+		ID:        "abc",
+		Title:     "Mountains [3840x2160]",
+		URL:       "https://i.redd.it/abc.jpg",
+		Permalink: "/r/wallpapers/comments/abc/mountains/",
+		Subreddit: "wallpapers",
+		PostHint:  "image",
+	}
+
+	img, ok := redditImageResult(base, SearchOptions{MinWidth: 1920, MinHeight: 1080})
+	if !ok {
+		t.Fatal("expected image post to be accepted")
+	}
+	if img.Width != 3840 || img.Height != 2160 || img.DownloadURL != base.URL {
+		t.Errorf("unexpected result %+v", img)
+	}
+
+	gallery := base
+	gallery.IsGallery = true
+	video := base
+	video.IsVideo = true
+	link := base
+	link.URL = "https://imgur.com/a/abc"
+	small := base
+	small.Title = "Mountains [1280x720]"
+	nsfw := base
+	nsfw.Over18 = true
+
+	for name, post := range map[string]redditPost{
+		"gallery": gallery, "video": video, "link": link, "small": small, "nsfw": nsfw,
+	} {
+		if _, ok := redditImageResult(post, SearchOptions{MinWidth: 1920, MinHeight: 1080}); ok {
+			t.Errorf("expected %s post to be skipped", name)
+		}
+	}
+}
+
+func TestRedditCursorsBounded(t *testing.T) {
+	p := NewRedditProvider(context.Background(), nil)
+	for i := 0; i < maxRedditListings+10; i++ {
+		p.setCursor(fmt.Sprintf("query%d|hot||25", i), 2, "t3_next")
+	}
+	if len(p.listings) != maxRedditListings {
+		t.Errorf("remembered %d listings, want %d", len(p.listings), maxRedditListings)
+	}
+	if _, ok := p.cursor(fmt.Sprintf("query%d|hot||25", maxRedditListings+9), 2); !ok {
+		t.Error("most recent listing was evicted")
+	}
+
+	p.setCursor("query0|hot||25", 3, "t3_more")
+	if after, ok := p.cursor("query0|hot||25", 3); !ok || after != "t3_more" {
+		t.Errorf("cursor = %q, %v; want t3_more", after, ok)
+	}
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// SeamCarveOptions marks regions of an image for SeamCarve. Masks may be
+// any size and are scaled to the image; a pixel is marked where the mask
+// is brighter than mid-grey.
+type SeamCarveOptions struct {
+	Protect image.Image // seams avoid these pixels
+	Remove  image.Image // seams go through these pixels first
+}
+
+// Mask energies outweigh the gradients of a whole seam; a single pixel's
+// gradient is at most 2×255
+const (
+	protectEnergy = 1e7
+	removeEnergy  = -1e7
+)
+
+// SeamCarve resizes img to width×height by removing or inserting seams:
+// connected paths of pixels with the least visual energy. Flat areas such
+// as sky absorb the change while the subject keeps its proportions.
+//
+// Regions marked in opts.Remove are carved out completely first and the
+// lost size is then inserted back. Growing by more than half is done in
+// rounds, so each round's seams still fall in the low-energy areas.
+//
+// To keep large images fast, several seams that do not touch are taken
+// from each energy map, up to 1/32 of the width at a time.
+func (ip *ImageProcessor) SeamCarve(img image.Image, width, height int, opts SeamCarveOptions) (image.Image, error) {
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("cannot seam carve an empty image")
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid seam carving size: %dx%d", width, height)
+	}
+
+	c := newCarver(img, opts)
+	if opts.Remove != nil {
+		c = c.removeMarked()
+	}
+	c.resizeWidth(width)
+	c = c.transposed()
+	c.resizeWidth(height)
+	return c.transposed().image(), nil
+}
+
+// carver holds an image being seam carved. Only vertical seams are
+// handled; horizontal ones are carved on the transposed image.
+type carver struct {
+	w, h   int
+	pix    []color.NRGBA
+	energy []float32
+	mask   []float32 // protectEnergy, removeEnergy or 0 per pixel
+
+	// Buffers reused between passes
+	lum  []float32
+	cost []float64
+	ends []int
+	used []bool
+	drop []bool
+}
+
+func newCarver(img image.Image, opts SeamCarveOptions) *carver {
+	b := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	c := &carver{w: b.Dx(), h: b.Dy()}
+	c.pix = make([]color.NRGBA, c.w*c.h)
+	for i := range c.pix {
+		c.pix[i] = color.NRGBA{src.Pix[i*4], src.Pix[i*4+1], src.Pix[i*4+2], src.Pix[i*4+3]}
+	}
+	c.mask = make([]float32, c.w*c.h)
+	c.applyMask(opts.Protect, protectEnergy)
+	c.applyMask(opts.Remove, removeEnergy)
+	c.computeEnergy()
+	return c
+}
+
+// applyMask marks the pixels where mask, scaled to the image, is bright
+func (c *carver) applyMask(mask image.Image, value float32) {
+	if mask == nil {
+		return
+	}
+	mb := mask.Bounds()
+	if mb.Empty() {
+		return
+	}
+	for y := 0; y < c.h; y++ {
+		my := mb.Min.Y + y*mb.Dy()/c.h
+		for x := 0; x < c.w; x++ {
+			mx := mb.Min.X + x*mb.Dx()/c.w
+			r, g, bl, a := mask.At(mx, my).RGBA()
+			if a > 0x7fff && (r+g+bl)/3 > 0x7fff {
+				c.mask[y*c.w+x] = value
+			}
+		}
+	}
+}
+
+// computeEnergy fills the energy map: the luminance gradient magnitude of
+// each pixel plus its mask energy
+func (c *carver) computeEnergy() {
+	n := c.w * c.h
+	c.lum = grow(c.lum, n)
+	for i, p := range c.pix {
+		c.lum[i] = 0.299*float32(p.R) + 0.587*float32(p.G) + 0.114*float32(p.B)
+	}
+	c.energy = grow(c.energy, n)
+	for y := 0; y < c.h; y++ {
+		up, down := max(y-1, 0)*c.w, min(y+1, c.h-1)*c.w
+		row := y * c.w
+		for x := 0; x < c.w; x++ {
+			dx := c.lum[row+min(x+1, c.w-1)] - c.lum[row+max(x-1, 0)]
+			dy := c.lum[down+x] - c.lum[up+x]
+			c.energy[row+x] = abs32(dx) + abs32(dy) + c.mask[row+x]
+		}
+	}
+}
+
+// findSeams returns up to n vertical seams, each a column per row, that
+// share no pixels. All come from one cumulative cost map: the cheapest
+// seam first, then others detouring around the seams already taken.
+func (c *carver) findSeams(n int) [][]int {
+	// Summed in float64: mask energies would swamp float32's precision
+	c.cost = grow(c.cost, c.w*c.h)
+	cost := c.cost
+	for x := 0; x < c.w; x++ {
+		cost[x] = float64(c.energy[x])
+	}
+	for y := 1; y < c.h; y++ {
+		prev, row := cost[(y-1)*c.w:y*c.w], cost[y*c.w:(y+1)*c.w]
+		energy := c.energy[y*c.w : (y+1)*c.w]
+		for x := range row {
+			best := prev[x]
+			if x > 0 && prev[x-1] < best {
+				best = prev[x-1]
+			}
+			if x < c.w-1 && prev[x+1] < best {
+				best = prev[x+1]
+			}
+			row[x] = float64(energy[x]) + best
+		}
+	}
+
+	last := cost[(c.h-1)*c.w:]
+	c.ends = grow(c.ends, c.w)
+	ends := c.ends
+	for x := range ends {
+		ends[x] = x
+	}
+	sort.Slice(ends, func(i, j int) bool { return last[ends[i]] < last[ends[j]] })
+
+	c.used = grow(c.used, c.w*c.h)
+	used := c.used
+	clear(used)
+	var seams [][]int
+	for _, end := range ends {
+		if len(seams) == n {
+			break
+		}
+		seam := make([]int, c.h)
+		seam[c.h-1] = end
+		ok := !used[(c.h-1)*c.w+end]
+		for y := c.h - 2; y >= 0 && ok; y-- {
+			x, best := seam[y+1], -1
+			for nx := max(x-1, 0); nx <= min(x+1, c.w-1); nx++ {
+				if !used[y*c.w+nx] && (best < 0 || cost[y*c.w+nx] < cost[y*c.w+best]) {
+					best = nx
+				}
+			}
+			seam[y], ok = best, best >= 0
+		}
+		if !ok {
+			continue
+		}
+		for y, x := range seam {
+			used[y*c.w+x] = true
+		}
+		seams = append(seams, seam)
+	}
+	return seams
+}
+
+// removeSeams deletes the seams' pixels, shifting the rest of each row
+// left. orig, if not nil, is kept in step with the pixels.
+func (c *carver) removeSeams(seams [][]int, orig []int32) {
+	c.drop = grow(c.drop, c.w*c.h)
+	drop := c.drop
+	clear(drop)
+	for _, seam := range seams {
+		for y, x := range seam {
+			drop[y*c.w+x] = true
+		}
+	}
+	j := 0
+	for i := range c.pix {
+		if drop[i] {
+			continue
+		}
+		c.pix[j], c.mask[j] = c.pix[i], c.mask[i]
+		if orig != nil {
+			orig[j] = orig[i]
+		}
+		j++
+	}
+	c.w -= len(seams)
+	c.pix, c.mask = c.pix[:j], c.mask[:j]
+	c.computeEnergy()
+}
+
+// seamBatch is how many seams to take from one energy map
+func (c *carver) seamBatch(remaining int) int {
+	return min(remaining, max(c.w/32, 1))
+}
+
+// insertSeams widens the image by k columns, duplicating the k seams that
+// would be removed first. Each new pixel blends the seam with its right
+// neighbour.
+func (c *carver) insertSeams(k int) {
+	// Find the seams on a copy, tracking each pixel's original column.
+	// c's energy is recomputed afterwards, so the copy borrows its buffers.
+	work := &carver{w: c.w, h: c.h}
+	work.takeBuffers(c)
+	work.pix = append([]color.NRGBA(nil), c.pix...)
+	work.mask = append([]float32(nil), c.mask...)
+	work.computeEnergy()
+	orig := make([]int32, c.w*c.h)
+	for i := range orig {
+		orig[i] = int32(i % c.w)
+	}
+	dup := make([]bool, c.w*c.h)
+	for found := 0; found < k; {
+		seams := work.findSeams(work.seamBatch(k - found))
+		for _, seam := range seams {
+			for y, x := range seam {
+				dup[y*c.w+int(orig[y*work.w+x])] = true
+			}
+		}
+		work.removeSeams(seams, orig)
+		found += len(seams)
+	}
+	c.takeBuffers(work)
+
+	w := c.w + k
+	pix := make([]color.NRGBA, 0, w*c.h)
+	mask := make([]float32, 0, w*c.h)
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			i := y*c.w + x
+			pix = append(pix, c.pix[i])
+			mask = append(mask, c.mask[i])
+			if dup[i] {
+				next := c.pix[y*c.w+min(x+1, c.w-1)]
+				pix = append(pix, blendNRGBA(c.pix[i], next))
+				mask = append(mask, c.mask[i])
+			}
+		}
+	}
+	c.w, c.pix, c.mask = w, pix, mask
+	c.computeEnergy()
+}
+
+// resizeWidth removes or inserts vertical seams until the image is width
+// pixels wide
+func (c *carver) resizeWidth(width int) {
+	for c.w > width {
+		c.removeSeams(c.findSeams(c.seamBatch(c.w-width)), nil)
+	}
+	for c.w < width {
+		c.insertSeams(min(width-c.w, max(c.w/2, 1)))
+	}
+}
+
+// removeMarked carves seams through the Remove mask until none of it is
+// left, along whichever axis the marked region is narrower
+func (c *carver) removeMarked() *carver {
+	minX, minY, maxX, maxY := c.w, c.h, -1, -1
+	for i, m := range c.mask {
+		if m == removeEnergy {
+			x, y := i%c.w, i/c.w
+			minX, minY = min(minX, x), min(minY, y)
+			maxX, maxY = max(maxX, x), max(maxY, y)
+		}
+	}
+	if maxX < 0 {
+		return c
+	}
+
+	horizontal := maxX-minX > maxY-minY
+	if horizontal {
+		c = c.transposed()
+	}
+	for c.w > 1 && c.hasMarked() {
+		// Small batches, so seams are not pushed out of the region
+		c.removeSeams(c.findSeams(min(c.seamBatch(c.w), 4)), nil)
+	}
+	if horizontal {
+		c = c.transposed()
+	}
+	return c
+}
+
+func (c *carver) hasMarked() bool {
+	for _, m := range c.mask {
+		if m == removeEnergy {
+			return true
+		}
+	}
+	return false
+}
+
+// transposed returns the image mirrored along its diagonal, so rows
+// become columns. It takes over c's buffers, so c is no longer usable.
+func (c *carver) transposed() *carver {
+	t := &carver{w: c.h, h: c.w}
+	t.takeBuffers(c)
+	t.pix = make([]color.NRGBA, len(c.pix))
+	t.mask = make([]float32, len(c.mask))
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			t.pix[x*t.w+y] = c.pix[y*c.w+x]
+			t.mask[x*t.w+y] = c.mask[y*c.w+x]
+		}
+	}
+	t.computeEnergy()
+	return t
+}
+
+// takeBuffers moves the reusable buffers, energy included, from other
+func (c *carver) takeBuffers(other *carver) {
+	c.energy, c.lum, c.cost, c.ends, c.used, c.drop = other.energy, other.lum, other.cost, other.ends, other.used, other.drop
+	other.energy, other.lum, other.cost, other.ends, other.used, other.drop = nil, nil, nil, nil, nil, nil
+}
+
+func (c *carver) image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, c.w, c.h))
+	for i, p := range c.pix {
+		img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = p.R, p.G, p.B, p.A
+	}
+	return img
+}
+
+func blendNRGBA(a, b color.NRGBA) color.NRGBA {
+	return color.NRGBA{
+		R: uint8((uint16(a.R) + uint16(b.R)) / 2),
+		G: uint8((uint16(a.G) + uint16(b.G)) / 2),
+		B: uint8((uint16(a.B) + uint16(b.B)) / 2),
+		A: uint8((uint16(a.A) + uint16(b.A)) / 2),
+	}
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// grow returns buf resized to n, reallocating only when it is too small
+func grow[T any](buf []T, n int) []T {
+	if cap(buf) < n {
+		return make([]T, n)
+	}
+	return buf[:n]
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// createSubjectImage draws squares of the given colours on a flat
+// background, side by side with wide gaps
+func createSubjectImage(width, height, size int, colors ...color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	step := width / (len(colors) + 1)
+	for i, c := range colors {
+		x0, y0 := step*(i+1)-size/2, (height-size)/2
+		for y := y0; y < y0+size; y++ {
+			for x := x0; x < x0+size; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+// colorWidth returns the widest run of pixels close to c in any row
+func colorWidth(img image.Image, c color.RGBA) int {
+	b := img.Bounds()
+	widest := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		run := 0
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			if absDiff(r>>8, uint32(c.R)) < 40 && absDiff(g>>8, uint32(c.G)) < 40 && absDiff(bl>>8, uint32(c.B)) < 40 {
+				run++
+				widest = max(widest, run)
+			} else {
+				run = 0
+			}
+		}
+	}
+	return widest
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+var (
+	subjectRed  = color.RGBA{220, 30, 30, 255}
+	subjectBlue = color.RGBA{30, 30, 220, 255}
+)
+
+func TestSeamCarveKeepsSubject(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	src := createSubjectImage(120, 80, 20, subjectRed)
+
+	sizes := [][2]int{{80, 80}, {160, 80}, {120, 60}, {120, 100}, {300, 50}}
+	for _, size := range sizes {
+		out, err := ip.SeamCarve(src, size[0], size[1], SeamCarveOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := out.Bounds(); b.Dx() != size[0] || b.Dy() != size[1] {
+			t.Errorf("carved to %v, want %dx%d", b, size[0], size[1])
+			continue
+		}
+		// The flat background takes the change; the subject is untouched
+		// unless the image becomes narrower than it
+		if got := colorWidth(out, subjectRed); got != 20 {
+			t.Errorf("%dx%d: subject is %d pixels wide, want 20", size[0], size[1], got)
+		}
+	}
+
+	if _, err := ip.SeamCarve(src, 0, 10, SeamCarveOptions{}); err == nil {
+		t.Error("expected an error for a zero width")
+	}
+}
+
+func TestSeamCarveMasks(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	src := createSubjectImage(150, 80, 20, subjectRed, subjectBlue)
+
+	// A quarter-size mask is scaled up to the image
+	remove := image.NewGray(image.Rect(0, 0, 75, 40))
+	for y := 13; y < 27; y++ {
+		for x := 44; x < 56; x++ {
+			remove.SetGray(x, y, color.Gray{255})
+		}
+	}
+	out, err := ip.SeamCarve(src, 150, 80, SeamCarveOptions{Remove: remove})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := colorWidth(out, subjectBlue); got != 0 {
+		t.Errorf("removed subject is still %d pixels wide", got)
+	}
+	if got := colorWidth(out, subjectRed); got != 20 {
+		t.Errorf("kept subject is %d pixels wide, want 20", got)
+	}
+
+	// Protecting the whole image but one column forces every seam through it
+	protect := image.NewGray(src.Bounds())
+	for i := range protect.Pix {
+		protect.Pix[i] = 255
+	}
+	for y := 0; y < 80; y++ {
+		protect.SetGray(50, y, color.Gray{0})
+	}
+	out, err = ip.SeamCarve(src, 149, 80, SeamCarveOptions{Protect: protect})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := colorWidth(out, subjectRed); got != 19 {
+		t.Errorf("subject under the only unprotected column is %d pixels wide, want 19", got)
+	}
+}
+
+func TestPrepareSourceSeamCarving(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	data := encodeImageToPNG(createSubjectImage(400, 300, 60, subjectRed))
+
+	same, changed, err := ip.PrepareSource(data, 1920, 1080, ProcessingOptions{})
+	if err != nil || changed || !bytes.Equal(same, data) {
+		t.Errorf("without seam carving: changed = %v, err = %v, want the input back", changed, err)
+	}
+
+	out, changed, err := ip.PrepareSource(data, 1920, 1080, ProcessingOptions{UseSeamCarving: true})
+	if err != nil || !changed {
+		t.Fatalf("PrepareSource: changed = %v, err = %v", changed, err)
+	}
+	width, height, _, err := ip.DecodeDimensions(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4:3 to 16:9 widens by a third, within the insertion limit
+	if width != 533 || height != 300 {
+		t.Errorf("carved to %dx%d, want 533x300", width, height)
+	}
+
+	if _, _, err := ip.PrepareSource(data, 1920, 1080, ProcessingOptions{UseSeamCarving: true, RemoveMask: "not base64!"}); err == nil {
+		t.Error("expected an error for an invalid mask")
+	}
+}
+
+func TestPrepareSourceSeamCarvesAtWorkingSize(t *testing.T) {
+	defer func(pixels int) { maxSeamCarvePixels = pixels }(maxSeamCarvePixels)
+	maxSeamCarvePixels = 200 * 150
+
+	ip := NewImageProcessor(context.Background())
+	data := encodeImageToPNG(createSubjectImage(400, 300, 60, subjectRed))
+	out, changed, err := ip.PrepareSource(data, 1920, 1080, ProcessingOptions{UseSeamCarving: true})
+	if err != nil || !changed {
+		t.Fatalf("PrepareSource: changed = %v, err = %v", changed, err)
+	}
+	// Halved to the working size, then widened to 16:9
+	if width, height, _, _ := ip.DecodeDimensions(out); width != 267 || height != 150 {
+		t.Errorf("carved to %dx%d, want 267x150", width, height)
+	}
+
+	// Sources already in the target aspect ratio are not scaled down
+	data = encodeImageToPNG(createSubjectImage(320, 180, 60, subjectRed))
+	if _, changed, err := ip.PrepareSource(data, 1920, 1080, ProcessingOptions{UseSeamCarving: true}); err != nil || changed {
+		t.Errorf("16:9 source: changed = %v, err = %v, want the input back", changed, err)
+	}
+}
+
+func TestSeamCarveSize(t *testing.T) {
+	cases := []struct {
+		w, h, tw, th int
+		wantW, wantH int
+	}{
+		{400, 300, 1920, 1080, 533, 300},    // 4:3 to 16:9: insert columns
+		{1920, 800, 1920, 1080, 1920, 1080}, // 21:9 to 16:9: insert rows
+		{300, 300, 1920, 1080, 450, 253},    // 1:1 to 16:9: insert half, remove rows
+		{1080, 1920, 1920, 1080, 1620, 911}, // portrait to landscape
+		{1920, 1080, 3840, 2160, 1920, 1080},
+	}
+	for _, c := range cases {
+		w, h := seamCarveSize(c.w, c.h, c.tw, c.th)
+		if w != c.wantW || h != c.wantH {
+			t.Errorf("seamCarveSize(%dx%d → %dx%d) = %dx%d, want %dx%d", c.w, c.h, c.tw, c.th, w, h, c.wantW, c.wantH)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL follows Pixabay's requirement to cache requests for 24 hours
+	DefaultCacheTTL = 24 * time.Hour
+
+	// DefaultCacheMaxStale is how long past the TTL an entry is still served
+	// while a fresh copy is fetched in the background
+	DefaultCacheMaxStale = 7 * 24 * time.Hour
+
+	// DefaultCacheMaxBytes bounds the size of the cache directory
+	DefaultCacheMaxBytes = 256 << 20
+)
+
+// pruneTarget is the share of maxBytes prune trims the cache down to, so
+// the directory is not walked again on the next few writes
+const pruneTarget = 0.9
+
+// SearchCache stores search responses and preview images on disk.
+// Entries younger than the TTL are served without a request; older entries
+// are served while being revalidated, and any entry is served when the
+// provider cannot be reached.
+type SearchCache struct {
+	dir      string
+	ttl      time.Duration
+	maxStale time.Duration
+	maxBytes int64
+	now      func() time.Time
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+
+	// size is the running total of the searches and previews on disk, or
+	// -1 until the directory has been walked
+	sizeMu sync.Mutex
+	size   int64
+
+	// previews memoizes CachedPreviews per search file, by modification time
+	previewsMu sync.Mutex
+	previews   map[string]cachedSearchPreviews
+}
+
+// CachedPreview is a cached preview image and the search result it shows
+type CachedPreview struct {
+	Path  string
+	Image ImageResult
+}
+
+type cachedSearchPreviews struct {
+	modTime  time.Time
+	previews []CachedPreview
+}
+
+// searchCacheEntry is the on-disk form of a cached search
+type searchCacheEntry struct {
+	StoredAt time.Time  `json:"storedAt"`
+	Page     SearchPage `json:"page"`
+}
+
+// NewSearchCache creates a cache rooted at dir
+func NewSearchCache(dir string, ttl time.Duration, maxBytes int64) (*SearchCache, error) {
+	for _, sub := range []string{"search", "previews"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+	return &SearchCache{
+		dir:        dir,
+		ttl:        ttl,
+		maxStale:   DefaultCacheMaxStale,
+		maxBytes:   maxBytes,
+		now:        time.Now,
+		refreshing: make(map[string]bool),
+		size:       -1,
+		previews:   make(map[string]cachedSearchPreviews),
+	}, nil
+}
+
+// Clear removes every cached search and preview
+func (c *SearchCache) Clear() error {
+	c.sizeMu.Lock()
+	defer c.sizeMu.Unlock()
+	c.size = -1
+	for _, sub := range []string{"search", "previews"} {
+		dir := filepath.Join(c.dir, sub)
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to clear cache: %w", err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to recreate cache directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// Preview returns a cached preview image, calling fetch when the cached copy
+// is missing or expired. An expired copy is returned if fetch fails.
+func (c *SearchCache) Preview(previewURL string, fetch func(string) ([]byte, error)) ([]byte, error) {
+	path := filepath.Join(c.dir, "previews", cacheKey(previewURL))
+
+	info, statErr := os.Stat(path)
+	if statErr == nil && c.now().Sub(info.ModTime()) < c.ttl {
+		if data, err := os.ReadFile(path); err == nil {
+			return data, nil
+		}
+	}
+
+	data, err := fetch(previewURL)
+	if err != nil {
+		if statErr == nil {
+			if cached, readErr := os.ReadFile(path); readErr == nil {
+				log.Printf("⚠️  Serving cached preview, fetch failed: %v", err)
+				return cached, nil
+			}
+		}
+		return nil, err
+	}
+
+	c.write(path, data)
+	return data, nil
+}
+
+// CachedPreviews lists the cached preview images together with the search
+// results they belong to. Previews whose search has left the cache are not
+// included, since there is nothing left to link them to.
+func (c *SearchCache) CachedPreviews() []CachedPreview {
+	entries, err := os.ReadDir(filepath.Join(c.dir, "search"))
+	if err != nil {
+		return nil
+	}
+
+	c.previewsMu.Lock()
+	defer c.previewsMu.Unlock()
+	seen := make(map[string]bool)
+	live := make(map[string]bool, len(entries))
+	var previews []CachedPreview
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		live[e.Name()] = true
+		memo, ok := c.previews[e.Name()]
+		if !ok || !memo.modTime.Equal(info.ModTime()) {
+			memo = cachedSearchPreviews{modTime: info.ModTime(), previews: c.searchPreviews(e.Name())}
+			c.previews[e.Name()] = memo
+		}
+		for _, p := range memo.previews {
+			if !seen[p.Path] {
+				seen[p.Path] = true
+				previews = append(previews, p)
+			}
+		}
+	}
+	for name := range c.previews {
+		if !live[name] {
+			delete(c.previews, name)
+		}
+	}
+
+	// Drop previews removed since their search was read
+	existing := previews[:0]
+	for _, p := range previews {
+		if _, err := os.Stat(p.Path); err == nil {
+			existing = append(existing, p)
+		}
+	}
+	return existing
+}
+
+// searchPreviews reads a cached search and returns its results' previews
+func (c *SearchCache) searchPreviews(name string) []CachedPreview {
+	entry, ok := c.loadSearch(strings.TrimSuffix(name, ".json"))
+	if !ok {
+		return nil
+	}
+	var previews []CachedPreview
+	for _, result := range entry.Page.Results {
+		if result.PreviewURL == "" {
+			continue
+		}
+		previews = append(previews, CachedPreview{
+			Path:  filepath.Join(c.dir, "previews", cacheKey(result.PreviewURL)),
+			Image: result,
+		})
+	}
+	return previews
+}
+
+// searchKey identifies a search by provider, query and options
+func (c *SearchCache) searchKey(provider string, query string, options SearchOptions) string {
+	encoded, _ := json.Marshal(options)
+	return cacheKey(strings.ToLower(provider) + "\n" + query + "\n" + string(encoded))
+}
+
+func (c *SearchCache) loadSearch(key string) (*searchCacheEntry, bool) {
+	data, err := os.ReadFile(filepath.Join(c.dir, "search", key+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var entry searchCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func (c *SearchCache) storeSearch(key string, page *SearchPage) {
+	data, err := json.Marshal(searchCacheEntry{StoredAt: c.now(), Page: *page})
+	if err != nil {
+		return
+	}
+	c.write(filepath.Join(c.dir, "search", key+".json"), data)
+}
+
+// write stores a file atomically and trims the cache once the running
+// total passes its size limit. Failures are logged, since the cache is
+// only an optimisation.
+func (c *SearchCache) write(path string, data []byte) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		log.Printf("⚠️  Failed to write cache entry: %v", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	var replaced int64
+	if info, statErr := os.Stat(path); statErr == nil {
+		replaced = info.Size()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("⚠️  Failed to write cache entry: %v", err)
+		return
+	}
+	c.grow(int64(len(data)) - replaced)
+}
+
+// grow adds delta bytes to the running size, pruning once it passes
+// maxBytes or when the size is not known yet
+func (c *SearchCache) grow(delta int64) {
+	c.sizeMu.Lock()
+	defer c.sizeMu.Unlock()
+	if c.size >= 0 {
+		c.size += delta
+	}
+	if c.size < 0 || c.size > c.maxBytes {
+		c.prune()
+	}
+}
+
+// prune deletes the least recently written entries until the cache fits
+// within pruneTarget of maxBytes, and records the size left. Only searches
+// and previews count; other files kept in the cache directory, such as the
+// image hash index, are left alone. It must be called with sizeMu held.
+func (c *SearchCache) prune() {
+	type cacheFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	files := []cacheFile{}
+	var total int64
+	for _, sub := range []string{"search", "previews"} {
+		filepath.WalkDir(filepath.Join(c.dir, sub), func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			files = append(files, cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+			total += info.Size()
+			return nil
+		})
+	}
+	defer func() { c.size = total }()
+	if total <= c.maxBytes {
+		return
+	}
+
+	target := int64(float64(c.maxBytes) * pruneTarget)
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= target {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			total -= f.size
+		}
+	}
+}
+
+// startRefresh marks key as being revalidated. It returns false if a
+// refresh is already running.
+func (c *SearchCache) startRefresh(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refreshing[key] {
+		return false
+	}
+	c.refreshing[key] = true
+	return true
+}
+
+func (c *SearchCache) endRefresh(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refreshing, key)
+}
+
+func cacheKey(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// CachedProvider wraps an APIProvider with a SearchCache. Downloads are not
+// cached; full-size images are only fetched when they are processed.
+type CachedProvider struct {
+	APIProvider
+	cache *SearchCache
+}
+
+// NewCachedProvider wraps p so its searches go through cache
+func NewCachedProvider(p APIProvider, cache *SearchCache) *CachedProvider {
+	return &CachedProvider{APIProvider: p, cache: cache}
+}
+
+// Search returns a cached page when one is fresh enough, otherwise queries
+// the provider. Cached pages have Cached set.
+func (p *CachedProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	return p.search(query, options, p.APIProvider.Search)
+}
+
+// SearchContext is Search, cancelling the provider's request with ctx.
+// Background refreshes of stale pages are not cancelled.
+func (p *CachedProvider) SearchContext(ctx context.Context, query string, options SearchOptions) (*SearchPage, error) {
+	return p.search(query, options, func(query string, options SearchOptions) (*SearchPage, error) {
+		return searchContext(ctx, p.APIProvider, query, options)
+	})
+}
+
+func (p *CachedProvider) search(query string, options SearchOptions, fetch func(string, SearchOptions) (*SearchPage, error)) (*SearchPage, error) {
+	key := p.cache.searchKey(p.GetName(), query, options)
+
+	entry, found := p.cache.loadSearch(key)
+	if found {
+		age := p.cache.now().Sub(entry.StoredAt)
+		if age < p.cache.ttl {
+			return entry.cachedPage(), nil
+		}
+		if age < p.cache.ttl+p.cache.maxStale {
+			p.revalidate(key, query, options)
+			return entry.cachedPage(), nil
+		}
+	}
+
+	page, err := fetch(query, options)
+	if err != nil {
+		if found {
+			log.Printf("⚠️  %s unavailable, serving cached results: %v", p.GetName(), err)
+			return entry.cachedPage(), nil
+		}
+		return nil, err
+	}
+	p.cache.storeSearch(key, page)
+	return page, nil
+}
+
+// MatchesURL forwards to the wrapped provider so downloads are still routed to it
+func (p *CachedProvider) MatchesURL(u *url.URL) bool {
+	m, ok := p.APIProvider.(URLMatcher)
+	return ok && m.MatchesURL(u)
+}
+
+// Tags forwards to the wrapped provider; it returns nil when the provider
+// has no tag lookup
+func (p *CachedProvider) Tags(id string) ([]string, error) {
+	if t, ok := p.APIProvider.(TagProvider); ok {
+		return t.Tags(id)
+	}
+	return nil, nil
+}
+
+// DownloadFile forwards to the wrapped provider, staging its in-memory
+// download when it cannot stream
+func (p *CachedProvider) DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error) {
+	if s, ok := p.APIProvider.(StreamingProvider); ok {
+		return s.DownloadFile(d, imageURL, progress)
+	}
+	data, err := p.APIProvider.Download(imageURL)
+	if err != nil {
+		return "", err
+	}
+	return d.Stage(bytes.NewReader(data), progress, int64(len(data)))
+}
+
+// revalidate refreshes a stale entry in the background
+func (p *CachedProvider) revalidate(key string, query string, options SearchOptions) {
+	if !p.cache.startRefresh(key) {
+		return
+	}
+	go func() {
+		defer p.cache.endRefresh(key)
+		page, err := p.APIProvider.Search(query, options)
+		if err != nil {
+			log.Printf("⚠️  Failed to refresh cached %s search: %v", p.GetName(), err)
+			return
+		}
+		p.cache.storeSearch(key, page)
+	}()
+}
+
+// cachedPage returns the stored page marked as coming from the cache. The
+// stored rate-limit state is dropped since it no longer reflects the quota.
+func (e *searchCacheEntry) cachedPage() *SearchPage {
+	page := e.Page
+	page.Cached = true
+	page.RateLimit = nil
+	return &page
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingProvider counts searches and can be switched offline
+type countingProvider struct {
+	fakeProvider
+	calls   atomic.Int32
+	offline atomic.Bool
+}
+
+func (p *countingProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	n := p.calls.Add(1)
+	if p.offline.Load() {
+		return nil, fmt.Errorf("network unreachable")
+	}
+	return &SearchPage{Results: fakeResults("c", fmt.Sprint(n)), Page: options.Page}, nil
+}
+
+// newTestCache returns a cache with a one hour TTL and a function that
+// advances its clock
+func newTestCache(t *testing.T, maxBytes int64) (*SearchCache, func(time.Duration)) {
+	t.Helper()
+	cache, err := NewSearchCache(t.TempDir(), time.Hour, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offset atomic.Int64
+	cache.now = func() time.Time { return time.Now().Add(time.Duration(offset.Load())) }
+	return cache, func(d time.Duration) { offset.Add(int64(d)) }
+}
+
+func TestCachedProviderSearch(t *testing.T) {
+	cache, advance := newTestCache(t, DefaultCacheMaxBytes)
+	inner := &countingProvider{fakeProvider: fakeProvider{name: "Counting", host: "c.example"}}
+	p := NewCachedProvider(inner, cache)
+	opts := SearchOptions{Page: 1, PerPage: 10}
+
+	first, err := p.Search("sea", opts)
+	if err != nil || first.Cached {
+		t.Fatalf("first search: %+v, %v", first, err)
+	}
+
+	// Fresh hit: no request
+	second, _ := p.Search("sea", opts)
+	if !second.Cached || inner.calls.Load() != 1 || second.Results[0].ID != "1" {
+		t.Errorf("expected cached page without a request, calls = %d", inner.calls.Load())
+	}
+
+	// Different options are a different entry
+	p.Search("sea", SearchOptions{Page: 2, PerPage: 10})
+	if inner.calls.Load() != 2 {
+		t.Errorf("page 2 should not reuse page 1, calls = %d", inner.calls.Load())
+	}
+
+	// Stale: served immediately, refreshed in the background
+	advance(2 * time.Hour)
+	stale, _ := p.Search("sea", opts)
+	if !stale.Cached || stale.Results[0].ID != "1" {
+		t.Errorf("expected stale page, got %+v", stale)
+	}
+	deadline := time.Now().Add(time.Second)
+	for inner.calls.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if inner.calls.Load() != 3 {
+		t.Errorf("expected a background refresh, calls = %d", inner.calls.Load())
+	}
+
+	// Offline beyond the stale window: the old entry is still returned
+	advance(DefaultCacheMaxStale + 2*time.Hour)
+	inner.offline.Store(true)
+	offline, err := p.Search("sea", opts)
+	if err != nil || !offline.Cached {
+		t.Errorf("expected cached fallback when offline, got %+v, %v", offline, err)
+	}
+	if _, err := p.Search("unseen query", opts); err == nil {
+		t.Error("expected an error for an uncached query while offline")
+	}
+}
+
+func TestSearchCachePreviewAndClear(t *testing.T) {
+	cache, _ := newTestCache(t, 1500)
+	fetches := 0
+	fetch := func(u string) ([]byte, error) {
+		fetches++
+		return make([]byte, 1000), nil
+	}
+
+	cache.Preview("https://cdn.example/a.jpg", fetch)
+	cache.Preview("https://cdn.example/a.jpg", fetch)
+	if fetches != 1 {
+		t.Errorf("expected 1 fetch, got %d", fetches)
+	}
+
+	// A second 1000-byte preview exceeds the limit and evicts the first
+	cache.Preview("https://cdn.example/b.jpg", fetch)
+	entries, _ := os.ReadDir(filepath.Join(cache.dir, "previews"))
+	if len(entries) != 1 {
+		t.Errorf("expected 1 preview after pruning, got %d", len(entries))
+	}
+
+	// Writes below the limit are counted without walking the directory
+	os.WriteFile(filepath.Join(cache.dir, "previews", "untracked"), make([]byte, 1000), 0644)
+	cache.Preview("https://cdn.example/c.jpg", func(string) ([]byte, error) { return make([]byte, 100), nil })
+	if entries, _ := os.ReadDir(filepath.Join(cache.dir, "previews")); len(entries) != 3 {
+		t.Errorf("expected 3 previews under the running size, got %d", len(entries))
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = os.ReadDir(filepath.Join(cache.dir, "previews"))
+	if len(entries) != 0 {
+		t.Errorf("expected empty cache after Clear, got %d entries", len(entries))
+	}
+}
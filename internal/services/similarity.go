@@ -0,0 +1,120 @@
+package services
+
+import (
+	"math/bits"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// MinSimilarityScore is the lowest score SimilarityIndex.Search returns
+	MinSimilarityScore = 0.5
+
+	// DefaultSimilarityLimit bounds the number of matches returned
+	DefaultSimilarityLimit = 20
+)
+
+// SimilarityMatch is an image that looks like the one searched for: a local
+// file, or a cached search result whose preview matched
+type SimilarityMatch struct {
+	Path  string       `json:"path,omitempty"`
+	Image *ImageResult `json:"image,omitempty"`
+
+	// Width and Height are the full image's resolution; for search results
+	// that is the original, not the matched preview
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Score runs from 0 to 1, where 1 is the same picture
+	Score float64 `json:"score"`
+}
+
+// Similarity scores how alike two images look, from 0 to 1. Shape, from
+// the perceptual hashes, weighs more than colour, from the histograms.
+func Similarity(a, b FileHash) float64 {
+	bitsDiffer := float64(bits.OnesCount64(a.Hash.DHash^b.Hash.DHash)+bits.OnesCount64(a.Hash.PHash^b.Hash.PHash)) / 2
+	// Unrelated images differ in about half of the 64 bits
+	shape := max(0, 1-bitsDiffer/32)
+	return 0.7*shape + 0.3*histogramIntersection(a.Histogram, b.Histogram)
+}
+
+// SimilarityIndex finds images resembling a given one among local folders
+// and cached search previews. Hashes are kept in a HashCache, so a search
+// only decodes files added since the last one.
+type SimilarityIndex struct {
+	hashes *HashCache
+	cache  *SearchCache // nil searches local folders only
+}
+
+// NewSimilarityIndex creates an index over hashes and the previews in cache
+func NewSimilarityIndex(hashes *HashCache, cache *SearchCache) *SimilarityIndex {
+	return &SimilarityIndex{hashes: hashes, cache: cache}
+}
+
+// Search returns up to limit images scoring at least MinSimilarityScore
+// against query, best first. dirs are scanned with their subfolders;
+// query.Path, if set, is left out of the results.
+func (x *SimilarityIndex) Search(query FileHash, dirs []string, limit int) []SimilarityMatch {
+	if limit <= 0 {
+		limit = DefaultSimilarityLimit
+	}
+	queryPath, _ := filepath.Abs(query.Path)
+
+	seen := map[string]bool{}
+	var matches []SimilarityMatch
+	for _, dir := range dirs {
+		files, err := x.hashes.HashDir(dir, true)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			abs, _ := filepath.Abs(f.Path)
+			if seen[abs] || (query.Path != "" && abs == queryPath) {
+				continue
+			}
+			seen[abs] = true
+			if score := Similarity(query, f); score >= MinSimilarityScore {
+				matches = append(matches, SimilarityMatch{Path: f.Path, Width: f.Width, Height: f.Height, Score: score})
+			}
+		}
+	}
+
+	if x.cache != nil {
+		for _, preview := range x.cache.CachedPreviews() {
+			f, err := x.hashes.HashFile(preview.Path)
+			if err != nil {
+				continue
+			}
+			if score := Similarity(query, f); score >= MinSimilarityScore {
+				image := preview.Image
+				matches = append(matches, SimilarityMatch{Image: &image, Width: image.Width, Height: image.Height, Score: score})
+			}
+		}
+	}
+
+	// Equally good matches are listed largest first, since the usual goal
+	// is finding the best copy of a picture
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Width*matches[i].Height > matches[j].Width*matches[j].Height
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// histogramIntersection returns the share of colour two histograms have in
+// common, from 0 to 1
+func histogramIntersection(a, b []byte) float64 {
+	if len(a) != histogramBins || len(b) != histogramBins {
+		return 0
+	}
+	var common int
+	for i := range a {
+		common += int(min(a[i], b[i]))
+	}
+	return min(float64(common)/255, 1)
+}
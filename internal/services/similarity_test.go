@@ -0,0 +1,86 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSimilarityIndexSearch(t *testing.T) {
+	scene := createTestScene(1280, 800, 11)
+	shared := encodeJPEG(t, resizeNearest(scene, 480, 300), 75)
+
+	// The output folder has the original and an unrelated wallpaper
+	outputDir := t.TempDir()
+	original := filepath.Join(outputDir, "original.png")
+	os.WriteFile(original, encodeImageToPNG(scene), 0644)
+	os.WriteFile(filepath.Join(outputDir, "other.png"), encodeImageToPNG(createTestScene(1280, 800, 12)), 0644)
+	sharedPath := filepath.Join(outputDir, "shared.jpg")
+	os.WriteFile(sharedPath, shared, 0644)
+
+	// A cached search holds a preview of the same picture
+	cache, err := NewSearchCache(t.TempDir(), DefaultCacheTTL, DefaultCacheMaxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := ImageResult{ID: "42", Source: "Wallhaven", PreviewURL: "https://th.example.com/42.jpg", Width: 3840, Height: 2400}
+	cache.storeSearch(cache.searchKey("Wallhaven", "city", SearchOptions{}), &SearchPage{Results: []ImageResult{
+		result,
+		{ID: "43", Source: "Wallhaven", PreviewURL: "https://th.example.com/43.jpg"}, // preview never fetched
+	}})
+	cache.Preview(result.PreviewURL, func(string) ([]byte, error) {
+		return encodeJPEG(t, resizeNearest(scene, 300, 188), 80), nil
+	})
+
+	hashesPath := filepath.Join(t.TempDir(), "hashes.json")
+	hashes, _ := NewHashCache(hashesPath)
+	index := NewSimilarityIndex(hashes, cache)
+
+	query, err := HashImageFile(sharedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := index.Search(query, []string{outputDir}, 0)
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, want the original and the cached preview: %+v", len(matches), matches)
+	}
+	for _, m := range matches {
+		if m.Score < 0.8 {
+			t.Errorf("match %+v scored %.2f, want at least 0.8", m, m.Score)
+		}
+	}
+	// Same score or not, the 3840x2400 original from the search is the larger copy
+	var local, remote *SimilarityMatch
+	for i := range matches {
+		if matches[i].Image != nil {
+			remote = &matches[i]
+		} else {
+			local = &matches[i]
+		}
+	}
+	if local == nil || local.Path != original || local.Width != 1280 {
+		t.Errorf("local match = %+v, want %s at 1280 wide", local, original)
+	}
+	if remote == nil || remote.Image.ID != "42" || remote.Width != 3840 {
+		t.Errorf("preview match = %+v, want result 42 at its full 3840 width", remote)
+	}
+
+	// A shared image that is not on disk is found the same way
+	query, _ = HashImageData(shared)
+	if matches := index.Search(query, []string{outputDir}, 1); len(matches) != 1 || matches[0].Score < 0.95 {
+		t.Errorf("search by data = %+v, want the identical shared.jpg first", matches)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	a, _ := HashImageData(encodeImageToPNG(createTestScene(640, 400, 21)))
+	if got := Similarity(a, a); got < 0.999 {
+		t.Errorf("Similarity with itself = %.3f, want 1", got)
+	}
+	for seed := int64(22); seed < 26; seed++ {
+		b, _ := HashImageData(encodeImageToPNG(createTestScene(640, 400, seed)))
+		if got := Similarity(a, b); got >= MinSimilarityScore {
+			t.Errorf("unrelated picture (seed %d) scored %.2f, want below %.2f", seed, got, MinSimilarityScore)
+		}
+	}
+}
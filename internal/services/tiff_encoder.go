@@ -0,0 +1,223 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+// TIFF compression schemes accepted by OutputFormat.TIFFCompression
+const (
+	TIFFDeflate = "deflate"
+	TIFFLZW     = "lzw"
+	TIFFNone    = "none"
+)
+
+// encodeTIFF writes img as a single-strip, 8-bit RGB TIFF, with an
+// unassociated alpha channel when img is not opaque. x/image's writer has
+// no LZW, hence this one. Compressed strips use the horizontal predictor.
+func encodeTIFF(w io.Writer, img image.Image, compression string) error {
+	b := img.Bounds()
+	if b.Empty() {
+		return fmt.Errorf("cannot encode an empty image")
+	}
+	width, height := b.Dx(), b.Dy()
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	samples := 4
+	if src.Opaque() {
+		samples = 3
+	}
+	rowBytes := width * samples
+	raw := make([]byte, 0, rowBytes*height)
+	for y := 0; y < height; y++ {
+		row := src.Pix[y*src.Stride : y*src.Stride+width*4]
+		for x := 0; x < width; x++ {
+			raw = append(raw, row[x*4:x*4+samples]...)
+		}
+	}
+
+	var (
+		scheme    uint32
+		predictor uint32 = 1
+		strip     []byte
+	)
+	if compression != TIFFNone {
+		predictor = 2
+		for y := 0; y < height; y++ {
+			row := raw[y*rowBytes : (y+1)*rowBytes]
+			for i := len(row) - 1; i >= samples; i-- {
+				row[i] -= row[i-samples]
+			}
+		}
+	}
+	switch compression {
+	case TIFFNone:
+		scheme, strip = 1, raw
+	case TIFFLZW:
+		scheme, strip = 5, lzwCompress(raw)
+	case TIFFDeflate, "":
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		if _, err := zw.Write(raw); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		scheme, strip = 8, buf.Bytes()
+	default:
+		return fmt.Errorf("unsupported TIFF compression: %s", compression)
+	}
+
+	// Layout: header, strip, then the IFD and the values that don't fit
+	// in their entries
+	type entry struct {
+		tag, typ uint16
+		values   []uint32
+	}
+	entries := []entry{
+		{256, 4, []uint32{uint32(width)}},
+		{257, 4, []uint32{uint32(height)}},
+		{258, 3, nil},
+		{259, 3, []uint32{scheme}},
+		{262, 3, []uint32{2}}, // RGB
+		{273, 4, []uint32{8}},
+		{277, 3, []uint32{uint32(samples)}},
+		{278, 4, []uint32{uint32(height)}},
+		{279, 4, []uint32{uint32(len(strip))}},
+		{282, 5, []uint32{72, 1}},
+		{283, 5, []uint32{72, 1}},
+		{296, 3, []uint32{2}}, // inches
+		{317, 3, []uint32{predictor}},
+	}
+	for i := 0; i < samples; i++ {
+		entries[2].values = append(entries[2].values, 8)
+	}
+	if samples == 4 {
+		entries = append(entries, entry{338, 3, []uint32{2}}) // unassociated alpha
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	size := func(e entry) int {
+		if e.typ == 3 {
+			return 2 * len(e.values)
+		}
+		return 4 * len(e.values)
+	}
+	ifdOffset := 8 + len(strip) + len(strip)&1
+	extraOffset := ifdOffset + 2 + 12*len(entries) + 4
+
+	out := make([]byte, 0, extraOffset+64)
+	out = append(out, 'I', 'I', 42, 0)
+	out = binary.LittleEndian.AppendUint32(out, uint32(ifdOffset))
+	out = append(out, strip...)
+	if len(strip)&1 == 1 {
+		out = append(out, 0)
+	}
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(entries)))
+	var extra []byte
+	for _, e := range entries {
+		count := len(e.values)
+		if e.typ == 5 {
+			count /= 2
+		}
+		out = binary.LittleEndian.AppendUint16(out, e.tag)
+		out = binary.LittleEndian.AppendUint16(out, e.typ)
+		out = binary.LittleEndian.AppendUint32(out, uint32(count))
+
+		var value []byte
+		for _, v := range e.values {
+			if e.typ == 3 {
+				value = binary.LittleEndian.AppendUint16(value, uint16(v))
+			} else {
+				value = binary.LittleEndian.AppendUint32(value, v)
+			}
+		}
+		if size(e) <= 4 {
+			value = append(value, make([]byte, 4-len(value))...)
+			out = append(out, value...)
+		} else {
+			out = binary.LittleEndian.AppendUint32(out, uint32(extraOffset+len(extra)))
+			extra = append(extra, value...)
+		}
+	}
+	out = binary.LittleEndian.AppendUint32(out, 0) // no next IFD
+	out = append(out, extra...)
+
+	_, err := w.Write(out)
+	return err
+}
+
+// lzwCompress codes data with TIFF's LZW: most significant bit first,
+// codes growing from 9 to 12 bits one code earlier than GIF's, and a clear
+// code once the table is full
+func lzwCompress(data []byte) []byte {
+	const (
+		lzwClear = 256
+		lzwEOI   = 257
+	)
+	var (
+		out   []byte
+		acc   uint32
+		nAcc  uint
+		width uint = 9
+		hi         = lzwEOI
+		table      = make(map[uint32]int, 4096)
+	)
+	put := func(code int) {
+		acc = acc<<width | uint32(code)
+		nAcc += width
+		for nAcc >= 8 {
+			out = append(out, byte(acc>>(nAcc-8)))
+			nAcc -= 8
+		}
+	}
+	// next follows the decoder, which adds an entry and may widen its
+	// codes after each one it reads. It reports whether the table was
+	// reset.
+	next := func() bool {
+		hi++
+		if hi+1 < 1<<width {
+			return false
+		}
+		if width < 12 {
+			width++
+			return false
+		}
+		put(lzwClear)
+		width, hi = 9, lzwEOI
+		clear(table)
+		return true
+	}
+
+	put(lzwClear)
+	if len(data) > 0 {
+		prefix := int(data[0])
+		for _, c := range data[1:] {
+			key := uint32(prefix)<<8 | uint32(c)
+			if code, ok := table[key]; ok {
+				prefix = code
+				continue
+			}
+			put(prefix)
+			if !next() {
+				table[key] = hi
+			}
+			prefix = int(c)
+		}
+		put(prefix)
+		next()
+	}
+	put(lzwEOI)
+	if nAcc > 0 {
+		out = append(out, byte(acc<<(8-nAcc)))
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// CropRect is a region of the source image in normalised coordinates: 0,0
+// is the top-left corner and 1,1 the bottom-right
+type CropRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// FocalPoint is a point of the source image in normalised coordinates that
+// a crop to the target aspect ratio is centred on
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// validateTransform checks the crop, focal point and rotation options
+func (o ProcessingOptions) validateTransform() error {
+	if o.Crop != nil && o.FocalPoint != nil {
+		return fmt.Errorf("crop rectangle and focal point are exclusive")
+	}
+	if c := o.Crop; c != nil {
+		if c.X < 0 || c.Y < 0 || c.Width <= 0 || c.Height <= 0 || c.X+c.Width > 1 || c.Y+c.Height > 1 {
+			return fmt.Errorf("crop rectangle out of bounds: %+v", *c)
+		}
+	}
+	if f := o.FocalPoint; f != nil && (f.X < 0 || f.X > 1 || f.Y < 0 || f.Y > 1) {
+		return fmt.Errorf("focal point out of bounds: %+v", *f)
+	}
+	if o.Rotate%90 != 0 {
+		return fmt.Errorf("rotation must be a multiple of 90 degrees: %d", o.Rotate)
+	}
+	return nil
+}
+
+// hasTransform reports whether any crop, rotation or flip is set
+func (o ProcessingOptions) hasTransform() bool {
+	return o.Crop != nil || o.FocalPoint != nil || o.Rotate%360 != 0 || o.FlipH || o.FlipV
+}
+
+// Transform crops img to opts.Crop, or to the largest window of the target
+// aspect ratio around opts.FocalPoint, then rotates it clockwise by
+// opts.Rotate and flips it. The focal point is ignored without a target
+// size.
+func (ip *ImageProcessor) Transform(img image.Image, targetWidth, targetHeight int, opts ProcessingOptions) (image.Image, error) {
+	if err := opts.validateTransform(); err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("cannot transform an empty image")
+	}
+	return transformImage(img, sourceCrop(b.Dx(), b.Dy(), targetWidth, targetHeight, opts), opts), nil
+}
+
+// sourceCrop returns the normalised region of a width×height source that
+// opts keeps, or nil for all of it
+func sourceCrop(width, height, targetWidth, targetHeight int, opts ProcessingOptions) *CropRect {
+	if opts.FocalPoint == nil || targetWidth <= 0 || targetHeight <= 0 {
+		return opts.Crop
+	}
+	// The window is cut before rotating, so a quarter turn swaps the
+	// target's sides
+	if rotation(opts.Rotate)%180 != 0 {
+		targetWidth, targetHeight = targetHeight, targetWidth
+	}
+	return focalCrop(width, height, targetWidth, targetHeight, *opts.FocalPoint)
+}
+
+// transformImage crops img to crop, if set, and applies the rotation and
+// flips of opts. Masks go through it with the image's crop, so they stay
+// aligned whatever their size.
+func transformImage(img image.Image, crop *CropRect, opts ProcessingOptions) *image.NRGBA {
+	r := img.Bounds()
+	if crop != nil {
+		r = cropPixels(r, *crop)
+	}
+	src := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	xdraw.Draw(src, src.Bounds(), img, r.Min, xdraw.Src)
+	return orient(src, rotation(opts.Rotate), opts.FlipH, opts.FlipV)
+}
+
+// rotation normalises a multiple of 90 degrees to 0, 90, 180 or 270
+func rotation(degrees int) int {
+	return (degrees%360 + 360) % 360
+}
+
+// cropPixels maps a normalised crop onto bounds, keeping at least a pixel
+func cropPixels(bounds image.Rectangle, c CropRect) image.Rectangle {
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	x0 := min(int(math.Round(c.X*w)), bounds.Dx()-1)
+	y0 := min(int(math.Round(c.Y*h)), bounds.Dy()-1)
+	x1 := max(int(math.Round((c.X+c.Width)*w)), x0+1)
+	y1 := max(int(math.Round((c.Y+c.Height)*h)), y0+1)
+	return image.Rect(x0, y0, min(x1, bounds.Dx()), min(y1, bounds.Dy())).Add(bounds.Min)
+}
+
+// focalCrop returns the largest window of a targetWidth:targetHeight aspect
+// ratio in a width×height image, centred on the focal point as far as the
+// image edges allow
+func focalCrop(width, height, targetWidth, targetHeight int, focal FocalPoint) *CropRect {
+	aspect := float64(targetWidth) / float64(targetHeight)
+	w, h := float64(width), float64(height)
+	cw, ch := w, w/aspect
+	if w/h > aspect {
+		cw, ch = h*aspect, h
+	}
+	x := min(max(focal.X*w-cw/2, 0), w-cw)
+	y := min(max(focal.Y*h-ch/2, 0), h-ch)
+	return &CropRect{X: x / w, Y: y / h, Width: cw / w, Height: ch / h}
+}
+
+// orient rotates src clockwise by a multiple of 90 degrees and then flips
+// it, in a single pass
+func orient(src *image.NRGBA, degrees int, flipH, flipV bool) *image.NRGBA {
+	if degrees == 0 && !flipH && !flipV {
+		return src
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	w, h := sw, sh
+	if degrees%180 != 0 {
+		w, h = sh, sw
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			ox, oy := x, y
+			if flipH {
+				ox = w - 1 - x
+			}
+			if flipV {
+				oy = h - 1 - y
+			}
+			// Source pixel shown at ox,oy after the rotation
+			var sx, sy int
+			switch degrees {
+			case 90:
+				sx, sy = oy, sh-1-ox
+			case 180:
+				sx, sy = sw-1-ox, sh-1-oy
+			case 270:
+				sx, sy = sw-1-oy, ox
+			default:
+				sx, sy = ox, oy
+			}
+			copy(out.Pix[y*out.Stride+x*4:y*out.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:])
+		}
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestTransformOrientation(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+
+	// A 3x2 image with a distinct red level per pixel
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := 0; i < 6; i++ {
+		src.SetNRGBA(i%3, i/3, color.NRGBA{R: uint8(i), A: 255})
+	}
+	rows := func(img image.Image) [][]uint8 {
+		b := img.Bounds()
+		var out [][]uint8
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			var row []uint8
+			for x := b.Min.X; x < b.Max.X; x++ {
+				row = append(row, color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA).R)
+			}
+			out = append(out, row)
+		}
+		return out
+	}
+
+	cases := []struct {
+		opts ProcessingOptions
+		want [][]uint8
+	}{
+		{ProcessingOptions{}, [][]uint8{{0, 1, 2}, {3, 4, 5}}},
+		{ProcessingOptions{Rotate: 90}, [][]uint8{{3, 0}, {4, 1}, {5, 2}}},
+		{ProcessingOptions{Rotate: 180}, [][]uint8{{5, 4, 3}, {2, 1, 0}}},
+		{ProcessingOptions{Rotate: -90}, [][]uint8{{2, 5}, {1, 4}, {0, 3}}},
+		{ProcessingOptions{FlipH: true}, [][]uint8{{2, 1, 0}, {5, 4, 3}}},
+		{ProcessingOptions{FlipV: true}, [][]uint8{{3, 4, 5}, {0, 1, 2}}},
+		// Flips apply to the rotated image
+		{ProcessingOptions{Rotate: 90, FlipH: true}, [][]uint8{{0, 3}, {1, 4}, {2, 5}}},
+		{ProcessingOptions{Crop: &CropRect{X: 1.0 / 3, Y: 0, Width: 2.0 / 3, Height: 1}, Rotate: 270}, [][]uint8{{2, 5}, {1, 4}}},
+	}
+	for _, c := range cases {
+		out, err := ip.Transform(src, 0, 0, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := rows(out)
+		if len(got) != len(c.want) {
+			t.Errorf("%+v: got %v, want %v", c.opts, got, c.want)
+			continue
+		}
+		for y := range got {
+			if string(got[y]) != string(c.want[y]) {
+				t.Errorf("%+v: got %v, want %v", c.opts, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFocalCrop(t *testing.T) {
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+	// 4:3 to 16:9 keeps the full width; the window slides down to the focal
+	// point but stops at the bottom edge
+	c := sourceCrop(400, 300, 1920, 1080, ProcessingOptions{FocalPoint: &FocalPoint{X: 0.2, Y: 0.95}})
+	if !near(c.X, 0) || !near(c.Width, 1) || !near(c.Height, 0.75) || !near(c.Y, 0.25) {
+		t.Errorf("focal crop = %+v, want the bottom 3/4 at full width", *c)
+	}
+
+	// Rotated a quarter turn, the window is cut portrait from the source
+	c = sourceCrop(400, 300, 1920, 1080, ProcessingOptions{FocalPoint: &FocalPoint{X: 0.5, Y: 0.5}, Rotate: 90})
+	if !near(c.Height, 1) || !near(c.Width, 300*9.0/16/400) || !near(c.X, (1-c.Width)/2) {
+		t.Errorf("rotated focal crop = %+v, want a centred 9:16 window", *c)
+	}
+
+	// No target, no window
+	if c := sourceCrop(400, 300, 0, 0, ProcessingOptions{FocalPoint: &FocalPoint{X: 0.5, Y: 0.5}}); c != nil {
+		t.Errorf("focal crop without a target = %+v, want none", *c)
+	}
+}
+
+func TestPrepareSourceTransform(t *testing.T) {
+	ip := NewImageProcessor(context.Background())
+	data := encodeImageToPNG(createSubjectImage(150, 80, 20, subjectRed, subjectBlue))
+
+	// Keep the right half, turned upright
+	out, changed, err := ip.PrepareSource(data, 1080, 1920, ProcessingOptions{
+		Crop:   &CropRect{X: 0.5, Y: 0, Width: 0.5, Height: 1},
+		Rotate: 90,
+	})
+	if err != nil || !changed {
+		t.Fatalf("PrepareSource: changed = %v, err = %v", changed, err)
+	}
+	img, _, _ := ip.LoadImageFromBytes(out)
+	if b := img.Bounds(); b.Dx() != 80 || b.Dy() != 75 {
+		t.Errorf("transformed to %v, want 80x75", b)
+	}
+	if colorWidth(img, subjectRed) != 0 || colorWidth(img, subjectBlue) != 20 {
+		t.Error("crop kept the wrong subject")
+	}
+
+	// A remove mask drawn on the full source still lines up after the crop
+	mask := image.NewGray(image.Rect(0, 0, 150, 80))
+	for y := 28; y < 52; y++ {
+		for x := 88; x < 112; x++ {
+			mask.SetGray(x, y, color.Gray{255})
+		}
+	}
+	out, _, err = ip.PrepareSource(data, 75, 80, ProcessingOptions{
+		Crop:           &CropRect{X: 0.5, Y: 0, Width: 0.5, Height: 1},
+		UseSeamCarving: true,
+		RemoveMask:     ip.ConvertToBase64(encodeImageToPNG(mask)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, _ = ip.LoadImageFromBytes(out)
+	if got := colorWidth(img, subjectBlue); got != 0 {
+		t.Errorf("masked subject is still %d pixels wide after cropping", got)
+	}
+
+	for _, opts := range []ProcessingOptions{
+		{Rotate: 45},
+		{Crop: &CropRect{X: 0.5, Y: 0, Width: 0.6, Height: 1}},
+		{Crop: &CropRect{Width: 1, Height: 1}, FocalPoint: &FocalPoint{X: 0.5, Y: 0.5}},
+		{FocalPoint: &FocalPoint{X: 1.5, Y: 0.5}},
+	} {
+		if _, _, err := ip.PrepareSource(data, 1920, 1080, opts); err == nil {
+			t.Errorf("PrepareSource with %+v succeeded, want an error", opts)
+		}
+	}
+}
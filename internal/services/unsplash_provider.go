@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// unsplashBaseURL is the Unsplash API endpoint
+const unsplashBaseURL = "https://api.unsplash.com"
+
+// UnsplashProvider implements APIProvider for Unsplash
+type UnsplashProvider struct {
+	accessKey string
+	client    *ProviderClient
+	ctx       context.Context
+	baseURL   string
+}
+
+// NewUnsplashProvider creates a new Unsplash provider
+func NewUnsplashProvider(ctx context.Context, accessKey string) *UnsplashProvider {
+	return &UnsplashProvider{
+		accessKey: accessKey,
+		client:    NewProviderClient("Unsplash", 30*time.Second),
+		ctx:       ctx,
+		baseURL:   unsplashBaseURL,
+	}
+}
+
+// SetBaseURL points the provider at another API endpoint, such as a mirror
+// or a local test server
+func (p *UnsplashProvider) SetBaseURL(baseURL string) {
+	p.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// GetName returns the provider name
+func (p *UnsplashProvider) GetName() string {
+	return "Unsplash"
+}
+
+// Search searches for photos on Unsplash
+func (p *UnsplashProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	return p.SearchContext(p.ctx, query, options)
+}
+
+// SearchContext is Search, cancelled with ctx
+func (p *UnsplashProvider) SearchContext(ctx context.Context, query string, options SearchOptions) (*SearchPage, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("page", fmt.Sprintf("%d", options.Page))
+	params.Set("per_page", fmt.Sprintf("%d", options.PerPage))
+	if orientation := unsplashOrientation(options.Orientation); orientation != "" {
+		params.Set("orientation", orientation)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/search/photos?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	p.authorize(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Total      int `json:"total"`
+		TotalPages int `json:"total_pages"`
+		Results    []struct {
+			ID     string `json:"id"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+			URLs   struct {
+				Raw     string `json:"raw"`
+				Full    string `json:"full"`
+				Regular string `json:"regular"`
+				Small   string `json:"small"`
+			} `json:"urls"`
+			Links struct {
+				HTML             string `json:"html"`
+				DownloadLocation string `json:"download_location"`
+			} `json:"links"`
+			User struct {
+				Name     string `json:"name"`
+				Username string `json:"username"`
+				Links    struct {
+					HTML string `json:"html"`
+				} `json:"links"`
+			} `json:"user"`
+			Tags []struct {
+				Title string `json:"title"`
+			} `json:"tags"`
+		} `json:"results"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	images := make([]ImageResult, 0, len(result.Results))
+	for _, photo := range result.Results {
+		if photo.Width < options.MinWidth || photo.Height < options.MinHeight {
+			continue
+		}
+
+		// Unsplash requires every download to be reported to the photo's
+		// download_location endpoint, which also returns the image URL, so
+		// it is the download URL. Otherwise full is the original resolution
+		// encoded as JPEG, and raw the untouched original.
+		downloadURL := photo.Links.DownloadLocation
+		if downloadURL == "" {
+			downloadURL = photo.URLs.Full
+		}
+		if downloadURL == "" {
+			downloadURL = photo.URLs.Raw
+		}
+		if downloadURL == "" {
+			continue
+		}
+
+		author := photo.User.Name
+		if author == "" {
+			author = photo.User.Username
+		}
+
+		tags := make([]string, 0, len(photo.Tags))
+		for _, tag := range photo.Tags {
+			if trimmed := trim(tag.Title); trimmed != "" {
+				tags = append(tags, trimmed)
+			}
+		}
+
+		images = append(images, ImageResult{
+			ID:          photo.ID,
+			URL:         photo.Links.HTML,
+			DownloadURL: downloadURL,
+			PreviewURL:  photo.URLs.Small,
+			Width:       photo.Width,
+			Height:      photo.Height,
+			Author:      author,
+			AuthorURL:   photo.User.Links.HTML,
+			Source:      "Unsplash",
+			Tags:        tags,
+			License:     "Unsplash License",
+			LicenseURL:  "https://unsplash.com/license",
+		})
+	}
+
+	page, perPage := pageBounds(options, 10)
+	return &SearchPage{
+		Results:   images,
+		Page:      page,
+		PerPage:   perPage,
+		Total:     result.Total,
+		TotalHits: result.Total,
+		HasMore:   page < result.TotalPages,
+		RateLimit: parseRateLimit(resp.Header),
+	}, nil
+}
+
+// MatchesURL reports whether u is served by Unsplash
+func (p *UnsplashProvider) MatchesURL(u *url.URL) bool {
+	return hostMatches(u, "unsplash.com")
+}
+
+// DownloadFile reports the download to Unsplash and streams the image to
+// a staging file
+func (p *UnsplashProvider) DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error) {
+	imageURL, err := p.resolveDownload(imageURL)
+	if err != nil {
+		return "", err
+	}
+	return d.Fetch(p.ctx, p.client, imageURL, nil, progress)
+}
+
+// Download reports the download to Unsplash, as the API guidelines
+// require, and downloads the image
+func (p *UnsplashProvider) Download(imageURL string) ([]byte, error) {
+	imageURL, err := p.resolveDownload(imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(p.ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	return data, nil
+}
+
+// resolveDownload calls the download_location endpoint a search result
+// gives as its download URL, which records the download, and returns the
+// image URL it answers with. Other Unsplash URLs cannot be reported; they
+// are downloaded as they are.
+func (p *UnsplashProvider) resolveDownload(imageURL string) (string, error) {
+	if !p.isDownloadLocation(imageURL) {
+		log.Printf("⚠️  Unsplash download not reported, %s is not a download_location URL", imageURL)
+		return imageURL, nil
+	}
+
+	req, err := http.NewRequestWithContext(p.ctx, "GET", imageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	p.authorize(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to report download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var location struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&location); err != nil {
+		return "", fmt.Errorf("failed to decode download location: %w", err)
+	}
+	if location.URL == "" {
+		return "", fmt.Errorf("Unsplash returned no image URL for %s", imageURL)
+	}
+	return location.URL, nil
+}
+
+// isDownloadLocation reports whether imageURL is an API download endpoint,
+// /photos/{id}/download. Only those are sent the access key.
+func (p *UnsplashProvider) isDownloadLocation(imageURL string) bool {
+	rest, ok := strings.CutPrefix(imageURL, p.baseURL+"/photos/")
+	if !ok {
+		return false
+	}
+	rest, _, _ = strings.Cut(rest, "?")
+	id, found := strings.CutSuffix(rest, "/download")
+	return found && id != "" && !strings.Contains(id, "/")
+}
+
+// authorize adds the Unsplash client ID header to an API request
+func (p *UnsplashProvider) authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Client-ID "+p.accessKey)
+	req.Header.Set("Accept-Version", "v1")
+}
+
+// unsplashOrientation maps SearchOptions.Orientation to Unsplash's values
+func unsplashOrientation(orientation string) string {
+	switch orientation {
+	case "horizontal", "landscape":
+		return "landscape"
+	case "vertical", "portrait":
+		return "portrait"
+	case "square", "squarish":
+		return "squarish"
+	default:
+		return ""
+	}
+}
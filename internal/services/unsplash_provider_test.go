@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestUnsplashOrientation(t *testing.T) {
+	tests := map[string]string{
+		"horizontal": "landscape",
+		"vertical":   "portrait",
+		"square":     "squarish",
+		"all":        "",
+		"":           "",
+	}
+	for in, want := range tests {
+		if got := unsplashOrientation(in); got != want {
+			t.Errorf("unsplashOrientation(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUnsplashDownloadTracksDownload(t *testing.T) {
+	var tracked int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photos/eOLpJytrbsQ/download":
+			if got := r.Header.Get("Authorization"); got != "Client-ID test-key" {
+				t.Errorf("unexpected Authorization header %q", got)
+			}
+			atomic.AddInt32(&tracked, 1)
+			fmt.Fprintf(w, `{"url":%q}`, "http://"+r.Host+"/photo.jpg")
+		case "/photo.jpg":
+			if r.Header.Get("Authorization") != "" {
+				t.Error("access key sent with the image request")
+			}
+			w.Write([]byte("image-bytes"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// A fresh provider, as after a restart: nothing was searched before
+	p := NewUnsplashProvider(context.Background(), "test-key")
+	p.SetBaseURL(server.URL)
+
+	data, err := p.Download(server.URL + "/photos/eOLpJytrbsQ/download?ixid=abc")
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if string(data) != "image-bytes" {
+		t.Errorf("unexpected download body %q", data)
+	}
+	if atomic.LoadInt32(&tracked) != 1 {
+		t.Errorf("expected 1 tracking call, got %d", tracked)
+	}
+
+	// Image URLs that are not a download endpoint are fetched unreported
+	data, err = p.Download(server.URL + "/photo.jpg")
+	if err != nil || string(data) != "image-bytes" {
+		t.Errorf("direct download: %q, %v", data, err)
+	}
+	if atomic.LoadInt32(&tracked) != 1 {
+		t.Errorf("expected no tracking call for a direct image URL, got %d", tracked-1)
+	}
+}
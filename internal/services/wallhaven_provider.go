@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// wallhavenBaseURL is the Wallhaven API endpoint
+const wallhavenBaseURL = "https://wallhaven.cc/api/v1"
+
+// WallhavenProvider implements APIProvider for Wallhaven
+type WallhavenProvider struct {
+	apiKey  string // optional, only required for NSFW results
+	client  *ProviderClient
+	ctx     context.Context
+	baseURL string
+}
+
+// NewWallhavenProvider creates a new Wallhaven provider
+func NewWallhavenProvider(ctx context.Context, apiKey string) *WallhavenProvider {
+	// Wallhaven allows 45 API calls per minute but sends no quota headers
+	client := NewProviderClient("Wallhaven", 30*time.Second)
+	client.SetMinInterval(time.Minute / 45)
+
+	return &WallhavenProvider{
+		apiKey:  apiKey,
+		client:  client,
+		ctx:     ctx,
+		baseURL: wallhavenBaseURL,
+	}
+}
+
+// SetBaseURL points the provider at another API endpoint, such as a mirror
+// or a local test server
+func (p *WallhavenProvider) SetBaseURL(baseURL string) {
+	p.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// GetName returns the provider name
+func (p *WallhavenProvider) GetName() string {
+	return "Wallhaven"
+}
+
+// Search searches for wallpapers on Wallhaven
+func (p *WallhavenProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	return p.SearchContext(p.ctx, query, options)
+}
+
+// SearchContext is Search, cancelled with ctx
+func (p *WallhavenProvider) SearchContext(ctx context.Context, query string, options SearchOptions) (*SearchPage, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("categories", wallhavenCategories(options.Categories))
+	params.Set("purity", wallhavenPurity(options.Purity))
+	if options.Page > 0 {
+		params.Set("page", fmt.Sprintf("%d", options.Page))
+	}
+
+	atLeast := options.AtLeast
+	if atLeast == "" && options.MinWidth > 0 && options.MinHeight > 0 {
+		atLeast = fmt.Sprintf("%dx%d", options.MinWidth, options.MinHeight)
+	}
+	if atLeast != "" {
+		params.Set("atleast", atLeast)
+	}
+
+	ratios := options.Ratios
+	if ratios == "" {
+		switch options.Orientation {
+		case "horizontal":
+			ratios = "landscape"
+		case "vertical":
+			ratios = "portrait"
+		}
+	}
+	if ratios != "" {
+		params.Set("ratios", ratios)
+	}
+
+	if p.apiKey != "" {
+		params.Set("apikey", p.apiKey)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data []struct {
+			ID         string `json:"id"`
+			URL        string `json:"url"`
+			Purity     string `json:"purity"`
+			Category   string `json:"category"`
+			DimensionX int    `json:"dimension_x"`
+			DimensionY int    `json:"dimension_y"`
+			Path       string `json:"path"`
+			Thumbs     struct {
+				Large    string `json:"large"`
+				Original string `json:"original"`
+				Small    string `json:"small"`
+			} `json:"thumbs"`
+			Uploader *struct {
+				Username string `json:"username"`
+			} `json:"uploader"`
+			Tags []struct {
+				Name string `json:"name"`
+			} `json:"tags"`
+		} `json:"data"`
+		Meta struct {
+			CurrentPage int `json:"current_page"`
+			LastPage    int `json:"last_page"`
+			Total       int `json:"total"`
+		} `json:"meta"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	images := make([]ImageResult, 0, len(result.Data))
+	for _, wp := range result.Data {
+		// Search results carry no tags, so the category stands in for
+		// them; Tags fetches the wallpaper's own from its details.
+		tags := []string{}
+		if wp.Category != "" {
+			tags = append(tags, wp.Category)
+		}
+		for _, tag := range wp.Tags {
+			if trimmed := trim(tag.Name); trimmed != "" {
+				tags = append(tags, trimmed)
+			}
+		}
+
+		author, authorURL := "", ""
+		if wp.Uploader != nil && wp.Uploader.Username != "" {
+			author = wp.Uploader.Username
+			authorURL = "https://wallhaven.cc/user/" + url.PathEscape(author)
+		}
+
+		images = append(images, ImageResult{
+			ID:          wp.ID,
+			URL:         wp.URL,
+			DownloadURL: wp.Path,
+			PreviewURL:  wp.Thumbs.Small,
+			Width:       wp.DimensionX,
+			Height:      wp.DimensionY,
+			Author:      author,
+			AuthorURL:   authorURL,
+			Source:      "Wallhaven",
+			Tags:        tags,
+		})
+	}
+
+	// Wallhaven's page size is fixed by the account settings (24 by default)
+	page, _ := pageBounds(options, 0)
+	if result.Meta.CurrentPage > 0 {
+		page = result.Meta.CurrentPage
+	}
+	return &SearchPage{
+		Results:   images,
+		Page:      page,
+		PerPage:   len(result.Data),
+		Total:     result.Meta.Total,
+		TotalHits: result.Meta.Total,
+		HasMore:   page < result.Meta.LastPage,
+	}, nil
+}
+
+// Tags fetches the tags of a wallpaper, with its category first like in
+// search results. Each call is one request against the rate limit, so it is
+// meant for a single image being looked at, not for whole result pages.
+func (p *WallhavenProvider) Tags(id string) ([]string, error) {
+	endpoint := p.baseURL + "/w/" + url.PathEscape(id)
+	if p.apiKey != "" {
+		endpoint += "?" + url.Values{"apikey": {p.apiKey}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(p.ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			Category string `json:"category"`
+			Tags     []struct {
+				Name string `json:"name"`
+			} `json:"tags"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	tags := []string{}
+	if result.Data.Category != "" {
+		tags = append(tags, result.Data.Category)
+	}
+	for _, tag := range result.Data.Tags {
+		if trimmed := trim(tag.Name); trimmed != "" {
+			tags = append(tags, trimmed)
+		}
+	}
+	return tags, nil
+}
+
+// MatchesURL reports whether u is served by Wallhaven
+func (p *WallhavenProvider) MatchesURL(u *url.URL) bool {
+	return hostMatches(u, "wallhaven.cc", "whvn.cc")
+}
+
+// DownloadFile streams an image to a staging file
+func (p *WallhavenProvider) DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error) {
+	return d.Fetch(p.ctx, p.client, imageURL, nil, progress)
+}
+
+// Download downloads an image from URL
+func (p *WallhavenProvider) Download(imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(p.ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	return data, nil
+}
+
+// wallhavenCategories converts a comma-separated list of category names
+// ("general", "anime", "people") into Wallhaven's 3-bit flag string.
+// Values that are already flag strings are passed through.
+func wallhavenCategories(categories string) string {
+	return wallhavenFlags(categories, []string{"general", "anime", "people"}, "111")
+}
+
+// wallhavenPurity converts a comma-separated list of purity levels
+// ("sfw", "sketchy", "nsfw") into Wallhaven's 3-bit flag string.
+// It defaults to SFW only.
+func wallhavenPurity(purity string) string {
+	return wallhavenFlags(purity, []string{"sfw", "sketchy", "nsfw"}, "100")
+}
+
+func wallhavenFlags(value string, names []string, fallback string) string {
+	value = strings.ToLower(trim(value))
+	if value == "" {
+		return fallback
+	}
+	if len(value) == len(names) && strings.Trim(value, "01") == "" {
+		return value
+	}
+
+	flags := []byte(strings.Repeat("0", len(names)))
+	for _, part := range splitString(value, ",") {
+		part = trim(part)
+		for i, name := range names {
+			if part == name {
+				flags[i] = '1'
+			}
+		}
+	}
+	if !strings.Contains(string(flags), "1") {
+		return fallback
+	}
+	return string(flags)
+}
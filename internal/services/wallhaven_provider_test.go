@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWallhavenFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{"purity defaults to sfw", wallhavenPurity, "", "100"},
+		{"purity by name", wallhavenPurity, "sfw,sketchy", "110"},
+		{"purity flags passthrough", wallhavenPurity, "111", "111"},
+		{"purity unknown names fall back", wallhavenPurity, "spicy", "100"},
+		{"categories default to all", wallhavenCategories, "", "111"},
+		{"categories by name", wallhavenCategories, "Anime", "010"},
+		{"categories with spaces", wallhavenCategories, "general, people", "101"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn(tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWallhavenTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/w/94x38z" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"id":"94x38z","category":"general","tags":[{"name":"landscape"},{"name":" "},{"name":"mountains"}]}}`)
+	}))
+	defer server.Close()
+
+	p := NewWallhavenProvider(context.Background(), "")
+	p.SetBaseURL(server.URL)
+	p.client.SetMinInterval(0)
+
+	tags, err := p.Tags("94x38z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(tags); got != "[general landscape mountains]" {
+		t.Errorf("tags = %s", got)
+	}
+	if _, err := p.Tags("missing"); err == nil {
+		t.Error("expected an error for an unknown wallpaper")
+	}
+}
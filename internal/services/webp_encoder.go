@@ -0,0 +1,532 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+)
+
+// encodeWebPLossless writes img as a lossless WebP (VP8L) image. x/image
+// only decodes WebP, so this is a small encoder of its own: the subtract
+// green and per-tile predictor transforms, then LZ77 and Huffman coding of
+// the residuals. It does not use a colour cache or several Huffman groups,
+// so files are somewhat larger than libwebp's, but usually well below PNG.
+func encodeWebPLossless(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	if b.Empty() {
+		return fmt.Errorf("cannot encode an empty image")
+	}
+	width, height := b.Dx(), b.Dy()
+	if width > 1<<14 || height > 1<<14 {
+		return fmt.Errorf("image too large for WebP: %dx%d", width, height)
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	argb := make([]uint32, width*height)
+	opaque := true
+	for i := range argb {
+		p := src.Pix[i*4 : i*4+4]
+		argb[i] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		opaque = opaque && p[3] == 0xff
+	}
+
+	bw := &vp8lBitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if opaque {
+		bw.write(0, 1)
+	} else {
+		bw.write(1, 1)
+	}
+	bw.write(0, 3) // version
+
+	// Subtract green
+	bw.write(1, 1)
+	bw.write(2, 2)
+	for i, p := range argb {
+		g := p >> 8 & 0xff
+		argb[i] = p&0xff00ff00 | ((p>>16&0xff-g)&0xff)<<16 | (p&0xff-g)&0xff
+	}
+
+	// Predictor, with a mode per tile
+	bw.write(1, 1)
+	bw.write(0, 2)
+	bw.write(vp8lPredictorBits-2, 3)
+	modes := vp8lPredict(argb, width, height)
+	bw.writeImage(modes, nTilesFor(width), false)
+
+	bw.write(0, 1) // no more transforms
+	bw.writeImage(argb, width, true)
+
+	data := bw.bytes()
+	var header [20]byte
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+len(data)+len(data)&1))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if len(data)&1 == 1 {
+		data = append(data, 0)
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// vp8lPredictorBits is the log-2 size of the predictor tiles
+const vp8lPredictorBits = 4
+
+func nTilesFor(size int) int {
+	return (size + 1<<vp8lPredictorBits - 1) >> vp8lPredictorBits
+}
+
+// vp8lModes are the predictors tried for each tile: L, T, the average of
+// L and T, Select, ClampAddSubtractFull and ClampAddSubtractHalf
+var vp8lModes = []uint32{1, 2, 7, 11, 12, 13}
+
+// vp8lPredict replaces argb with its residuals from the predictor that
+// fits each tile best, and returns the tiles' modes as an image
+func vp8lPredict(argb []uint32, width, height int) []uint32 {
+	tilesX, tilesY := nTilesFor(width), nTilesFor(height)
+	modes := make([]uint32, tilesX*tilesY)
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			best, bestCost := vp8lModes[0], -1
+			for _, mode := range vp8lModes {
+				cost := 0
+				for y := ty << vp8lPredictorBits; y < min((ty+1)<<vp8lPredictorBits, height); y++ {
+					for x := tx << vp8lPredictorBits; x < min((tx+1)<<vp8lPredictorBits, width); x++ {
+						cost += residualCost(argb[y*width+x], vp8lPrediction(argb, width, x, y, mode))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[ty*tilesX+tx] = 0xff000000 | best<<8
+		}
+	}
+
+	// Residuals are computed from the original pixels, back to front so
+	// each prediction still sees them
+	for i := len(argb) - 1; i >= 0; i-- {
+		x, y := i%width, i/width
+		mode := modes[(y>>vp8lPredictorBits)*tilesX+x>>vp8lPredictorBits] >> 8 & 0xf
+		argb[i] = subPixels(argb[i], vp8lPrediction(argb, width, x, y, mode))
+	}
+	return modes
+}
+
+// vp8lPrediction predicts the pixel at x,y as the decoder will: opaque
+// black for the first pixel, L along the first row, T down the first
+// column and the tile's mode elsewhere
+func vp8lPrediction(argb []uint32, width, x, y int, mode uint32) uint32 {
+	i := y*width + x
+	switch {
+	case i == 0:
+		return 0xff000000
+	case y == 0:
+		return argb[i-1]
+	case x == 0:
+		return argb[i-width]
+	}
+	l, t, tl := argb[i-1], argb[i-width], argb[i-width-1]
+	switch mode {
+	case 1:
+		return l
+	case 2:
+		return t
+	case 7:
+		return average2(l, t)
+	case 11:
+		pl, pt := 0, 0
+		for shift := 0; shift < 32; shift += 8 {
+			pl += absInt(int(t>>shift&0xff) - int(tl>>shift&0xff))
+			pt += absInt(int(l>>shift&0xff) - int(tl>>shift&0xff))
+		}
+		if pl < pt {
+			return l
+		}
+		return t
+	case 12:
+		return perChannel(l, t, tl, func(a, b, c int) int { return a + b - c })
+	case 13:
+		avg := average2(l, t)
+		return perChannel(avg, tl, 0, func(a, b, _ int) int { return a + (a-b)/2 })
+	}
+	return l
+}
+
+// perChannel applies f to each 8-bit channel, clamping the result
+func perChannel(a, b, c uint32, f func(a, b, c int) int) uint32 {
+	var out uint32
+	for shift := 0; shift < 32; shift += 8 {
+		v := f(int(a>>shift&0xff), int(b>>shift&0xff), int(c>>shift&0xff))
+		out |= uint32(min(max(v, 0), 255)) << shift
+	}
+	return out
+}
+
+func average2(a, b uint32) uint32 {
+	return ((a^b)&0xfefefefe)>>1 + a&b
+}
+
+func subPixels(a, b uint32) uint32 {
+	var out uint32
+	for shift := 0; shift < 32; shift += 8 {
+		out |= ((a>>shift&0xff - b>>shift&0xff) & 0xff) << shift
+	}
+	return out
+}
+
+// residualCost is the sum of the channels' distances from zero, treating
+// each as a signed byte
+func residualCost(p, prediction uint32) int {
+	r := subPixels(p, prediction)
+	cost := 0
+	for shift := 0; shift < 32; shift += 8 {
+		cost += absInt(int(int8(r >> shift)))
+	}
+	return cost
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// LZ77 limits: lengths and distances the format can code, and how many
+// earlier positions are tried for each match
+const (
+	vp8lMaxLength   = 4096
+	vp8lMaxDistance = 1<<20 - 120
+	vp8lMinMatch    = 3
+	vp8lChainLength = 16
+	vp8lHashBits    = 16
+)
+
+// vp8lToken is a literal pixel, or a backward reference when length > 0
+type vp8lToken struct {
+	argb     uint32
+	length   int
+	distCode int
+}
+
+// vp8lTokens finds backward references in pix with a hash chain. The pixel
+// directly left and the one directly above are always tried.
+func vp8lTokens(pix []uint32, width int) []vp8lToken {
+	distCodes := vp8lDistanceCodes(width)
+	head := make([]int32, 1<<vp8lHashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, len(pix))
+	hash := func(i int) uint32 {
+		return (pix[i]*0x1e35a7bd ^ pix[i+1]*0x9e3779b1) >> (32 - vp8lHashBits)
+	}
+	insert := func(i int) {
+		if i+1 < len(pix) {
+			h := hash(i)
+			prev[i], head[h] = head[h], int32(i)
+		}
+	}
+	matchLength := func(i, j int) int {
+		n := 0
+		for limit := min(len(pix)-i, vp8lMaxLength); n < limit && pix[i+n] == pix[j+n]; n++ {
+		}
+		return n
+	}
+
+	var tokens []vp8lToken
+	for i := 0; i < len(pix); {
+		bestLen, bestDist := 0, 0
+		try := func(j int) {
+			if j >= 0 && j < i && i-j <= vp8lMaxDistance {
+				if n := matchLength(i, j); n > bestLen {
+					bestLen, bestDist = n, i-j
+				}
+			}
+		}
+		try(i - 1)
+		try(i - width)
+		if i+1 < len(pix) {
+			for j, n := int(head[hash(i)]), 0; j >= 0 && n < vp8lChainLength; j, n = int(prev[j]), n+1 {
+				try(j)
+			}
+		}
+
+		if bestLen < vp8lMinMatch {
+			tokens = append(tokens, vp8lToken{argb: pix[i]})
+			insert(i)
+			i++
+			continue
+		}
+		code, ok := distCodes[bestDist]
+		if !ok {
+			code = bestDist + 120
+		}
+		tokens = append(tokens, vp8lToken{length: bestLen, distCode: code})
+		for k := 0; k < bestLen; k++ {
+			insert(i + k)
+		}
+		i += bestLen
+	}
+	return tokens
+}
+
+// vp8lDistanceMap is the format's table of short two-dimensional distances,
+// as yOffset<<4 | (8-xOffset)
+var vp8lDistanceMap = [120]uint8{
+	0x18, 0x07, 0x17, 0x19, 0x28, 0x06, 0x27, 0x29, 0x16, 0x1a,
+	0x26, 0x2a, 0x38, 0x05, 0x37, 0x39, 0x15, 0x1b, 0x36, 0x3a,
+	0x25, 0x2b, 0x48, 0x04, 0x47, 0x49, 0x14, 0x1c, 0x35, 0x3b,
+	0x46, 0x4a, 0x24, 0x2c, 0x58, 0x45, 0x4b, 0x34, 0x3c, 0x03,
+	0x57, 0x59, 0x13, 0x1d, 0x56, 0x5a, 0x23, 0x2d, 0x44, 0x4c,
+	0x55, 0x5b, 0x33, 0x3d, 0x68, 0x02, 0x67, 0x69, 0x12, 0x1e,
+	0x66, 0x6a, 0x22, 0x2e, 0x54, 0x5c, 0x43, 0x4d, 0x65, 0x6b,
+	0x32, 0x3e, 0x78, 0x01, 0x77, 0x79, 0x53, 0x5d, 0x11, 0x1f,
+	0x64, 0x6c, 0x42, 0x4e, 0x76, 0x7a, 0x21, 0x2f, 0x75, 0x7b,
+	0x31, 0x3f, 0x63, 0x6d, 0x52, 0x5e, 0x00, 0x74, 0x7c, 0x41,
+	0x4f, 0x10, 0x20, 0x62, 0x6e, 0x30, 0x73, 0x7d, 0x51, 0x5f,
+	0x40, 0x72, 0x7e, 0x61, 0x6f, 0x50, 0x71, 0x7f, 0x60, 0x70,
+}
+
+// vp8lDistanceCodes maps the distances the short codes stand for in an
+// image of the given width to the smallest such code
+func vp8lDistanceCodes(width int) map[int]int {
+	codes := make(map[int]int, len(vp8lDistanceMap))
+	for i := len(vp8lDistanceMap) - 1; i >= 0; i-- {
+		c := int(vp8lDistanceMap[i])
+		d := max((c>>4)*width+8-c&0xf, 1)
+		codes[d] = i + 1
+	}
+	return codes
+}
+
+// vp8lPrefix splits a length or distance into its prefix symbol and the
+// extra bits that follow it
+func vp8lPrefix(v int) (symbol int, extra uint32, n int) {
+	if v <= 4 {
+		return v - 1, 0, 0
+	}
+	v--
+	high := bitLength(v) - 1
+	second := v >> (high - 1) & 1
+	n = high - 1
+	return 2*high + second, uint32(v) & (1<<n - 1), n
+}
+
+// vp8lBitWriter packs bits least significant first
+type vp8lBitWriter struct {
+	buf   []byte
+	bits  uint64
+	nBits uint
+}
+
+func (b *vp8lBitWriter) write(v uint32, n uint) {
+	b.bits |= uint64(v) << b.nBits
+	b.nBits += n
+	for b.nBits >= 8 {
+		b.buf = append(b.buf, byte(b.bits))
+		b.bits >>= 8
+		b.nBits -= 8
+	}
+}
+
+func (b *vp8lBitWriter) bytes() []byte {
+	if b.nBits > 0 {
+		b.buf = append(b.buf, byte(b.bits))
+		b.bits, b.nBits = 0, 0
+	}
+	return b.buf
+}
+
+// writeImage codes pix, width pixels wide, with one group of five Huffman
+// codes: green with lengths, red, blue, alpha and distance. The main image
+// also says it uses no meta Huffman image.
+func (b *vp8lBitWriter) writeImage(pix []uint32, width int, main bool) {
+	tokens := vp8lTokens(pix, width)
+
+	freqs := [5][]int{make([]int, 256+24), make([]int, 256), make([]int, 256), make([]int, 256), make([]int, 40)}
+	for _, t := range tokens {
+		if t.length == 0 {
+			freqs[0][t.argb>>8&0xff]++
+			freqs[1][t.argb>>16&0xff]++
+			freqs[2][t.argb&0xff]++
+			freqs[3][t.argb>>24]++
+			continue
+		}
+		s, _, _ := vp8lPrefix(t.length)
+		freqs[0][256+s]++
+		s, _, _ = vp8lPrefix(t.distCode)
+		freqs[4][s]++
+	}
+
+	b.write(0, 1) // no colour cache
+	if main {
+		b.write(0, 1) // no meta Huffman image
+	}
+	var codes [5]vp8lCode
+	for i, f := range freqs {
+		codes[i] = b.writeCode(f)
+	}
+
+	for _, t := range tokens {
+		if t.length == 0 {
+			codes[0].put(b, int(t.argb>>8&0xff))
+			codes[1].put(b, int(t.argb>>16&0xff))
+			codes[2].put(b, int(t.argb&0xff))
+			codes[3].put(b, int(t.argb>>24))
+			continue
+		}
+		s, extra, n := vp8lPrefix(t.length)
+		codes[0].put(b, 256+s)
+		b.write(extra, uint(n))
+		s, extra, n = vp8lPrefix(t.distCode)
+		codes[4].put(b, s)
+		b.write(extra, uint(n))
+	}
+}
+
+// vp8lCode is a Huffman code as written to the stream. A code with a
+// single symbol takes no bits.
+type vp8lCode struct {
+	lengths []uint8
+	codes   []uint16
+	single  bool
+}
+
+func (c *vp8lCode) put(b *vp8lBitWriter, symbol int) {
+	if c.single {
+		return
+	}
+	n := uint(c.lengths[symbol])
+	b.write(reverseBits(uint32(c.codes[symbol]), n), n)
+}
+
+func reverseBits(v uint32, n uint) uint32 {
+	var r uint32
+	for i := uint(0); i < n; i++ {
+		r = r<<1 | v>>i&1
+	}
+	return r
+}
+
+// vp8lCodeLengthOrder is the order code length code lengths are stored in
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// writeCode builds a Huffman code for freqs and writes it: as a simple
+// code when one or two symbols below 256 are used, otherwise as code
+// lengths, themselves Huffman coded
+func (b *vp8lBitWriter) writeCode(freqs []int) vp8lCode {
+	var used []int
+	for s, f := range freqs {
+		if f > 0 {
+			used = append(used, s)
+		}
+	}
+	if len(used) == 0 {
+		used = []int{0}
+	}
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		b.write(1, 1)
+		b.write(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			b.write(0, 1)
+			b.write(uint32(used[0]), 1)
+		} else {
+			b.write(1, 1)
+			b.write(uint32(used[0]), 8)
+		}
+		code := vp8lCode{lengths: make([]uint8, len(freqs)), codes: make([]uint16, len(freqs)), single: len(used) == 1}
+		if len(used) == 2 {
+			b.write(uint32(used[1]), 8)
+			code.lengths[used[0]], code.lengths[used[1]] = 1, 1
+			code.codes[used[1]] = 1
+		}
+		return code
+	}
+
+	lengths := huffmanLengths(freqs, 15)
+	code := vp8lCode{lengths: lengths, codes: canonicalCodes(lengths), single: len(used) == 1}
+
+	// Run-length code the lengths: 16 repeats the previous length 3-6
+	// times, 17 and 18 write 3-10 and 11-138 zeros
+	type clToken struct {
+		symbol int
+		extra  uint32
+		n      uint
+	}
+	var cl []clToken
+	for i := 0; i < len(lengths); {
+		l := int(lengths[i])
+		run := 1
+		for i+run < len(lengths) && int(lengths[i+run]) == l {
+			run++
+		}
+		i += run
+		if l == 0 {
+			for run >= 3 {
+				if run >= 11 {
+					n := min(run, 138)
+					cl = append(cl, clToken{18, uint32(n - 11), 7})
+					run -= n
+				} else {
+					n := min(run, 10)
+					cl = append(cl, clToken{17, uint32(n - 3), 3})
+					run -= n
+				}
+			}
+		} else {
+			cl = append(cl, clToken{l, 0, 0})
+			run--
+			for run >= 3 {
+				n := min(run, 6)
+				cl = append(cl, clToken{16, uint32(n - 3), 2})
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			cl = append(cl, clToken{l, 0, 0})
+		}
+	}
+
+	clFreqs := make([]int, 19)
+	for _, t := range cl {
+		clFreqs[t.symbol]++
+	}
+	clLengths := huffmanLengths(clFreqs, 7)
+	clCode := vp8lCode{lengths: clLengths, codes: canonicalCodes(clLengths)}
+	nonzero := 0
+	for _, l := range clLengths {
+		if l > 0 {
+			nonzero++
+		}
+	}
+	clCode.single = nonzero == 1
+
+	count := 4
+	for i, s := range vp8lCodeLengthOrder {
+		if clLengths[s] > 0 {
+			count = max(count, i+1)
+		}
+	}
+	b.write(0, 1)
+	b.write(uint32(count-4), 4)
+	for _, s := range vp8lCodeLengthOrder[:count] {
+		b.write(uint32(clLengths[s]), 3)
+	}
+	b.write(0, 1) // lengths for the whole alphabet
+	for _, t := range cl {
+		clCode.put(b, t.symbol)
+		b.write(t.extra, t.n)
+	}
+	return code
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// wikimediaUserAgent identifies SweetDesk as required by the Wikimedia API etiquette
+const wikimediaUserAgent = "SweetDesk/1.0 (https://github.com/pedro3pv/SweetDesk)"
+
+// wikimediaBaseURL is the Wikimedia API endpoint
+const wikimediaBaseURL = "https://commons.wikimedia.org/w/api.php"
+
+// WikimediaProvider implements APIProvider for Wikimedia Commons
+type WikimediaProvider struct {
+	client  *ProviderClient
+	ctx     context.Context
+	baseURL string
+}
+
+// wikimediaResponse mirrors the subset of the MediaWiki query API we use
+type wikimediaResponse struct {
+	Continue map[string]interface{} `json:"continue"`
+	Query    struct {
+		SearchInfo struct {
+			TotalHits int `json:"totalhits"`
+		} `json:"searchinfo"`
+		Pages map[string]struct {
+			PageID    int    `json:"pageid"`
+			Title     string `json:"title"`
+			Index     int    `json:"index"`
+			ImageInfo []struct {
+				URL            string `json:"url"`
+				DescriptionURL string `json:"descriptionurl"`
+				ThumbURL       string `json:"thumburl"`
+				Width          int    `json:"width"`
+				Height         int    `json:"height"`
+				Mime           string `json:"mime"`
+				ExtMetadata    map[string]struct {
+					Value interface{} `json:"value"`
+				} `json:"extmetadata"`
+			} `json:"imageinfo"`
+		} `json:"pages"`
+	} `json:"query"`
+}
+
+// NewWikimediaProvider creates a new Wikimedia Commons provider.
+// Commons does not require an API key.
+func NewWikimediaProvider(ctx context.Context) *WikimediaProvider {
+	return &WikimediaProvider{
+		client:  NewProviderClient("Wikimedia", 30*time.Second),
+		ctx:     ctx,
+		baseURL: wikimediaBaseURL,
+	}
+}
+
+// SetBaseURL points the provider at another API endpoint, such as a mirror
+// or a local test server
+func (p *WikimediaProvider) SetBaseURL(baseURL string) {
+	p.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// GetName returns the provider name
+func (p *WikimediaProvider) GetName() string {
+	return "Wikimedia"
+}
+
+// Search searches for bitmap files on Wikimedia Commons
+func (p *WikimediaProvider) Search(query string, options SearchOptions) (*SearchPage, error) {
+	return p.SearchContext(p.ctx, query, options)
+}
+
+// SearchContext is Search, cancelled with ctx
+func (p *WikimediaProvider) SearchContext(ctx context.Context, query string, options SearchOptions) (*SearchPage, error) {
+	search := query + " filetype:bitmap"
+	if options.MinWidth > 0 {
+		search += fmt.Sprintf(" filew:>%d", options.MinWidth-1)
+	}
+	if options.MinHeight > 0 {
+		search += fmt.Sprintf(" fileh:>%d", options.MinHeight-1)
+	}
+
+	page, perPage := pageBounds(options, 20)
+	offset := (page - 1) * perPage
+
+	params := p.imageInfoParams()
+	params.Set("generator", "search")
+	params.Set("gsrsearch", search)
+	params.Set("gsrnamespace", "6") // File: namespace
+	params.Set("gsrlimit", fmt.Sprintf("%d", perPage))
+	params.Set("gsroffset", fmt.Sprintf("%d", offset))
+
+	results, info, err := p.query(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]ImageResult, 0, len(results))
+	for _, img := range results {
+		if matchesOrientation(img.Width, img.Height, options.Orientation) {
+			images = append(images, img)
+		}
+	}
+	return &SearchPage{
+		Results:   images,
+		Page:      page,
+		PerPage:   perPage,
+		Total:     info.Query.SearchInfo.TotalHits,
+		TotalHits: info.Query.SearchInfo.TotalHits,
+		HasMore:   info.Continue != nil,
+	}, nil
+}
+
+// PictureOfTheDay returns the Commons Picture of the Day for the given date
+func (p *WikimediaProvider) PictureOfTheDay(date time.Time) (*ImageResult, error) {
+	params := p.imageInfoParams()
+	params.Set("generator", "images")
+	params.Set("titles", "Template:Potd/"+date.Format("2006-01-02"))
+
+	results, _, err := p.query(p.ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no picture of the day for %s", date.Format("2006-01-02"))
+	}
+	return &results[0], nil
+}
+
+// MatchesURL reports whether u is served by Wikimedia
+func (p *WikimediaProvider) MatchesURL(u *url.URL) bool {
+	return hostMatches(u, "wikimedia.org")
+}
+
+// DownloadFile streams an image to a staging file
+func (p *WikimediaProvider) DownloadFile(d *Downloader, imageURL string, progress DownloadProgress) (string, error) {
+	return d.Fetch(p.ctx, p.client, imageURL, http.Header{"User-Agent": {wikimediaUserAgent}}, progress)
+}
+
+// Download downloads an image from URL
+func (p *WikimediaProvider) Download(imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(p.ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", wikimediaUserAgent)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	return data, nil
+}
+
+// imageInfoParams returns the query parameters shared by all lookups
+func (p *WikimediaProvider) imageInfoParams() url.Values {
+	params := url.Values{}
+	params.Set("action", "query")
+	params.Set("format", "json")
+	params.Set("prop", "imageinfo")
+	params.Set("iiprop", "url|size|mime|extmetadata")
+	params.Set("iiurlwidth", "640")
+	params.Set("iiextmetadatafilter", "Artist|LicenseShortName|LicenseUrl|Categories")
+	return params
+}
+
+// query runs an API request and maps the returned file pages to results,
+// in the order reported by the generator. The decoded response is returned
+// for its paging details.
+func (p *WikimediaProvider) query(ctx context.Context, params url.Values) ([]ImageResult, *wikimediaResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", wikimediaUserAgent)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	var result wikimediaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	type indexedResult struct {
+		index  int
+		result ImageResult
+	}
+	indexed := make([]indexedResult, 0, len(result.Query.Pages))
+	for _, page := range result.Query.Pages {
+		if len(page.ImageInfo) == 0 {
+			continue
+		}
+		info := page.ImageInfo[0]
+		if !strings.HasPrefix(info.Mime, "image/") || info.Mime == "image/svg+xml" {
+			continue
+		}
+
+		meta := func(key string) string {
+			if field, ok := info.ExtMetadata[key]; ok {
+				if s, ok := field.Value.(string); ok {
+					return s
+				}
+			}
+			return ""
+		}
+
+		tags := []string{}
+		for _, category := range splitString(meta("Categories"), "|") {
+			if trimmed := trim(category); trimmed != "" {
+				tags = append(tags, trimmed)
+			}
+		}
+
+		indexed = append(indexed, indexedResult{index: page.Index, result: ImageResult{
+			ID:          fmt.Sprintf("%d", page.PageID),
+			URL:         info.DescriptionURL,
+			DownloadURL: info.URL,
+			PreviewURL:  info.ThumbURL,
+			Width:       info.Width,
+			Height:      info.Height,
+			Author:      stripHTML(meta("Artist")),
+			Source:      "Wikimedia",
+			Tags:        tags,
+			License:     meta("LicenseShortName"),
+			LicenseURL:  meta("LicenseUrl"),
+		}})
+	}
+
+	sort.SliceStable(indexed, func(i, j int) bool { return indexed[i].index < indexed[j].index })
+	images := make([]ImageResult, 0, len(indexed))
+	for _, item := range indexed {
+		images = append(images, item.result)
+	}
+	return images, &result, nil
+}
+
+// stripHTML reduces an extmetadata HTML fragment to plain text
+func stripHTML(s string) string {
+	return html.UnescapeString(stripXMLTags(s))
+}
@@ -0,0 +1,17 @@
+package services
+
+import "testing"
+
+func TestStripHTML(t *testing.T) {
+	tests := map[string]string{
+		`<a href="//commons.wikimedia.org/wiki/User:Example" title="User:Example">Example</a>`: "Example",
+		`<span>Jane &amp; John   Doe</span>`:                                                   "Jane & John Doe",
+		"Plain author":                                                                         "Plain author",
+		"":                                                                                     "",
+	}
+	for in, want := range tests {
+		if got := stripHTML(in); got != want {
+			t.Errorf("stripHTML(%q) = %q, want %q", in, got, want)
+		}
+	}
+}